@@ -0,0 +1,187 @@
+package admin
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vvbbnn00/goflet/cache"
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/hasher"
+	"github.com/vvbbnn00/goflet/storage/scrub"
+	"github.com/vvbbnn00/goflet/storage/upload"
+	"github.com/vvbbnn00/goflet/util"
+)
+
+// TestImportExport tests importing a folder and exporting it back
+func TestImportExport(t *testing.T) {
+	src := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "a.txt"), []byte("hello"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("world!"), 0644))
+
+	prefix := "/admin/" + util.RandomString(8)
+	result, err := Import(ImportOptions{Dir: src, Prefix: prefix, Owner: "tester"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Files)
+	assert.Equal(t, int64(11), result.Bytes)
+	hasher.Wait()
+
+	info, err := Meta(prefix + "/sub/b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "tester", info.FileMeta.Owner)
+	assert.Equal(t, "text/plain", info.FileMeta.MimeType)
+	assert.NotEmpty(t, info.FileMeta.Hash.HashSha256)
+
+	_, err = Meta(prefix + "/missing.txt")
+	assert.EqualError(t, err, "file_not_found")
+
+	dst := t.TempDir()
+	exported, err := Export(ExportOptions{Prefix: prefix + "/sub", Dir: dst})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, exported.Files)
+	data, err := os.ReadFile(filepath.Join(dst, "b.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "world!", string(data))
+
+	verified, err := VerifyExport(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, verified.Checked)
+	assert.Empty(t, verified.Problems)
+	assert.NoError(t, os.WriteFile(filepath.Join(dst, "b.txt"), []byte("changed"), 0644))
+	verified, _ = VerifyExport(dst)
+	assert.Equal(t, scrub.KindHashMismatch, verified.Problems["b.txt"])
+}
+
+// TestExportTar tests the incremental export to a tar stream
+func TestExportTar(t *testing.T) {
+	src := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "old.txt"), []byte("old"), 0644))
+	prefix := "/admin/" + util.RandomString(8)
+	_, err := Import(ImportOptions{Dir: src, Prefix: prefix})
+	assert.NoError(t, err)
+	hasher.Wait()
+
+	// Only the files changed after the time are exported
+	since := time.Now().Add(time.Second)
+	info, err := Meta(prefix + "/old.txt")
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chtimes(info.FilePath, since, since.Add(-time.Hour)))
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "new.txt"), []byte("new"), 0644))
+	_, err = Import(ImportOptions{Dir: src, Prefix: prefix + "/next"})
+	assert.NoError(t, err)
+	next, err := Meta(prefix + "/next/new.txt")
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chtimes(next.FilePath, since.Add(time.Hour), since.Add(time.Hour)))
+
+	var buf bytes.Buffer
+	result, err := Export(ExportOptions{Prefix: prefix, Tar: &buf, Since: since.Add(time.Minute)})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Files)
+
+	reader := tar.NewReader(&buf)
+	files := make(map[string]string)
+	for {
+		header, err := reader.Next()
+		if err != nil {
+			break
+		}
+		data, _ := io.ReadAll(reader)
+		files[header.Name] = string(data)
+	}
+	assert.Equal(t, "new", files["next/new.txt"])
+	assert.Contains(t, files[ManifestName], "next/new.txt")
+	assert.NotContains(t, files, "old.txt")
+}
+
+// TestImportOptions tests the dry run, resuming and hard linking of the import
+func TestImportOptions(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"1.txt", "2.txt", "3.txt"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(src, name), []byte(name), 0644))
+	}
+	prefix := "/admin/" + util.RandomString(8)
+
+	result, err := Import(ImportOptions{Dir: src, Prefix: prefix, DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Files)
+	_, err = Meta(prefix + "/1.txt")
+	assert.EqualError(t, err, "file_not_found")
+
+	// The failed file is imported again when the import is resumed
+	locked, _ := util.RelativeToFsPath(strings.TrimPrefix(prefix, "/") + "/3.txt")
+	_ = cache.GetCache().SetEx(storage.CachePrefix+locked, true, 60)
+	result, err = Import(ImportOptions{Dir: src, Prefix: prefix, Resume: true, Link: true, Parallel: 1})
+	assert.NoError(t, err)
+	assert.Len(t, result.Failed, 1)
+	assert.Equal(t, 2, result.Files)
+
+	_ = cache.GetCache().Del(storage.CachePrefix + locked)
+	result, err = Import(ImportOptions{Dir: src, Prefix: prefix, Resume: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Skipped)
+	assert.Equal(t, 1, result.Files)
+	hasher.Wait()
+
+	// The hard linked file shares the content with the local file
+	info, err := Meta(prefix + "/1.txt")
+	assert.NoError(t, err)
+	stored, _ := os.Stat(info.FilePath)
+	local, _ := os.Stat(filepath.Join(src, "1.txt"))
+	assert.True(t, os.SameFile(stored, local))
+}
+
+// TestIssueToken tests signing the token with the configured key
+func TestIssueToken(t *testing.T) {
+	config.GofletCfg.JWTConfig.Algorithm = "HS256"
+	util.JwtInit()
+
+	_, err := IssueToken(TokenOptions{Paths: []string{"/file/*"}})
+	assert.EqualError(t, err, "missing_permissions")
+	_, err = IssueToken(TokenOptions{Paths: []string{"/file/*"}, Methods: []string{"GET"}, Bucket: "missing"})
+	assert.EqualError(t, err, "bucket_not_found")
+
+	token, err := IssueToken(TokenOptions{
+		Subject:   "tester",
+		Paths:     []string{"/file/*"},
+		Methods:   []string{"GET"},
+		ExpiresIn: time.Minute,
+	})
+	assert.NoError(t, err)
+	claims, err := util.ParseJwtToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "tester", claims.Subject)
+	assert.Equal(t, "/file/*", claims.Permissions[0].Path)
+}
+
+// TestGC tests removing the abandoned multipart uploads as a whole, while keeping the parts of the active ones
+func TestGC(t *testing.T) {
+	old := time.Now().Add(-2 * time.Duration(config.GofletCfg.FileConfig.UploadTimeout) * time.Second)
+	newUpload := func(manifestTime time.Time) string {
+		dir := filepath.Join(upload.GetMultipartPath(), util.RandomString(32))
+		assert.NoError(t, os.MkdirAll(dir, os.ModePerm))
+		t.Cleanup(func() {
+			_ = os.RemoveAll(dir)
+		})
+		manifest := filepath.Join(dir, upload.MultipartManifest)
+		assert.NoError(t, os.WriteFile(manifest, []byte("{}"), 0644))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "1.part"), []byte("part"), 0644))
+		assert.NoError(t, os.Chtimes(filepath.Join(dir, "1.part"), old, old))
+		assert.NoError(t, os.Chtimes(manifest, manifestTime, manifestTime))
+		return dir
+	}
+	active := newUpload(time.Now())
+	abandoned := newUpload(old)
+
+	GC()
+	assert.FileExists(t, filepath.Join(active, "1.part"))
+	assert.NoDirExists(t, abandoned)
+}
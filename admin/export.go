@@ -0,0 +1,240 @@
+package admin
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/storage/scrub"
+)
+
+// ManifestName is the name of the manifest written with the exported files
+const ManifestName = "goflet-manifest.json"
+
+// ExportOptions are the options of an export
+type ExportOptions struct {
+	Prefix string    // The path prefix of the files to export, empty for all the files
+	Dir    string    // The local folder to write the files to, ignored if Tar is set
+	Tar    io.Writer // The writer of the tar stream to write the files to
+	Since  time.Time // Only export the files changed after the time, zero for all the files
+}
+
+// ExportResult is the result of an export
+type ExportResult struct {
+	Files  int               `json:"files"`            // The number of the exported files
+	Bytes  int64             `json:"bytes"`            // The total size of the exported files
+	Failed map[string]string `json:"failed,omitempty"` // The errors of the files failed to export, by their relative paths
+}
+
+// ManifestEntry is an exported file in the manifest
+type ManifestEntry struct {
+	Name         string         `json:"name"`         // The path of the file in the export
+	RelativePath string         `json:"relativePath"` // The relative path of the stored file
+	Size         int64          `json:"size"`         // The size of the file
+	ModTime      int64          `json:"modTime"`      // The last modified time of the file
+	MimeType     string         `json:"mimeType"`     // The mime type of the file
+	Owner        string         `json:"owner"`        // The owner of the file
+	UploadedAt   int64          `json:"uploadedAt"`   // The time the file was uploaded
+	Hash         model.FileHash `json:"hash"`         // The hash of the file, the sha256 is always computed in the export
+}
+
+// Manifest lists the exported files, so the export can be verified and imported back
+type Manifest struct {
+	Prefix    string          `json:"prefix"`    // The path prefix of the export
+	Since     int64           `json:"since"`     // The files changed after the time are exported, 0 for all the files
+	CreatedAt int64           `json:"createdAt"` // The time of the export
+	Files     []ManifestEntry `json:"files"`     // The exported files
+}
+
+// exportTarget is where the exported files are written to
+type exportTarget interface {
+	write(name string, size int64, modTime time.Time, r io.Reader) error
+}
+
+// underPrefix checks whether the relative path is the prefix or under it, an empty prefix matches all
+func underPrefix(relativePath string, prefix string) bool {
+	return prefix == "" || relativePath == prefix || strings.HasPrefix(relativePath, prefix+"/")
+}
+
+// Export writes the files under the prefix with the manifest, the paths are kept relative to the prefix
+func Export(opts ExportOptions) (ExportResult, error) {
+	prefix := strings.Trim(opts.Prefix, "/")
+	result := ExportResult{Failed: make(map[string]string)}
+	manifest := Manifest{Prefix: prefix, CreatedAt: time.Now().Unix(), Files: []ManifestEntry{}}
+	if !opts.Since.IsZero() {
+		manifest.Since = opts.Since.Unix()
+	}
+
+	var target exportTarget
+	var tarWriter *tar.Writer
+	if opts.Tar != nil {
+		tarWriter = tar.NewWriter(opts.Tar)
+		target = &tarTarget{writer: tarWriter}
+	} else {
+		if err := os.MkdirAll(opts.Dir, os.ModePerm); err != nil {
+			return result, err
+		}
+		target = &dirTarget{dir: opts.Dir}
+	}
+
+	storage.WalkFiles(func(fsPath string, _ int64, meta model.FileMeta) {
+		if !underPrefix(meta.RelativePath, prefix) {
+			return
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(meta.RelativePath, prefix), "/")
+		if name == "" {
+			name = meta.FileName // The prefix is the file itself
+		}
+		if name == ManifestName {
+			result.Failed[meta.RelativePath] = "name_reserved"
+			return
+		}
+
+		entry, exported, err := exportFile(target, fsPath, name, meta, opts.Since)
+		if err != nil {
+			result.Failed[meta.RelativePath] = err.Error()
+			return
+		}
+		if !exported {
+			return // Not changed since the last export
+		}
+		manifest.Files = append(manifest.Files, entry)
+		result.Files++
+		result.Bytes += entry.Size
+	})
+
+	// The manifest is written last, with the hashes computed in the export, the files exported to the folder
+	// before are kept in it, so an incremental export to the same folder can be verified as a whole
+	if opts.Tar == nil {
+		manifest.Files = mergeManifest(opts.Dir, manifest.Files)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Name < manifest.Files[j].Name
+	})
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	err := target.write(ManifestName, int64(len(data)), time.Now(), strings.NewReader(string(data)))
+	if err == nil && tarWriter != nil {
+		err = tarWriter.Close()
+	}
+	return result, err
+}
+
+// mergeManifest adds the files in the manifest of the folder which are not exported again
+func mergeManifest(dir string, files []ManifestEntry) []ManifestEntry {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return files
+	}
+	var previous Manifest
+	if json.Unmarshal(data, &previous) != nil {
+		return files
+	}
+
+	exported := make(map[string]bool, len(files))
+	for _, entry := range files {
+		exported[entry.Name] = true
+	}
+	for _, entry := range previous.Files {
+		if !exported[entry.Name] {
+			files = append(files, entry)
+		}
+	}
+	return files
+}
+
+// exportFile writes the stored file to the target if it is changed after the time
+func exportFile(target exportTarget, fsPath string, name string, meta model.FileMeta,
+	since time.Time) (ManifestEntry, bool, error) {
+	src, err := storage.GetFileReader(fsPath)
+	if err != nil {
+		return ManifestEntry{}, false, err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+	info, err := src.Stat()
+	if err != nil {
+		return ManifestEntry{}, false, err
+	}
+	if !since.IsZero() && !info.ModTime().After(since) && meta.UploadedAt <= since.Unix() {
+		return ManifestEntry{}, false, nil
+	}
+
+	sum := sha256.New()
+	if err := target.write(name, info.Size(), info.ModTime(), io.TeeReader(src, sum)); err != nil {
+		return ManifestEntry{}, false, err
+	}
+	fileHash := meta.Hash
+	sha256Hex := hex.EncodeToString(sum.Sum(nil))
+	if fileHash.HashSha256 != "" && fileHash.HashSha256 != sha256Hex {
+		return ManifestEntry{}, false, errors.New(scrub.KindHashMismatch) // The stored file is corrupted
+	}
+	fileHash.HashSha256 = sha256Hex
+
+	return ManifestEntry{
+		Name:         name,
+		RelativePath: meta.RelativePath,
+		Size:         info.Size(),
+		ModTime:      info.ModTime().Unix(),
+		MimeType:     meta.MimeType,
+		Owner:        meta.Owner,
+		UploadedAt:   meta.UploadedAt,
+		Hash:         fileHash,
+	}, true, nil
+}
+
+// dirTarget writes the exported files to a local folder
+type dirTarget struct {
+	dir string
+}
+
+// write writes the file to the folder, keeping the modification time
+func (t *dirTarget) write(name string, _ int64, modTime time.Time, r io.Reader) error {
+	localPath := filepath.Join(t.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(localPath), os.ModePerm); err != nil {
+		return err
+	}
+	dst, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, r)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(localPath, time.Now(), modTime)
+}
+
+// tarTarget writes the exported files to a tar stream
+type tarTarget struct {
+	writer *tar.Writer
+}
+
+// write writes the file to the tar stream
+func (t *tarTarget) write(name string, size int64, modTime time.Time, r io.Reader) error {
+	err := t.writer.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(t.writer, r, size)
+	return err
+}
@@ -0,0 +1,13 @@
+package admin
+
+import (
+	"github.com/vvbbnn00/goflet/task"
+)
+
+// GC removes the outdated uploads, the processed images over the budget and the empty folders, like the scheduled
+// tasks do
+func GC() {
+	task.CleanOutdatedFile()
+	task.CleanImageCache()
+	task.DeleteEmptyFolder()
+}
@@ -0,0 +1,224 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/vvbbnn00/goflet/storage/upload"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/hash"
+)
+
+// defaultImportParallel is the number of the files imported at the same time by default
+const defaultImportParallel = 4
+
+// ImportOptions are the options of an import
+type ImportOptions struct {
+	Dir      string // The local folder to import
+	Prefix   string // The path to store the files under
+	Bucket   string // The bucket the files should be stored in, empty if not limited
+	Owner    string // The subject owning the files
+	DryRun   bool   // Only report the files to import, nothing is stored
+	Parallel int    // The number of the files imported at the same time
+	Link     bool   // Hard link the files instead of copying them, the folder should be on the device of the storage
+	Resume   bool   // Skip the files imported by the interrupted import of the same folder and prefix
+	Quota    bool   // Check the files against the quotas like the uploads, otherwise the usage is only counted
+}
+
+// ImportResult is the result of an import
+type ImportResult struct {
+	Files   int               `json:"files"`            // The number of the imported files
+	Bytes   int64             `json:"bytes"`            // The total size of the imported files
+	Skipped int               `json:"skipped"`          // The number of the files skipped as imported before
+	Failed  map[string]string `json:"failed,omitempty"` // The errors of the files failed to import, by their local paths
+}
+
+// importJob is a file to import
+type importJob struct {
+	localPath string
+	path      string
+	info      fs.FileInfo
+}
+
+// progressEntry is a line of the progress file, the file is imported again if it is changed
+type progressEntry struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"`
+}
+
+// Import stores the files in the local folder under the prefix, the hashes are computed in the background
+func Import(opts ImportOptions) (ImportResult, error) {
+	result := ImportResult{Failed: make(map[string]string)}
+	if info, err := os.Stat(opts.Dir); err != nil || !info.IsDir() {
+		return result, errors.New("folder_not_found")
+	}
+
+	progress, err := openProgress(opts)
+	if err != nil {
+		return result, err
+	}
+	defer progress.close()
+
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = defaultImportParallel
+	}
+	jobs := make(chan importJob)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				err := importFile(job, opts, progress)
+				lock.Lock()
+				if err != nil {
+					result.Failed[job.localPath] = err.Error()
+				} else {
+					result.Files++
+					result.Bytes += job.info.Size()
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+	err = filepath.WalkDir(opts.Dir, func(localPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			lock.Lock()
+			result.Failed[localPath] = err.Error()
+			lock.Unlock()
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil // Skip the folders and the links
+		}
+
+		rel, err := filepath.Rel(opts.Dir, localPath)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // The file is removed during the import
+		}
+		job := importJob{localPath: localPath, path: path.Join("/", opts.Prefix, filepath.ToSlash(rel)), info: info}
+		if progress.done(job) {
+			lock.Lock()
+			result.Skipped++
+			lock.Unlock()
+			return nil
+		}
+		jobs <- job
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+
+	if err == nil && len(result.Failed) == 0 && !opts.DryRun {
+		progress.remove() // Nothing is left to resume
+	}
+	return result, err
+}
+
+// importFile stores the file of the job, only checks the path in the dry run
+func importFile(job importJob, opts ImportOptions, progress *importProgress) error {
+	pathData, err := util.ParsePath(job.path)
+	if err != nil {
+		return err
+	}
+	if opts.Bucket != "" && util.GetBucketName(pathData.RelativePath) != opts.Bucket {
+		return errors.New("bucket_not_allowed")
+	}
+	if opts.DryRun {
+		return nil
+	}
+
+	err = upload.ImportFile(job.localPath, pathData.RelativePath, opts.Owner, opts.Link, opts.Quota)
+	if err != nil {
+		return err
+	}
+	return progress.add(job)
+}
+
+// importProgress is the progress of an import, stored in the upload path to resume the import,
+// it is cleaned with the outdated uploads if the import is not resumed in time
+type importProgress struct {
+	path    string
+	lock    sync.Mutex
+	file    *os.File
+	entries map[string]progressEntry
+}
+
+// openProgress loads the progress of the import, the progress is not kept if the import is not resumable
+func openProgress(opts ImportOptions) (*importProgress, error) {
+	if !opts.Resume || opts.DryRun {
+		return &importProgress{}, nil
+	}
+
+	dir, _ := filepath.Abs(opts.Dir)
+	p := &importProgress{
+		path:    filepath.Join(util.GetUploadPath(), "import-"+hash.StringSha3New256(dir+"\n"+opts.Prefix)+".progress"),
+		entries: make(map[string]progressEntry),
+	}
+	if file, err := os.Open(p.path); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var entry progressEntry
+			if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+				p.entries[entry.Path] = entry
+			}
+		}
+		_ = file.Close()
+	}
+
+	file, err := os.OpenFile(p.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	p.file = file
+	return p, nil
+}
+
+// done checks whether the file of the job is imported and not changed since
+func (p *importProgress) done(job importJob) bool {
+	entry, ok := p.entries[job.path]
+	return ok && entry.Size == job.info.Size() && entry.ModTime == job.info.ModTime().UnixNano()
+}
+
+// add records the file of the job as imported
+func (p *importProgress) add(job importJob) error {
+	if p.file == nil {
+		return nil
+	}
+	data, _ := json.Marshal(progressEntry{Path: job.path, Size: job.info.Size(), ModTime: job.info.ModTime().UnixNano()})
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	_, err := p.file.Write(append(data, '\n'))
+	return err
+}
+
+// close closes the progress file
+func (p *importProgress) close() {
+	if p.file != nil {
+		_ = p.file.Close()
+	}
+}
+
+// remove removes the progress file
+func (p *importProgress) remove() {
+	p.close()
+	p.file = nil
+	if p.path != "" {
+		_ = os.Remove(p.path)
+	}
+}
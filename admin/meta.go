@@ -0,0 +1,23 @@
+package admin
+
+import (
+	"errors"
+
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/util"
+)
+
+// Meta returns the info of the file at the path, the metadata is read from the disk instead of the cache
+func Meta(path string) (model.FileInfo, error) {
+	pathData, err := util.ParsePath(path)
+	if err != nil {
+		return model.FileInfo{}, err
+	}
+	info, err := storage.GetFileInfo(pathData.FsPath)
+	if err != nil {
+		return model.FileInfo{}, errors.New("file_not_found")
+	}
+	info.FileMeta, err = storage.LoadFileMeta(pathData.FsPath)
+	return info, err
+}
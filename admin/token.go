@@ -0,0 +1,91 @@
+// Package admin provides the administration operations of the storage, shared by the commands and the admin API
+package admin
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/util"
+)
+
+// TokenOptions are the options of the issued token
+type TokenOptions struct {
+	Subject      string        // The subject of the token
+	Paths        []string      // The paths allowed, support wildcards
+	Methods      []string      // The methods allowed on the paths
+	ExpiresIn    time.Duration // The time before the token expires
+	Bucket       string        // The bucket that the token is bound to, signed with the key of the bucket if set
+	RateLimitBps int64         // The bandwidth of each connection in bytes per second
+	Watermark    string        // The watermark preset forced on the processed images
+	PrivateKey   string        // The PEM private key for RS/ES/PS, the configured key is used if empty
+}
+
+// IssueToken signs the token with the configured algorithm and key
+func IssueToken(opts TokenOptions) (string, error) {
+	if len(opts.Paths) == 0 || len(opts.Methods) == 0 {
+		return "", errors.New("missing_permissions")
+	}
+
+	algorithm := config.GofletCfg.JWTConfig.Algorithm
+	signingKey := config.GofletCfg.JWTConfig.Security.SigningKey
+	privateKey := config.GofletCfg.JWTConfig.Security.PrivateKey
+	if opts.Bucket != "" {
+		bucket, ok := config.GofletCfg.BucketConfig.Buckets[opts.Bucket]
+		if !ok {
+			return "", errors.New("bucket_not_found")
+		}
+		algorithm = bucket.JWTConfig.Algorithm
+		signingKey = bucket.JWTConfig.SigningKey
+		privateKey = "" // The buckets only keep the public keys
+	}
+	if opts.PrivateKey != "" {
+		privateKey = opts.PrivateKey
+	}
+
+	method := jwt.GetSigningMethod(algorithm)
+	if method == nil || method == jwt.SigningMethodNone {
+		return "", errors.New("invalid_algorithm")
+	}
+	key, err := signKey(method, signingKey, privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	tokenClaims := &util.JwtClaims{
+		StandardClaims: &jwt.StandardClaims{
+			Subject:   opts.Subject,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(opts.ExpiresIn).Unix(),
+		},
+		RateLimitBps: opts.RateLimitBps,
+		Bucket:       opts.Bucket,
+		Watermark:    opts.Watermark,
+	}
+	for _, path := range opts.Paths {
+		tokenClaims.Permissions = append(tokenClaims.Permissions, util.Permission{Path: path, Methods: opts.Methods})
+	}
+	return jwt.NewWithClaims(method, tokenClaims).SignedString(key)
+}
+
+// signKey returns the key to sign the token with the method
+func signKey(method jwt.SigningMethod, signingKey string, privateKey string) (interface{}, error) {
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return []byte(signingKey), nil
+	}
+
+	if privateKey == "" {
+		return nil, errors.New("private_key_required")
+	}
+	switch method.(type) {
+	case *jwt.SigningMethodECDSA:
+		return jwt.ParseECPrivateKeyFromPEM([]byte(privateKey))
+	default: // RSA and RSA-PSS
+		return jwt.ParseRSAPrivateKeyFromPEM([]byte(privateKey))
+	}
+}
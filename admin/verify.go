@@ -0,0 +1,40 @@
+package admin
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/vvbbnn00/goflet/storage/scrub"
+	"github.com/vvbbnn00/goflet/util/hash"
+)
+
+// VerifyResult is the result of a verification of an export
+type VerifyResult struct {
+	Checked  int               `json:"checked"`  // The number of the checked files
+	Problems map[string]string `json:"problems"` // The problems of the files, by their names in the export
+}
+
+// VerifyExport checks the files of the exported folder against the manifest
+func VerifyExport(dir string) (VerifyResult, error) {
+	result := VerifyResult{Problems: make(map[string]string)}
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return result, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return result, err
+	}
+
+	for _, entry := range manifest.Files {
+		result.Checked++
+		sum, err := hash.FileSha256(filepath.Join(dir, filepath.FromSlash(entry.Name)))
+		if err != nil {
+			result.Problems[entry.Name] = err.Error()
+		} else if sum != entry.Hash.HashSha256 {
+			result.Problems[entry.Name] = scrub.KindHashMismatch
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,185 @@
+// Package client provides the Go client of the HTTP API, the uploads are chunked and resumable,
+// and the downloads support the ranges and resuming
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vvbbnn00/goflet/storage/model"
+)
+
+const (
+	// DefaultChunkSize is the default size of the upload chunks, below the default max post size
+	DefaultChunkSize = 8 << 20
+	// DefaultParallel is the default number of the chunks uploaded at the same time
+	DefaultParallel = 4
+	// ReadyPollInterval is the interval to poll the meta of the file waiting for the upload to complete
+	ReadyPollInterval = 100 * time.Millisecond
+)
+
+// Client is the client of a goflet server
+type Client struct {
+	BaseURL    string       // The URL of the server, e.g. http://localhost:8080
+	Token      string       // The JWT token sent in the Authorization header, empty if JWT is disabled
+	HTTPClient *http.Client // The HTTP client to send the requests
+	ChunkSize  int64        // The size of the upload chunks
+	Parallel   int          // The number of the chunks uploaded at the same time
+}
+
+// Error is the error response of the server
+type Error struct {
+	StatusCode int    // The status code of the response
+	Message    string // The error message of the response
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("goflet: %d %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound checks whether the error is a not found response
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// New creates a client of the server with the token
+func New(baseURL string, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: http.DefaultClient,
+		ChunkSize:  DefaultChunkSize,
+		Parallel:   DefaultParallel,
+	}
+}
+
+// escapePath escapes each segment of the path, the path always starts with a slash
+func escapePath(path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// newRequest creates the request to the route of the server
+func (c *Client) newRequest(ctx context.Context, method string, route string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+route, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return req, nil
+}
+
+// do sends the request, the response with an unexpected status code is converted to an error
+func (c *Client) do(req *http.Request, expected ...int) (*http.Response, error) {
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	e := &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body) == nil && body.Error != "" {
+		e.Message = body.Error
+	}
+	return nil, e
+}
+
+// doJSON sends the request with the JSON body, and decodes the JSON response into the result if it is not nil
+func (c *Client) doJSON(ctx context.Context, method string, route string, body any, result any, expected ...int) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := c.newRequest(ctx, method, route, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.do(req, expected...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// Meta returns the info of the file
+func (c *Client) Meta(ctx context.Context, path string) (*model.FileInfo, error) {
+	var info model.FileInfo
+	err := c.doJSON(ctx, http.MethodGet, "/api/meta"+escapePath(path), nil, &info, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// copyMove sends the copy or move action
+func (c *Client) copyMove(ctx context.Context, action string, sourcePath string, targetPath string, overwrite bool) error {
+	onConflict := "abort"
+	if overwrite {
+		onConflict = "overwrite"
+	}
+	body := map[string]string{
+		"sourcePath": sourcePath,
+		"targetPath": targetPath,
+		"onConflict": onConflict,
+	}
+	return c.doJSON(ctx, http.MethodPost, "/api/action/"+action, body, nil, http.StatusOK)
+}
+
+// Copy copies the file, the existing target file is overwritten only if overwrite is true
+func (c *Client) Copy(ctx context.Context, sourcePath string, targetPath string, overwrite bool) error {
+	return c.copyMove(ctx, "copy", sourcePath, targetPath, overwrite)
+}
+
+// Move moves the file, the existing target file is overwritten only if overwrite is true
+func (c *Client) Move(ctx context.Context, sourcePath string, targetPath string, overwrite bool) error {
+	return c.copyMove(ctx, "move", sourcePath, targetPath, overwrite)
+}
+
+// Create creates an empty file, fails if the file already exists
+func (c *Client) Create(ctx context.Context, path string) error {
+	body := map[string]string{"path": path}
+	return c.doJSON(ctx, http.MethodPost, "/api/action/create", body, nil, http.StatusCreated)
+}
+
+// Delete deletes the file
+func (c *Client) Delete(ctx context.Context, path string) error {
+	return c.doJSON(ctx, http.MethodDelete, "/file"+escapePath(path), nil, nil, http.StatusNoContent)
+}
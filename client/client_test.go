@@ -0,0 +1,176 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/route"
+	"github.com/vvbbnn00/goflet/util"
+)
+
+var server *httptest.Server
+
+func init() {
+	config.InitConfig()
+	*config.GofletCfg.JWTConfig.Enabled = false
+	server = httptest.NewServer(route.RegisterRoutes())
+}
+
+// testData returns the content of the size
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+// waitReady waits until the uploaded content is stored and hashed
+func waitReady(t *testing.T, c *Client, path string, data []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sum := sha256.Sum256(data)
+	_, err := c.WaitReady(ctx, path, hex.EncodeToString(sum[:]))
+	assert.NoError(t, err)
+}
+
+// TestUploadDownload tests uploading the file in the parallel chunks and downloading it
+func TestUploadDownload(t *testing.T) {
+	ctx := context.Background()
+	c := New(server.URL, "")
+	c.ChunkSize = 1000
+	path := "/client/" + util.RandomString(8) + "/data file.bin"
+	data := testData(4500)
+
+	assert.NoError(t, c.Upload(ctx, path, bytes.NewReader(data), int64(len(data))))
+	waitReady(t, c, path, data)
+
+	var buf bytes.Buffer
+	assert.NoError(t, c.Download(ctx, path, &buf))
+	assert.Equal(t, data, buf.Bytes())
+
+	buf.Reset()
+	assert.NoError(t, c.DownloadRange(ctx, path, 1000, 500, &buf))
+	assert.Equal(t, data[1000:1500], buf.Bytes())
+
+	info, err := c.Meta(ctx, path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), info.FileSize)
+
+	assert.NoError(t, c.Delete(ctx, path))
+	_, err = c.Meta(ctx, path)
+	assert.True(t, IsNotFound(err))
+}
+
+// TestResumeUpload tests resuming the upload with the saved session
+func TestResumeUpload(t *testing.T) {
+	ctx := context.Background()
+	c := New(server.URL, "")
+	c.ChunkSize = 100
+	path := "/client/" + util.RandomString(8) + "/resume.bin"
+	data := testData(350)
+
+	// Upload the first chunks only, as if the upload was interrupted
+	session := c.NewUploadSession(path, int64(len(data)))
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, c.uploadChunk(ctx, session, bytes.NewReader(data), i))
+		session.markDone(i)
+	}
+	assert.Len(t, session.pending(), 2)
+
+	assert.NoError(t, c.ResumeUpload(ctx, session, bytes.NewReader(data)))
+	waitReady(t, c, path, data)
+	var buf bytes.Buffer
+	assert.NoError(t, c.Download(ctx, path, &buf))
+	assert.Equal(t, data, buf.Bytes())
+	assert.NoError(t, c.Delete(ctx, path))
+}
+
+// TestDownloadFile tests resuming the download into the local file
+func TestDownloadFile(t *testing.T) {
+	ctx := context.Background()
+	c := New(server.URL, "")
+	path := "/client/" + util.RandomString(8) + "/download.bin"
+	data := testData(2048)
+	assert.NoError(t, c.Upload(ctx, path, bytes.NewReader(data), int64(len(data))))
+	waitReady(t, c, path, data) // The hashes change the ETag
+
+	etag, _, err := c.head(ctx, path)
+	assert.NoError(t, err)
+	localPath := filepath.Join(t.TempDir(), "download.bin")
+	assert.NoError(t, os.WriteFile(localPath+".part", data[:1000], 0644))
+	assert.NoError(t, os.WriteFile(localPath+".part.etag", []byte(etag), 0644))
+
+	assert.NoError(t, c.DownloadFile(ctx, path, localPath))
+	got, err := os.ReadFile(localPath)
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+	_, err = os.Stat(localPath + ".part")
+	assert.True(t, os.IsNotExist(err))
+	assert.NoError(t, c.Delete(ctx, path))
+}
+
+// TestActions tests creating, copying and moving the files
+func TestActions(t *testing.T) {
+	ctx := context.Background()
+	c := New(server.URL, "")
+	dir := "/client/" + util.RandomString(8)
+
+	assert.NoError(t, c.Create(ctx, dir+"/a.txt"))
+	err := c.Create(ctx, dir+"/a.txt")
+	var e *Error
+	if assert.ErrorAs(t, err, &e) {
+		assert.Equal(t, http.StatusConflict, e.StatusCode)
+	}
+
+	assert.NoError(t, c.Copy(ctx, dir+"/a.txt", dir+"/b.txt", false))
+	assert.Error(t, c.Copy(ctx, dir+"/a.txt", dir+"/b.txt", false))
+	assert.NoError(t, c.Move(ctx, dir+"/a.txt", dir+"/b.txt", true))
+	_, err = c.Meta(ctx, dir+"/a.txt")
+	assert.True(t, IsNotFound(err))
+	assert.NoError(t, c.Delete(ctx, dir+"/b.txt"))
+}
+
+// TestToken tests that the server accepts the token built by the client
+func TestToken(t *testing.T) {
+	*config.GofletCfg.JWTConfig.Enabled = true
+	config.GofletCfg.JWTConfig.Algorithm = "HS256"
+	util.JwtInit()
+	defer func() {
+		*config.GofletCfg.JWTConfig.Enabled = false
+	}()
+
+	ctx := context.Background()
+	dir := "/client/" + util.RandomString(8)
+	token, err := NewToken(time.Minute).
+		Subject("tester").
+		Allow("/api/action/create", http.MethodPost).
+		Allow("/file"+dir+"/*", http.MethodGet, http.MethodHead, http.MethodDelete).
+		Sign(jwt.SigningMethodHS256, []byte(config.GofletCfg.JWTConfig.Security.SigningKey))
+	assert.NoError(t, err)
+
+	c := New(server.URL, token)
+	assert.NoError(t, c.Create(ctx, dir+"/token.txt"))
+	var buf bytes.Buffer
+	assert.NoError(t, c.Download(ctx, dir+"/token.txt", &buf))
+
+	_, err = c.Meta(ctx, dir+"/token.txt")
+	var e *Error
+	if assert.ErrorAs(t, err, &e) {
+		assert.Equal(t, http.StatusUnauthorized, e.StatusCode)
+	}
+	assert.Error(t, New(server.URL, "").Delete(ctx, dir+"/token.txt"))
+	assert.NoError(t, c.Delete(ctx, dir+"/token.txt"))
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// Download writes the content of the file to the writer
+func (c *Client) Download(ctx context.Context, path string, w io.Writer) error {
+	return c.DownloadRange(ctx, path, 0, -1, w)
+}
+
+// DownloadRange writes the range of the file to the writer, the negative length downloads to the end of the file
+func (c *Client) DownloadRange(ctx context.Context, path string, offset int64, length int64, w io.Writer) error {
+	return c.download(ctx, path, offset, length, "", w)
+}
+
+// download sends the range request, the ETag is checked by the server if it is not empty
+func (c *Client) download(ctx context.Context, path string, offset int64, length int64, etag string, w io.Writer) error {
+	req, err := c.newRequest(ctx, http.MethodGet, "/file"+escapePath(path), nil)
+	if err != nil {
+		return err
+	}
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	expected := http.StatusOK
+	if offset > 0 || length >= 0 {
+		rangeHeader := "bytes=" + strconv.FormatInt(offset, 10) + "-"
+		if length >= 0 {
+			rangeHeader += strconv.FormatInt(offset+length-1, 10)
+		}
+		req.Header.Set("Range", rangeHeader)
+		expected = http.StatusPartialContent
+	}
+
+	resp, err := c.do(req, expected)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// DownloadFile downloads the file to the local path, the content is written to a .part file first,
+// so an interrupted download is resumed if the file is not changed on the server
+func (c *Client) DownloadFile(ctx context.Context, path string, localPath string) error {
+	etag, size, err := c.head(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	partPath := localPath + ".part"
+	etagPath := partPath + ".etag"
+	var offset int64
+	if saved, err := os.ReadFile(etagPath); err == nil && string(saved) == etag {
+		if info, err := os.Stat(partPath); err == nil && info.Size() <= size {
+			offset = info.Size()
+		}
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if offset == 0 {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(etagPath, []byte(etag), 0644); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	if offset < size {
+		err = c.download(ctx, path, offset, -1, etag, file)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	var e *Error
+	if errors.As(err, &e) && e.StatusCode == http.StatusPreconditionFailed {
+		_ = os.Remove(etagPath) // The file is changed, the next try starts over
+	}
+	if err != nil {
+		return err
+	}
+
+	_ = os.Remove(etagPath)
+	return os.Rename(partPath, localPath)
+}
+
+// head returns the ETag and the size of the file
+func (c *Client) head(ctx context.Context, path string) (string, int64, error) {
+	req, err := c.newRequest(ctx, http.MethodHead, "/file"+escapePath(path), nil)
+	if err != nil {
+		return "", 0, err
+	}
+	resp, err := c.do(req, http.StatusOK)
+	if err != nil {
+		return "", 0, err
+	}
+	_ = resp.Body.Close()
+	size, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return "", 0, errors.New("goflet: missing content length")
+	}
+	return resp.Header.Get("ETag"), size, nil
+}
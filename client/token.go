@@ -0,0 +1,85 @@
+package client
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/vvbbnn00/goflet/util/claims"
+)
+
+// TokenBuilder builds the claims of the JWT tokens accepted by the server
+type TokenBuilder struct {
+	claims claims.JwtClaims
+}
+
+// NewToken creates the builder of a token expiring after the duration
+func NewToken(expiresIn time.Duration) *TokenBuilder {
+	now := time.Now()
+	return &TokenBuilder{
+		claims: claims.JwtClaims{
+			StandardClaims: &jwt.StandardClaims{
+				IssuedAt:  now.Unix(),
+				NotBefore: now.Unix(),
+				ExpiresAt: now.Add(expiresIn).Unix(),
+			},
+		},
+	}
+}
+
+// Subject sets the subject of the token, which is the owner of the uploaded files
+func (b *TokenBuilder) Subject(subject string) *TokenBuilder {
+	b.claims.Subject = subject
+	return b
+}
+
+// Issuer sets the issuer of the token
+func (b *TokenBuilder) Issuer(issuer string) *TokenBuilder {
+	b.claims.Issuer = issuer
+	return b
+}
+
+// Allow allows the methods on the path, the path supports wildcards, e.g. /file/images/*
+func (b *TokenBuilder) Allow(path string, methods ...string) *TokenBuilder {
+	b.claims.Permissions = append(b.claims.Permissions, claims.Permission{Path: path, Methods: methods})
+	return b
+}
+
+// AllowQuery allows the methods on the path only if the query parameters match the map
+func (b *TokenBuilder) AllowQuery(path string, query map[string]string, methods ...string) *TokenBuilder {
+	b.claims.Permissions = append(b.claims.Permissions, claims.Permission{Path: path, Methods: methods, Query: query})
+	return b
+}
+
+// Bucket binds the token to the bucket, the token should be signed with the key of the bucket
+func (b *TokenBuilder) Bucket(bucket string) *TokenBuilder {
+	b.claims.Bucket = bucket
+	return b
+}
+
+// RateLimit limits the bandwidth of each connection in bytes per second
+func (b *TokenBuilder) RateLimit(bps int64) *TokenBuilder {
+	b.claims.RateLimitBps = bps
+	return b
+}
+
+// Watermark forces the watermark preset on the processed images
+func (b *TokenBuilder) Watermark(preset string) *TokenBuilder {
+	b.claims.Watermark = preset
+	return b
+}
+
+// Claims returns the claims of the token
+func (b *TokenBuilder) Claims() *claims.JwtClaims {
+	c := b.claims
+	standard := *b.claims.StandardClaims
+	c.StandardClaims = &standard
+	c.Permissions = append([]claims.Permission(nil), b.claims.Permissions...)
+	return &c
+}
+
+// Sign signs the token with the method and the key, the key is a []byte for HMAC,
+// or the private key for RSA and ECDSA
+func (b *TokenBuilder) Sign(method jwt.SigningMethod, key interface{}) (string, error) {
+	return jwt.NewWithClaims(method, b.Claims()).SignedString(key)
+}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vvbbnn00/goflet/storage/model"
+)
+
+// UploadSession is the state of a chunked upload, it can be saved as JSON to resume the upload later,
+// the uploaded chunks are kept by the server until the upload times out
+type UploadSession struct {
+	Path      string `json:"path"`      // The path of the file
+	Size      int64  `json:"size"`      // The size of the file
+	ChunkSize int64  `json:"chunkSize"` // The size of the chunks
+	Done      []bool `json:"done"`      // Whether each chunk is uploaded
+
+	lock sync.Mutex
+}
+
+// NewUploadSession creates the session to upload the file of the size in the chunks of the client
+func (c *Client) NewUploadSession(path string, size int64) *UploadSession {
+	chunkSize := c.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	chunks := (size + chunkSize - 1) / chunkSize
+	if chunks == 0 {
+		chunks = 1 // The empty file is uploaded in an empty chunk
+	}
+	return &UploadSession{
+		Path:      path,
+		Size:      size,
+		ChunkSize: chunkSize,
+		Done:      make([]bool, chunks),
+	}
+}
+
+// pending returns the indexes of the chunks not uploaded yet
+func (s *UploadSession) pending() []int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var indexes []int
+	for i, done := range s.Done {
+		if !done {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// markDone marks the chunk as uploaded
+func (s *UploadSession) markDone(index int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Done[index] = true
+}
+
+// Upload uploads the file of the size read from the reader, the chunks are uploaded in parallel. The server stores
+// the file in the background, use WaitReady to wait until it can be read
+func (c *Client) Upload(ctx context.Context, path string, r io.ReaderAt, size int64) error {
+	return c.ResumeUpload(ctx, c.NewUploadSession(path, size), r)
+}
+
+// ResumeUpload uploads the chunks of the session not uploaded yet, then completes the upload,
+// if it fails, the session can be resumed with the same content. As for Upload, the file is stored in the background
+func (c *Client) ResumeUpload(ctx context.Context, session *UploadSession, r io.ReaderAt) error {
+	if err := c.uploadChunks(ctx, session, r); err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/upload"+escapePath(session.Path), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// WaitReady polls the meta of the file until the file is stored and hashed, so it can be read after an upload. If the
+// hex encoded SHA-256 of the uploaded content is not empty, it also waits until the previous content is replaced
+func (c *Client) WaitReady(ctx context.Context, path string, sha256 string) (*model.FileInfo, error) {
+	ticker := time.NewTicker(ReadyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		info, err := c.Meta(ctx, path)
+		if err != nil && !IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil {
+			hash := info.FileMeta.Hash.HashSha256
+			if hash != "" && (sha256 == "" || strings.EqualFold(hash, sha256)) {
+				return info, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// uploadChunks uploads the pending chunks of the session with the workers of the client
+func (c *Client) uploadChunks(ctx context.Context, session *UploadSession, r io.ReaderAt) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for _, index := range session.pending() {
+			select {
+			case indexes <- index:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	parallel := c.Parallel
+	if parallel <= 0 {
+		parallel = DefaultParallel
+	}
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				if err := c.uploadChunk(ctx, session, r, index); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+				session.markDone(index)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if len(session.pending()) > 0 {
+		return errors.New("goflet: upload canceled")
+	}
+	return nil
+}
+
+// uploadChunk uploads the chunk of the index with the Content-Range header
+func (c *Client) uploadChunk(ctx context.Context, session *UploadSession, r io.ReaderAt, index int) error {
+	start := int64(index) * session.ChunkSize
+	length := min(session.ChunkSize, session.Size-start)
+
+	req, err := c.newRequest(ctx, http.MethodPut, "/upload"+escapePath(session.Path),
+		io.NewSectionReader(r, start, length))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = length
+	if length > 0 {
+		req.Header.Set("Content-Range", "bytes "+strconv.FormatInt(start, 10)+"-"+
+			strconv.FormatInt(start+length-1, 10)+"/"+strconv.FormatInt(session.Size, 10))
+	}
+
+	resp, err := c.do(req, http.StatusAccepted)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
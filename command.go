@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vvbbnn00/goflet/admin"
+	"github.com/vvbbnn00/goflet/storage/hasher"
+	"github.com/vvbbnn00/goflet/storage/image"
+	"github.com/vvbbnn00/goflet/storage/scrub"
+)
+
+const usage = `Usage: goflet [command]
+
+Commands:
+  serve                          Start the server, the default command
+  token issue --path <path> --methods <methods> [options]
+                                 Issue a token signed with the configured key
+  fsck [--repair] [--quarantine] Verify the stored files against their hashes
+  import <dir> [--prefix <path>] [--owner <subject>] [--dry-run] [--parallel <n>] [--link] [--resume]
+                                 Store the files of a local folder
+  export <prefix> <dir> [--tar] [--since <time>]
+                                 Write the stored files under the prefix to a local folder or a tar file
+  verify <dir>                   Verify the exported folder against its manifest
+  meta <path>                    Print the info of the stored file
+  gc                             Remove the outdated uploads, the processed images over the budget and the empty folders
+`
+
+// runCommand runs the command of the arguments, the server is started if no command is given
+func runCommand(args []string) error {
+	if len(args) == 0 {
+		serve()
+		return nil
+	}
+
+	switch args[0] {
+	case "serve":
+		serve()
+		return nil
+	case "token":
+		return runToken(args[1:])
+	case "fsck":
+		return runFsck(args[1:])
+	case "import":
+		return runImport(args[1:])
+	case "export":
+		return runExport(args[1:])
+	case "verify":
+		return runVerify(args[1:])
+	case "meta":
+		return runMeta(args[1:])
+	case "gc":
+		admin.GC()
+		return nil
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	}
+	return errors.New("unknown command: " + args[0] + "\n\n" + usage)
+}
+
+// printJSON prints the value as indented JSON
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// splitList splits the comma separated list, the empty items are dropped
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseFlags parses the flags among the arguments, returns the other arguments
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		// The flags may follow the positional arguments
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// runToken handles goflet token issue
+func runToken(args []string) error {
+	if len(args) == 0 || args[0] != "issue" {
+		return errors.New("usage: goflet token issue --path <path> --methods <methods> [options]")
+	}
+
+	flags := flag.NewFlagSet("token issue", flag.ContinueOnError)
+	paths := flags.String("path", "", "The paths allowed, comma separated, e.g. /file/images/*")
+	methods := flags.String("methods", "GET", "The methods allowed, comma separated")
+	subject := flags.String("subject", "", "The subject of the token")
+	expires := flags.Duration("expires", time.Hour, "The time before the token expires")
+	bucket := flags.String("bucket", "", "The bucket that the token is bound to")
+	rateLimit := flags.Int64("rate-limit", 0, "The bandwidth of each connection in bytes per second")
+	watermark := flags.String("watermark", "", "The watermark preset forced on the processed images")
+	keyFile := flags.String("key", "", "The PEM private key file for RS/ES/PS, the configured key if empty")
+	if _, err := parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+
+	opts := admin.TokenOptions{
+		Subject:      *subject,
+		Paths:        splitList(*paths),
+		Methods:      splitList(strings.ToUpper(*methods)),
+		ExpiresIn:    *expires,
+		Bucket:       *bucket,
+		RateLimitBps: *rateLimit,
+		Watermark:    *watermark,
+	}
+	if *keyFile != "" {
+		key, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		opts.PrivateKey = string(key)
+	}
+	token, err := admin.IssueToken(opts)
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
+
+// runFsck handles goflet fsck
+func runFsck(args []string) error {
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	repair := flags.Bool("repair", false, "Repair the missing hashes and the misplaced files, and remove the leftovers")
+	quarantine := flags.Bool("quarantine", false, "Move the files which cannot be repaired to the quarantine folder")
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	report := scrub.Run(scrub.Options{Repair: *repair, Quarantine: *quarantine})
+	hasher.Wait() // The repaired hashes are stored before the command exits
+	return printJSON(report)
+}
+
+// runImport handles goflet import
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	prefix := flags.String("prefix", "/", "The path to store the files under")
+	owner := flags.String("owner", "", "The subject owning the files")
+	dryRun := flags.Bool("dry-run", false, "Only report the files to import")
+	parallel := flags.Int("parallel", 4, "The number of the files imported at the same time")
+	link := flags.Bool("link", false, "Hard link the files instead of copying them")
+	resume := flags.Bool("resume", false, "Skip the files imported by the interrupted import")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: goflet import <dir> [--prefix <path>] [--owner <subject>] [options]")
+	}
+
+	result, err := admin.Import(admin.ImportOptions{
+		Dir:      positional[0],
+		Prefix:   *prefix,
+		Owner:    *owner,
+		DryRun:   *dryRun,
+		Parallel: *parallel,
+		Link:     *link,
+		Resume:   *resume,
+	})
+	hasher.Wait()        // The hashes are stored before the command exits
+	image.WaitAnalysis() // So is the image information
+	if err != nil {
+		return err
+	}
+	return printJSON(result)
+}
+
+// runExport handles goflet export
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	asTar := flags.Bool("tar", false, "Write a tar stream to the file instead of a folder, - for the standard output")
+	since := flags.String("since", "", "Only export the files changed after the time, RFC 3339 or unix seconds")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return errors.New("usage: goflet export <prefix> <dir> [--tar] [--since <time>]")
+	}
+
+	opts := admin.ExportOptions{Prefix: positional[0], Dir: positional[1]}
+	if *since != "" {
+		if opts.Since, err = parseTime(*since); err != nil {
+			return err
+		}
+	}
+	output := os.Stdout
+	if *asTar {
+		if positional[1] == "-" {
+			output = os.Stderr // The standard output is taken by the tar stream
+			opts.Tar = os.Stdout
+		} else {
+			file, err := os.Create(positional[1])
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = file.Close()
+			}()
+			opts.Tar = file
+		}
+	}
+
+	result, err := admin.Export(opts)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// parseTime parses the time in RFC 3339 or unix seconds
+func parseTime(s string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// runVerify handles goflet verify
+func runVerify(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: goflet verify <dir>")
+	}
+	result, err := admin.VerifyExport(args[0])
+	if err != nil {
+		return err
+	}
+	if err := printJSON(result); err != nil {
+		return err
+	}
+	if len(result.Problems) > 0 {
+		return errors.New("the export does not match the manifest")
+	}
+	return nil
+}
+
+// runMeta handles goflet meta
+func runMeta(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: goflet meta <path>")
+	}
+	info, err := admin.Meta(args[0])
+	if err != nil {
+		return err
+	}
+	return printJSON(info)
+}
@@ -25,6 +25,114 @@ const (
 	CacheTypeMemory CacheType = "MemoryCache"
 )
 
+// RateLimitRule contains the rate limit rule for a route group
+type RateLimitRule struct {
+	Rate          float64 `json:"rate"`          // The number of requests allowed per second
+	Burst         int     `json:"burst"`         // The maximum number of requests allowed in a burst
+	MaxConcurrent int     `json:"maxConcurrent"` // The maximum number of concurrent requests, if le 0, it will not be limited
+}
+
+// QuotaLimit contains the limit of a storage quota, if the value le 0, it will not be limited
+type QuotaLimit struct {
+	MaxBytes int64 `json:"maxBytes"` // The maximum total size of the files
+	MaxFiles int64 `json:"maxFiles"` // The maximum number of files
+}
+
+// ImageConfig contains the configuration for the image processing
+type ImageConfig struct {
+	DefaultFormat  string   `json:"defaultFormat" default:"png"` // The default format for the image
+	AllowedFormats []string `json:"allowedFormats"`              // The list of allowed formats for the image
+
+	StrictMode   *bool `json:"strictMode" default:"true"`                // If true, the image size will only accept the allowed sizes
+	AllowedSizes []int `json:"allowedSizes" default:"32,64,128,256,512"` // The list of allowed sizes for the image, like 32, 64, 128, 256
+
+	Presets     map[string]ImagePreset `json:"presets"`                     // The named parameters, requested with p=<preset>
+	PresetsOnly *bool                  `json:"presetsOnly" default:"false"` // If true, only the presets are accepted
+
+	MaxWidth    int   `json:"maxWidth" default:"4096"`        // The maximum width of the image
+	MaxHeight   int   `json:"maxHeight" default:"4096"`       // The maximum height of the image
+	MaxFileSize int64 `json:"maxFileSize" default:"20971520"` // The maximum size of the image file
+
+	MaxFrames      int   `json:"maxFrames" default:"300"`            // The maximum number of frames kept, the larger animations keep their first frame only
+	MaxTotalPixels int64 `json:"maxTotalPixels" default:"100000000"` // The maximum pixels of all the frames kept, the larger animations keep their first frame only
+
+	ExposeExif *bool `json:"exposeExif" default:"false"` // If true, /api/image-info includes the camera and the time taken from the EXIF
+
+	MaxBlur    float64 `json:"maxBlur" default:"20"`   // The maximum sigma of the blur effect, negative disables it
+	MaxSharpen float64 `json:"maxSharpen" default:"5"` // The maximum sigma of the sharpen effect, negative disables it
+
+	Watermarks map[string]WatermarkConfig `json:"watermarks"` // The watermark presets, selected with wm=<preset>
+}
+
+// ImagePreset contains the processing parameters of a preset, with the same keys as the query, like w, h, s, f, q
+type ImagePreset map[string]string
+
+// UnmarshalJSON accepts the numbers and the booleans as the values of the preset
+func (p *ImagePreset) UnmarshalJSON(data []byte) error {
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*p = ImagePreset{}
+	for key, value := range values {
+		if number, ok := value.(float64); ok {
+			(*p)[key] = strconv.FormatFloat(number, 'f', -1, 64)
+			continue
+		}
+		(*p)[key] = fmt.Sprint(value)
+	}
+	return nil
+}
+
+// WatermarkConfig contains the configuration of a watermark preset
+type WatermarkConfig struct {
+	Image    string  `json:"image"`    // The relative path of the watermark image stored in goflet
+	Text     string  `json:"text"`     // The text of the watermark, used if the image is not set
+	Font     string  `json:"font"`     // The relative path of the TrueType font stored in goflet, the Go font is used if empty
+	FontSize float64 `json:"fontSize"` // The font size of the text in pixels, 24 if not set
+	Color    string  `json:"color"`    // The color of the text like #ffffff or #ffffff80, white if not set
+	Position string  `json:"position"` // The position of the watermark, same values as the gravity, southeast if not set
+	Opacity  float64 `json:"opacity"`  // The opacity of the watermark from 0 to 1, 0.5 if not set
+	Scale    float64 `json:"scale"`    // The width of the watermark relative to the image width, the original size if not set
+	Margin   int     `json:"margin"`   // The margin to the edges or between the tiles in pixels
+	Tile     bool    `json:"tile"`     // Whether the watermark is repeated over the whole image
+}
+
+// BucketJWTConfig contains the JWT configuration of a bucket
+type BucketJWTConfig struct {
+	Algorithm      string   `json:"algorithm"`      // The algorithm to be used for the JWT
+	SigningKey     string   `json:"signingKey"`     // The signing key for the JWT when the algorithm is HS256/HS384/HS512
+	PublicKey      string   `json:"publicKey"`      // The public key for the JWT when the algorithm is RS/ES/PS
+	TrustedIssuers []string `json:"trustedIssuers"` // The list of trusted issuers for the JWT, if empty, it will trust any issuer
+}
+
+// Bucket contains the isolated configuration of a bucket, the empty values are inherited from the global configuration
+type Bucket struct {
+	BaseFileStoragePath string          `json:"baseFileStoragePath"` // The base path where the files of the bucket will be stored
+	UploadLimit         int64           `json:"uploadLimit"`         // The maximum size of the file to be uploaded
+	MaxPostSize         int64           `json:"maxPostSize"`         // The maximum size of the post request
+	CorsOrigins         []string        `json:"corsOrigins"`         // The list of allowed origins
+	ImageConfig         ImageConfig     `json:"imageConfig"`         // The image configuration
+	JWTConfig           BucketJWTConfig `json:"jwtConfig"`           // The JWT configuration, tokens signed with it can only access the bucket
+}
+
+// WebhookSubscription contains a webhook subscription of the file lifecycle events
+type WebhookSubscription struct {
+	URL    string   `json:"url"`    // The URL to post the events to
+	Secret string   `json:"secret"` // The secret to sign the payloads with HMAC-SHA256
+	Events []string `json:"events"` // The types of the events to post, if empty, all the events will be posted
+	Prefix string   `json:"prefix"` // The path prefix of the files, if empty, the events of all the files will be posted
+}
+
+// S3Credential contains an access key of the S3 API
+type S3Credential struct {
+	AccessKeyID     string   `json:"accessKeyId"`     // The access key ID
+	SecretAccessKey string   `json:"secretAccessKey"` // The secret access key to verify the signatures with
+	Subject         string   `json:"subject"`         // The subject of the operations for the quotas and the events, the access key ID if empty
+	Buckets         []string `json:"buckets"`         // The S3 buckets the key can access, if empty, all the buckets can be accessed
+	ReadOnly        bool     `json:"readOnly"`        // Whether the key can only read the objects
+}
+
 // GofletConfig contains the configuration for the application
 type GofletConfig struct {
 	Debug          *bool `json:"debug" default:"false"`         // Enable debug mode
@@ -74,18 +182,13 @@ type GofletConfig struct {
 			DefaultTTL int `json:"defaultTTL" default:"60"`  // The default time to live for the cache
 		}
 	} `json:"cacheConfig"`
-	ImageConfig struct {
-		// Image configuration
-		DefaultFormat  string   `json:"defaultFormat" default:"png"` // The default format for the image
-		AllowedFormats []string `json:"allowedFormats"`              // The list of allowed formats for the image
-
-		StrictMode   *bool `json:"strictMode" default:"true"`                // If true, the image size will only accept the allowed sizes
-		AllowedSizes []int `json:"allowedSizes" default:"32,64,128,256,512"` // The list of allowed sizes for the image, like 32, 64, 128, 256
-
-		MaxWidth    int   `json:"maxWidth" default:"4096"`        // The maximum width of the image
-		MaxHeight   int   `json:"maxHeight" default:"4096"`       // The maximum height of the image
-		MaxFileSize int64 `json:"maxFileSize" default:"20971520"` // The maximum size of the image file
-	} `json:"imageConfig"`
+	ImageConfig      ImageConfig `json:"imageConfig"` // Image configuration
+	ImageCacheConfig struct {
+		// Image cache configuration, the processed images are cached until they are evicted, the least recently used first
+		Path     string `json:"path"`                          // The folder to store the processed images in, next to the original files if empty
+		MaxBytes int64  `json:"maxBytes" default:"1073741824"` // The maximum total size of the processed images, negative disables the limit
+		MaxFiles int64  `json:"maxFiles" default:"100000"`     // The maximum number of the processed images, negative disables the limit
+	} `json:"imageCacheConfig"`
 	JWTConfig struct {
 		// JWT configuration
 		Enabled   *bool  `json:"enabled" default:"true"`    // Enable JWT
@@ -98,13 +201,100 @@ type GofletConfig struct {
 		}
 		TrustedIssuers []string `json:"trustedIssuers"` // The list of trusted issuers for the JWT, if empty, it will trust any issuer
 	} `json:"jwtConfig"`
+	RateLimitConfig struct {
+		// Rate limit configuration
+		Enabled   *bool                    `json:"enabled" default:"false"`       // Enable rate limiting
+		KeyHeader string                   `json:"keyHeader" default:"X-Api-Key"` // The header that contains the API key, used to identify clients without a JWT subject
+		CacheTTL  int                      `json:"cacheTTL" default:"600"`        // The time to live of the counters stored in the cache, in seconds
+		Rules     map[string]RateLimitRule `json:"rules"`                         // The rules for each route group, like /file, /upload, /api/image, /api/action
+	} `json:"rateLimitConfig"`
+	BandwidthConfig struct {
+		// Bandwidth configuration, in bytes per second, if the value le 0, the bandwidth will not be limited
+		DownloadGlobalBps  int64 `json:"downloadGlobalBps"`  // The bandwidth shared by all downloads
+		DownloadPerConnBps int64 `json:"downloadPerConnBps"` // The bandwidth of each download connection
+		UploadGlobalBps    int64 `json:"uploadGlobalBps"`    // The bandwidth shared by all uploads
+		UploadPerConnBps   int64 `json:"uploadPerConnBps"`   // The bandwidth of each upload connection
+	} `json:"bandwidthConfig"`
+	QuotaConfig struct {
+		// Quota configuration
+		Enabled  *bool                 `json:"enabled" default:"false"` // Enable storage quotas
+		Prefixes map[string]QuotaLimit `json:"prefixes"`                // The quotas of the path prefixes, like /tenant-a
+		Subjects map[string]QuotaLimit `json:"subjects"`                // The quotas of the token subjects, * applies to every subject not listed
+	} `json:"quotaConfig"`
+	BucketConfig struct {
+		// Bucket configuration
+		Enabled *bool             `json:"enabled" default:"false"` // Enable buckets, the first segment of the path will be the bucket name
+		Buckets map[string]Bucket `json:"buckets"`                 // The buckets, the key is the bucket name
+	} `json:"bucketConfig"`
+	AuditConfig struct {
+		// Audit log configuration
+		Enabled    *bool  `json:"enabled" default:"false"`        // Enable the audit log of the mutating operations
+		Path       string `json:"path" default:"audit/audit.log"` // The file to write the audit events to, in JSON lines
+		MaxSize    int64  `json:"maxSize" default:"104857600"`    // The maximum size of the file before it is rotated
+		MaxBackups int    `json:"maxBackups" default:"10"`        // The maximum number of rotated files to keep
+		WebhookURL string `json:"webhookUrl"`                     // The URL to post the audit events to, if empty, the events will not be posted
+	} `json:"auditConfig"`
+	WebhookConfig struct {
+		// Webhook configuration
+		Enabled       *bool                 `json:"enabled" default:"false"`    // Enable the webhooks
+		Timeout       int                   `json:"timeout" default:"10"`       // The timeout of a delivery, in seconds
+		HistorySize   int                   `json:"historySize" default:"1000"` // The number of the latest deliveries to keep for querying
+		Subscriptions []WebhookSubscription `json:"subscriptions"`              // The webhook subscriptions
+	} `json:"webhookConfig"`
+	EventStreamConfig struct {
+		// Event stream configuration
+		Enabled           *bool `json:"enabled" default:"false"`        // Enable the server-sent events of the file changes
+		BufferSize        int   `json:"bufferSize" default:"1000"`      // The number of the latest events to keep for resuming with Last-Event-ID
+		HeartbeatInterval int   `json:"heartbeatInterval" default:"30"` // The interval to send the heartbeat comments, in seconds, 0 or less disables it
+	} `json:"eventStreamConfig"`
+	WebDAVConfig struct {
+		// WebDAV configuration
+		Enabled *bool `json:"enabled" default:"false"` // Enable the WebDAV endpoint at /dav/
+	} `json:"webdavConfig"`
+	S3Config struct {
+		// S3 API configuration, the S3 API is served by its own listener with the path-style requests
+		Enabled     *bool             `json:"enabled" default:"false"`    // Enable the S3 API
+		Host        string            `json:"host" default:"0.0.0.0"`     // The host to bind the S3 API
+		Port        int               `json:"port" default:"9000"`        // The port to bind the S3 API
+		Region      string            `json:"region" default:"us-east-1"` // The region the requests should be signed for
+		Buckets     map[string]string `json:"buckets"`                    // The S3 buckets, mapped to the path prefixes
+		Credentials []S3Credential    `json:"credentials"`                // The access keys
+	} `json:"s3Config"`
+	GRPCConfig struct {
+		// gRPC API configuration, the gRPC API is served by its own listener
+		Enabled *bool  `json:"enabled" default:"false"` // Enable the gRPC API
+		Host    string `json:"host" default:"0.0.0.0"`  // The host to bind the gRPC API
+		Port    int    `json:"port" default:"9090"`     // The port to bind the gRPC API
+	} `json:"grpcConfig"`
+	AdminConfig struct {
+		// Admin API configuration
+		ImportRoots []string `json:"importRoots"` // The local folders that the files can be imported from, the import API is disabled if empty
+	} `json:"adminConfig"`
+	ScrubConfig struct {
+		// Scrub configuration, the scrub verifies the stored files against their hashes
+		Repair         *bool  `json:"repair" default:"false"`              // Repair the missing hashes and the misplaced files, and remove the leftovers
+		Quarantine     *bool  `json:"quarantine" default:"false"`          // Move the files which cannot be repaired to the quarantine folder
+		QuarantinePath string `json:"quarantinePath" default:"quarantine"` // The folder of the quarantined files
+	} `json:"scrubConfig"`
 	CronConfig struct {
 		// Cron configuration, if the value le 0, the cron job will be disabled
 		DeleteEmptyFolder int `json:"deleteEmptyFolder" default:"3600"` // The interval to delete empty folders, in seconds
 		CleanOutdatedFile int `json:"cleanOutdatedFile" default:"3600"` // The interval to clean outdated files, in seconds
+		ScrubFiles        int `json:"scrubFiles" default:"0"`           // The interval to scrub the stored files, in seconds
+		CleanImageCache   int `json:"cleanImageCache" default:"3600"`   // The interval to evict the processed images over the budget, in seconds
 	} `json:"cronConfig"`
 }
 
+// GetS3Endpoint returns the endpoint for the S3 API
+func (c *GofletConfig) GetS3Endpoint() string {
+	return c.S3Config.Host + ":" + strconv.Itoa(c.S3Config.Port)
+}
+
+// GetGRPCEndpoint returns the endpoint for the gRPC API
+func (c *GofletConfig) GetGRPCEndpoint() string {
+	return c.GRPCConfig.Host + ":" + strconv.Itoa(c.GRPCConfig.Port)
+}
+
 // GetEndpoint returns the endpoint for the HTTP/S server
 func (c *GofletConfig) GetEndpoint() string {
 	porti := c.HTTPConfig.Port
@@ -152,6 +342,9 @@ func InitConfig() {
 	// Set the default values
 	confutil.SetDefaults(&GofletCfg)
 
+	// Inherit the bucket configuration from the global configuration
+	InitBuckets()
+
 	// Set the default value for the cache type
 	if !*GofletCfg.JWTConfig.Enabled {
 		fmt.Println("[WARN] JWT is disabled, the security of the application is not guaranteed.")
@@ -164,6 +357,98 @@ func InitConfig() {
 	}
 }
 
+// InitBuckets fills the empty values of the buckets with the global configuration
+func InitBuckets() {
+	for name, bucket := range GofletCfg.BucketConfig.Buckets {
+		if bucket.UploadLimit <= 0 {
+			bucket.UploadLimit = GofletCfg.FileConfig.UploadLimit
+		}
+		if bucket.MaxPostSize <= 0 {
+			bucket.MaxPostSize = GofletCfg.FileConfig.MaxPostSize
+		}
+		if len(bucket.CorsOrigins) == 0 {
+			bucket.CorsOrigins = GofletCfg.HTTPConfig.Cors.Origins
+		}
+		bucket.ImageConfig = inheritImageConfig(bucket.ImageConfig, GofletCfg.ImageConfig)
+
+		jwtConfig := &bucket.JWTConfig
+		if jwtConfig.Algorithm == "" {
+			jwtConfig.Algorithm = GofletCfg.JWTConfig.Algorithm
+		}
+		if jwtConfig.SigningKey == "" && jwtConfig.PublicKey == "" {
+			jwtConfig.SigningKey = GofletCfg.JWTConfig.Security.SigningKey
+			jwtConfig.PublicKey = GofletCfg.JWTConfig.Security.PublicKey
+		}
+		if len(jwtConfig.TrustedIssuers) == 0 {
+			jwtConfig.TrustedIssuers = GofletCfg.JWTConfig.TrustedIssuers
+		}
+
+		GofletCfg.BucketConfig.Buckets[name] = bucket
+	}
+}
+
+// inheritImageConfig fills the empty values of the image configuration with the parent configuration
+func inheritImageConfig(conf ImageConfig, parent ImageConfig) ImageConfig {
+	if conf.DefaultFormat == "" {
+		conf.DefaultFormat = parent.DefaultFormat
+	}
+	if len(conf.AllowedFormats) == 0 {
+		conf.AllowedFormats = parent.AllowedFormats
+	}
+	if conf.StrictMode == nil {
+		conf.StrictMode = parent.StrictMode
+	}
+	if len(conf.AllowedSizes) == 0 {
+		conf.AllowedSizes = parent.AllowedSizes
+	}
+	if len(conf.Presets) == 0 {
+		conf.Presets = parent.Presets
+	}
+	if conf.PresetsOnly == nil {
+		conf.PresetsOnly = parent.PresetsOnly
+	}
+	if conf.MaxWidth <= 0 {
+		conf.MaxWidth = parent.MaxWidth
+	}
+	if conf.MaxHeight <= 0 {
+		conf.MaxHeight = parent.MaxHeight
+	}
+	if conf.MaxFileSize <= 0 {
+		conf.MaxFileSize = parent.MaxFileSize
+	}
+	if conf.MaxFrames <= 0 {
+		conf.MaxFrames = parent.MaxFrames
+	}
+	if conf.MaxTotalPixels <= 0 {
+		conf.MaxTotalPixels = parent.MaxTotalPixels
+	}
+	if conf.ExposeExif == nil {
+		conf.ExposeExif = parent.ExposeExif
+	}
+	if conf.MaxBlur == 0 {
+		conf.MaxBlur = parent.MaxBlur
+	}
+	if conf.MaxSharpen == 0 {
+		conf.MaxSharpen = parent.MaxSharpen
+	}
+	if len(conf.Watermarks) == 0 {
+		conf.Watermarks = parent.Watermarks
+	}
+	return conf
+}
+
+// GetBucket returns the configuration of the bucket, false if the buckets are disabled or the bucket does not exist
+func (c *GofletConfig) GetBucket(name string) (*Bucket, bool) {
+	if !*c.BucketConfig.Enabled || name == "" {
+		return nil, false
+	}
+	bucket, ok := c.BucketConfig.Buckets[name]
+	if !ok {
+		return nil, false
+	}
+	return &bucket, true
+}
+
 // loadConfig loads the configuration from the file
 func loadConfig() error {
 	// Load the configuration from the file
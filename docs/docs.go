@@ -82,6 +82,12 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "507": {
+                        "description": "Quota exceeded",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
@@ -139,6 +145,12 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "507": {
+                        "description": "Quota exceeded",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
@@ -202,89 +214,49 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "507": {
+                        "description": "Quota exceeded",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
-        "/api/image/{path}": {
-            "get": {
+        "/api/admin/fsck": {
+            "post": {
                 "security": [
                     {
                         "Authorization": []
                     }
                 ],
-                "description": "Get processed image, {path} should be the relative path of the file, starting from the root directory, e.g. /image/path/to/image.jpg",
+                "description": "Verify the stored files against their hashes, find the misplaced files and the leftovers of the uploads and the image caches. The problems are fixed as the request allows.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
-                    "image/jpeg",
-                    " image/png",
-                    " image/gif"
+                    "application/json"
                 ],
                 "tags": [
-                    "Image"
+                    "Admin"
                 ],
-                "summary": "Get Image",
+                "summary": "Check Storage",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "File path",
-                        "name": "path",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Width",
-                        "name": "w",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Height",
-                        "name": "h",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Quality, 0-100",
-                        "name": "q",
-                        "in": "query"
-                    },
-                    {
-                        "enum": [
-                            "jpg",
-                            "png",
-                            "gif"
-                        ],
-                        "type": "string",
-                        "description": "Format",
-                        "name": "f",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Angle, 0-360",
-                        "name": "a",
-                        "in": "query"
-                    },
-                    {
-                        "enum": [
-                            "fit",
-                            "fill",
-                            "resize",
-                            "fit_width",
-                            "fit_height"
-                        ],
-                        "type": "string",
-                        "description": "Scale type",
-                        "name": "s",
-                        "in": "query"
+                        "description": "Request body",
+                        "name": "body",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/admin.FsckRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/scrub.Report"
                         }
                     },
                     "400": {
@@ -293,20 +265,8 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "404": {
-                        "description": "File not found",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "413": {
-                        "description": "File too large",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal server error",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "string"
                         }
@@ -314,51 +274,61 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/meta/{path}": {
+        "/api/admin/image-cache": {
             "get": {
                 "security": [
                     {
                         "Authorization": []
                     }
                 ],
-                "description": "Get the file meta data, {path} should be the relative path of the file, starting from the root directory, e.g. /meta/path/to/file.txt",
+                "description": "Get the usage, the budget and the hits of the processed image cache",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "File"
-                ],
-                "summary": "Get File Meta",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "File path",
-                        "name": "path",
-                        "in": "path",
-                        "required": true
-                    }
+                    "Admin"
                 ],
+                "summary": "Get Image Cache Stats",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.FileInfo"
+                            "$ref": "#/definitions/image.ImageCacheStats"
                         }
                     },
-                    "400": {
-                        "description": "Bad request",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "string"
                         }
-                    },
-                    "404": {
-                        "description": "File not found",
+                    }
+                }
+            }
+        },
+        "/api/admin/image-cache/clean": {
+            "post": {
+                "security": [
+                    {
+                        "Authorization": []
+                    }
+                ],
+                "description": "Count the processed images and evict the least recently used ones over the budget now, instead of waiting for the scheduled cleanup",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Clean Image Cache",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/image.ImageCacheStats"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "string"
                         }
@@ -366,14 +336,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/onlyoffice/{path}": {
+        "/api/admin/import": {
             "post": {
                 "security": [
                     {
                         "Authorization": []
                     }
                 ],
-                "description": "OnlyOffice callback for file updates, when the status is 2, the file has been updated, {path} should be the relative path of the file, starting from the root directory, e.g. /onlyoffice/path/to/file.txt",
+                "description": "Import the files of a local folder on the server, the folder should be under one of the configured import roots. The files are checked against the quotas like the uploads, the files exceeding them are reported as quota_exceeded in failed. The import returns when the files are stored, the hashes are computed in the background.",
                 "consumes": [
                     "application/json"
                 ],
@@ -381,24 +351,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "OnlyOffice"
+                    "Admin"
                 ],
-                "summary": "OnlyOffice Callback",
+                "summary": "Import Folder",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "File path",
-                        "name": "path",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "OnlyOffice update request",
+                        "description": "Request body",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/onlyoffice.onlyOfficeUpdateRequest"
+                            "$ref": "#/definitions/admin.ImportRequest"
                         }
                     }
                 ],
@@ -406,7 +369,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/admin.ImportResult"
                         }
                     },
                     "400": {
@@ -415,14 +378,20 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "404": {
-                        "description": "File not found",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "403": {
+                        "description": "Folder not allowed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Folder not found",
                         "schema": {
                             "type": "string"
                         }
@@ -430,98 +399,60 @@ const docTemplate = `{
                 }
             }
         },
-        "/file/{path}": {
+        "/api/events": {
             "get": {
                 "security": [
                     {
                         "Authorization": []
                     }
                 ],
-                "description": "Download a file by path, supports range requests, {path} should be the relative path of the file, starting from the root directory, e.g. /file/path/to/file.txt",
+                "description": "Stream the changes of the files as server-sent events, only the events of the files the token can read via GET /file/{path} are sent. The id of each event can be sent back with the Last-Event-ID header or the lastEventId query to resume the stream",
                 "produces": [
-                    "application/octet-stream"
+                    "text/event-stream"
                 ],
                 "tags": [
-                    "File"
+                    "Event"
                 ],
-                "summary": "File Download",
+                "summary": "Event Stream",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "File path",
-                        "name": "path",
-                        "in": "path",
-                        "required": true
+                        "description": "Path prefix of the files, e.g. /projects/x",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of the last event received, same as the Last-Event-ID header",
+                        "name": "lastEventId",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "string"
-                        },
-                        "headers": {
-                            "Cache-Control": {
-                                "type": "string",
-                                "description": "max-age=3600"
-                            },
-                            "Content-Disposition": {
-                                "type": "string",
-                                "description": "attachment; filename=file.txt"
-                            },
-                            "Content-Length": {
-                                "type": "string",
-                                "description": "1024"
-                            },
-                            "Content-Type": {
-                                "type": "string",
-                                "description": "application/octet-stream"
-                            },
-                            "ETag": {
-                                "type": "string",
-                                "description": "686897696a7c876b7e"
-                            },
-                            "Last-Modified": {
-                                "type": "string",
-                                "description": "Mon, 02 Jan 2006 15:04:05 GMT"
-                            }
-                        }
-                    },
-                    "400": {
-                        "description": "Bad request",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "404": {
-                        "description": "File not found",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal server error",
-                        "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/event.Event"
                         }
                     }
                 }
-            },
-            "post": {
+            }
+        },
+        "/api/image-info/{path}": {
+            "get": {
                 "security": [
                     {
                         "Authorization": []
                     }
                 ],
-                "description": "Upload a small file using a POST request, {path} should be the relative path of the file, starting from the root directory, e.g. /file/path/to/file.txt",
-                "consumes": [
-                    "multipart/form-data"
+                "description": "Get the size of the image as displayed, its format, number of frames, dominant colors, BlurHash and EXIF information, {path} should be the relative path of the file, starting from the root directory, e.g. /image-info/path/to/image.jpg",
+                "produces": [
+                    "application/json"
                 ],
                 "tags": [
-                    "File",
-                    "Upload"
+                    "Image"
                 ],
-                "summary": "Upload Small File",
+                "summary": "Get Image Info",
                 "parameters": [
                     {
                         "type": "string",
@@ -529,20 +460,13 @@ const docTemplate = `{
                         "name": "path",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "type": "file",
-                        "description": "File",
-                        "name": "file",
-                        "in": "formData",
-                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/image.ImageInfo"
                         }
                     },
                     "400": {
@@ -552,19 +476,13 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "File not found or upload not started",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "409": {
-                        "description": "File completion in progress",
+                        "description": "File not found",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "413": {
-                        "description": "File too large, please use PUT method to upload large files",
+                    "415": {
+                        "description": "Unsupported image format",
                         "schema": {
                             "type": "string"
                         }
@@ -576,18 +494,27 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "security": [
+            }
+        },
+        "/api/image/{path}": {
+            "get": {
+                "security": [
                     {
                         "Authorization": []
                     }
                 ],
-                "description": "Delete a file by path, {path} should be the relative path of the file, starting from the root directory, e.g. /file/path/to/file.txt",
+                "description": "Get processed image, {path} should be the relative path of the file, starting from the root directory, e.g. /image/path/to/image.jpg",
+                "produces": [
+                    "image/jpeg",
+                    " image/png",
+                    " image/gif",
+                    " image/webp",
+                    " image/avif"
+                ],
                 "tags": [
-                    "File"
+                    "Image"
                 ],
-                "summary": "Delete File",
+                "summary": "Get Image",
                 "parameters": [
                     {
                         "type": "string",
@@ -595,11 +522,140 @@ const docTemplate = `{
                         "name": "path",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Preset, the other parameters are ignored if set",
+                        "name": "p",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width",
+                        "name": "w",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Height",
+                        "name": "h",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Quality, 0-100, 100 means lossless for webp and avif",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "jpeg",
+                            "png",
+                            "gif",
+                            "webp",
+                            "avif",
+                            "auto"
+                        ],
+                        "type": "string",
+                        "description": "Format, auto negotiates from the Accept header",
+                        "name": "f",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Angle, 0-360",
+                        "name": "a",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "fit",
+                            "fill",
+                            "resize",
+                            "fit_width",
+                            "fit_height"
+                        ],
+                        "type": "string",
+                        "description": "Scale type",
+                        "name": "s",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Crop area of the source image, x,y,w,h",
+                        "name": "c",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "center",
+                            "north",
+                            "south",
+                            "east",
+                            "west",
+                            "northeast",
+                            "northwest",
+                            "southeast",
+                            "southwest",
+                            "face"
+                        ],
+                        "type": "string",
+                        "description": "Gravity of the fill crop, face keeps the focal point of the file",
+                        "name": "g",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Sigma of the gaussian blur, limited by maxBlur",
+                        "name": "blur",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Sigma of the sharpening, limited by maxSharpen",
+                        "name": "sharpen",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Convert to grayscale",
+                        "name": "grayscale",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Brightness change in percent, -100-100",
+                        "name": "brightness",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Contrast change in percent, -100-100",
+                        "name": "contrast",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Flip horizontally",
+                        "name": "flipH",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Flip vertically",
+                        "name": "flipV",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Watermark preset, the preset in the token is always used",
+                        "name": "wm",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "Deleted",
+                    "200": {
+                        "description": "OK",
                         "schema": {
                             "type": "string"
                         }
@@ -611,7 +667,19 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "File not found or upload not started",
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "File too large",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported image format",
                         "schema": {
                             "type": "string"
                         }
@@ -623,25 +691,27 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "head": {
+            }
+        },
+        "/api/list/{path}": {
+            "get": {
                 "security": [
                     {
                         "Authorization": []
                     }
                 ],
-                "description": "Download a file by path, supports range requests, {path} should be the relative path of the file, starting from the root directory, e.g. /file/path/to/file.txt",
+                "description": "List the files and the folders in a folder sorted by name, {path} should be the relative path of the folder, starting from the root directory, e.g. /list/path/to/folder, /list/ lists the root",
                 "produces": [
-                    "application/octet-stream"
+                    "application/json"
                 ],
                 "tags": [
                     "File"
                 ],
-                "summary": "File Download",
+                "summary": "List Folder",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "File path",
+                        "description": "Folder path",
                         "name": "path",
                         "in": "path",
                         "required": true
@@ -651,32 +721,9 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "string"
-                        },
-                        "headers": {
-                            "Cache-Control": {
-                                "type": "string",
-                                "description": "max-age=3600"
-                            },
-                            "Content-Disposition": {
-                                "type": "string",
-                                "description": "attachment; filename=file.txt"
-                            },
-                            "Content-Length": {
-                                "type": "string",
-                                "description": "1024"
-                            },
-                            "Content-Type": {
-                                "type": "string",
-                                "description": "application/octet-stream"
-                            },
-                            "ETag": {
-                                "type": "string",
-                                "description": "686897696a7c876b7e"
-                            },
-                            "Last-Modified": {
-                                "type": "string",
-                                "description": "Mon, 02 Jan 2006 15:04:05 GMT"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/index.Entry"
                             }
                         }
                     },
@@ -686,14 +733,14 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "404": {
-                        "description": "File not found",
+                    "401": {
+                        "description": "Unauthorized access",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "404": {
+                        "description": "Folder not found",
                         "schema": {
                             "type": "string"
                         }
@@ -701,21 +748,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/upload/{path}": {
-            "put": {
+        "/api/meta/{path}": {
+            "get": {
                 "security": [
                     {
                         "Authorization": []
                     }
                 ],
-                "description": "Create an upload session with a partial file upload, supports range requests, {path} should be the relative path of the file, starting from the root directory, e.g. /file/path/to/file.txt",
-                "consumes": [
-                    "*/*"
+                "description": "Get the file meta data, {path} should be the relative path of the file, starting from the root directory, e.g. /meta/path/to/file.txt",
+                "produces": [
+                    "application/json"
                 ],
                 "tags": [
-                    "Upload"
+                    "File"
                 ],
-                "summary": "Partial File Upload",
+                "summary": "Get File Meta",
                 "parameters": [
                     {
                         "type": "string",
@@ -726,10 +773,10 @@ const docTemplate = `{
                     }
                 ],
                 "responses": {
-                    "202": {
-                        "description": "Accepted",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/model.FileInfo"
                         }
                     },
                     "400": {
@@ -738,14 +785,8 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "403": {
-                        "description": "Directory creation not allowed",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "413": {
-                        "description": "File too large",
+                    "404": {
+                        "description": "File not found",
                         "schema": {
                             "type": "string"
                         }
@@ -758,17 +799,23 @@ const docTemplate = `{
                     }
                 }
             },
-            "post": {
+            "put": {
                 "security": [
                     {
                         "Authorization": []
                     }
                 ],
-                "description": "Complete an upload session with a partial file upload. You should first upload the file with a PUT request, then complete the upload with a POST request, {path} should be the relative path of the file, starting from the root directory, e.g. /file/path/to/file.txt",
+                "description": "Update the editable file meta data, {path} should be the relative path of the file, starting from the root directory, e.g. /meta/path/to/file.txt",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "Upload"
+                    "File"
                 ],
-                "summary": "Complete Partial File Upload",
+                "summary": "Update File Meta",
                 "parameters": [
                     {
                         "type": "string",
@@ -776,13 +823,22 @@ const docTemplate = `{
                         "name": "path",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Request body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/meta.UpdateFileMetaRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/model.FileInfo"
                         }
                     },
                     "400": {
@@ -792,13 +848,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "File not found or upload not started",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "409": {
-                        "description": "File completion in progress",
+                        "description": "File not found",
                         "schema": {
                             "type": "string"
                         }
@@ -810,18 +860,26 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/api/onlyoffice/{path}": {
+            "post": {
                 "security": [
                     {
                         "Authorization": []
                     }
                 ],
-                "description": "Cancel an upload session, {path} should be the relative path of the file, starting from the root directory, e.g. /upload/path/to/file.txt",
+                "description": "OnlyOffice callback for file updates, when the status is 2, the file has been updated, {path} should be the relative path of the file, starting from the root directory, e.g. /onlyoffice/path/to/file.txt",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "Upload"
+                    "OnlyOffice"
                 ],
-                "summary": "Cancel Upload",
+                "summary": "OnlyOffice Callback",
                 "parameters": [
                     {
                         "type": "string",
@@ -829,11 +887,20 @@ const docTemplate = `{
                         "name": "path",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "OnlyOffice update request",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/onlyoffice.onlyOfficeUpdateRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "Deleted",
+                    "200": {
+                        "description": "OK",
                         "schema": {
                             "type": "string"
                         }
@@ -845,7 +912,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Upload session not found",
+                        "description": "File not found",
                         "schema": {
                             "type": "string"
                         }
@@ -855,61 +922,971 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
-                    }
-                }
+                    },
+                    "507": {
+                        "description": "Quota exceeded",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/quota/{prefix}": {
+            "get": {
+                "security": [
+                    {
+                        "Authorization": []
+                    }
+                ],
+                "description": "Get the limit and the current usage of the quota of a path prefix, {prefix} should be the configured prefix, e.g. /quota/tenant-a, the quota of the token subject is also returned if there is one",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Quota"
+                ],
+                "summary": "Get Quota",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path prefix",
+                        "name": "prefix",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/quota.Report"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Quota not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhook/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "Authorization": []
+                    }
+                ],
+                "description": "Get the latest webhook delivery attempts, the latest first, the tokens bound to a bucket are not allowed",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhook"
+                ],
+                "summary": "Get Webhook Deliveries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Event type, e.g. file.uploaded",
+                        "name": "event",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Delivery ID",
+                        "name": "id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of the deliveries, default 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/webhook.Delivery"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/file/{path}": {
+            "get": {
+                "security": [
+                    {
+                        "Authorization": []
+                    }
+                ],
+                "description": "Download a file by path, supports range requests, {path} should be the relative path of the file, starting from the root directory, e.g. /file/path/to/file.txt",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "File"
+                ],
+                "summary": "File Download",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Serve the JPEG image without the EXIF, XMP and IPTC metadata, the EXIF orientation is applied, range requests are not supported",
+                        "name": "stripExif",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "headers": {
+                            "Cache-Control": {
+                                "type": "string",
+                                "description": "max-age=3600"
+                            },
+                            "Content-Disposition": {
+                                "type": "string",
+                                "description": "attachment; filename=file.txt"
+                            },
+                            "Content-Length": {
+                                "type": "string",
+                                "description": "1024"
+                            },
+                            "Content-Type": {
+                                "type": "string",
+                                "description": "application/octet-stream"
+                            },
+                            "ETag": {
+                                "type": "string",
+                                "description": "686897696a7c876b7e"
+                            },
+                            "Last-Modified": {
+                                "type": "string",
+                                "description": "Mon, 02 Jan 2006 15:04:05 GMT"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "Image too large to be stripped",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Only JPEG images can be stripped",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "Authorization": []
+                    }
+                ],
+                "description": "Upload a small file using a POST request, {path} should be the relative path of the file, starting from the root directory, e.g. /file/path/to/file.txt",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "tags": [
+                    "File",
+                    "Upload"
+                ],
+                "summary": "Upload Small File",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "File",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found or upload not started",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "File completion in progress",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "File too large, please use PUT method to upload large files",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "507": {
+                        "description": "Quota exceeded",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "Authorization": []
+                    }
+                ],
+                "description": "Delete a file by path, {path} should be the relative path of the file, starting from the root directory, e.g. /file/path/to/file.txt",
+                "tags": [
+                    "File"
+                ],
+                "summary": "Delete File",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Deleted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found or upload not started",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "head": {
+                "security": [
+                    {
+                        "Authorization": []
+                    }
+                ],
+                "description": "Download a file by path, supports range requests, {path} should be the relative path of the file, starting from the root directory, e.g. /file/path/to/file.txt",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "File"
+                ],
+                "summary": "File Download",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Serve the JPEG image without the EXIF, XMP and IPTC metadata, the EXIF orientation is applied, range requests are not supported",
+                        "name": "stripExif",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "headers": {
+                            "Cache-Control": {
+                                "type": "string",
+                                "description": "max-age=3600"
+                            },
+                            "Content-Disposition": {
+                                "type": "string",
+                                "description": "attachment; filename=file.txt"
+                            },
+                            "Content-Length": {
+                                "type": "string",
+                                "description": "1024"
+                            },
+                            "Content-Type": {
+                                "type": "string",
+                                "description": "application/octet-stream"
+                            },
+                            "ETag": {
+                                "type": "string",
+                                "description": "686897696a7c876b7e"
+                            },
+                            "Last-Modified": {
+                                "type": "string",
+                                "description": "Mon, 02 Jan 2006 15:04:05 GMT"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "Image too large to be stripped",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Only JPEG images can be stripped",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/upload/{path}": {
+            "put": {
+                "security": [
+                    {
+                        "Authorization": []
+                    }
+                ],
+                "description": "Create an upload session with a partial file upload, supports range requests, {path} should be the relative path of the file, starting from the root directory, e.g. /file/path/to/file.txt",
+                "consumes": [
+                    "*/*"
+                ],
+                "tags": [
+                    "Upload"
+                ],
+                "summary": "Partial File Upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Directory creation not allowed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "File too large",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "507": {
+                        "description": "Quota exceeded",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "Authorization": []
+                    }
+                ],
+                "description": "Complete an upload session with a partial file upload. You should first upload the file with a PUT request, then complete the upload with a POST request, {path} should be the relative path of the file, starting from the root directory, e.g. /file/path/to/file.txt",
+                "tags": [
+                    "Upload"
+                ],
+                "summary": "Complete Partial File Upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found or upload not started",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "File completion in progress",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "507": {
+                        "description": "Quota exceeded",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "Authorization": []
+                    }
+                ],
+                "description": "Cancel an upload session, {path} should be the relative path of the file, starting from the root directory, e.g. /upload/path/to/file.txt",
+                "tags": [
+                    "Upload"
+                ],
+                "summary": "Cancel Upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Deleted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Upload session not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "action.CopyMoveFileRequest": {
+            "type": "object",
+            "required": [
+                "onConflict",
+                "sourcePath",
+                "targetPath"
+            ],
+            "properties": {
+                "onConflict": {
+                    "description": "OnConflict is the action to take when the file already exists",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/action.OnConflictAction"
+                        }
+                    ]
+                },
+                "sourcePath": {
+                    "description": "SourcePath is the path of the file to copy",
+                    "type": "string"
+                },
+                "targetPath": {
+                    "description": "TargetPath is the path where the file will be copied",
+                    "type": "string"
+                }
+            }
+        },
+        "action.CreateFileRequest": {
+            "type": "object",
+            "required": [
+                "path"
+            ],
+            "properties": {
+                "path": {
+                    "description": "Path is the path where the file will be created",
+                    "type": "string"
+                }
+            }
+        },
+        "action.OnConflictAction": {
+            "type": "string",
+            "enum": [
+                "overwrite",
+                "abort"
+            ],
+            "x-enum-varnames": [
+                "OnConflictActionOverwrite",
+                "OnConflictActionAbort"
+            ]
+        },
+        "admin.FsckRequest": {
+            "type": "object",
+            "properties": {
+                "quarantine": {
+                    "description": "Quarantine moves the files which cannot be repaired to the quarantine folder",
+                    "type": "boolean"
+                },
+                "repair": {
+                    "description": "Repair repairs the missing hashes and the misplaced files, and removes the leftovers",
+                    "type": "boolean"
+                }
+            }
+        },
+        "admin.ImportRequest": {
+            "type": "object",
+            "required": [
+                "source"
+            ],
+            "properties": {
+                "dryRun": {
+                    "description": "DryRun only reports the files to import",
+                    "type": "boolean"
+                },
+                "link": {
+                    "description": "Link hard links the files instead of copying them",
+                    "type": "boolean"
+                },
+                "owner": {
+                    "description": "Owner is the subject owning the files, the subject of the token if empty",
+                    "type": "string"
+                },
+                "parallel": {
+                    "description": "Parallel is the number of the files imported at the same time",
+                    "type": "integer"
+                },
+                "prefix": {
+                    "description": "Prefix is the path to store the files under",
+                    "type": "string"
+                },
+                "resume": {
+                    "description": "Resume skips the files imported by the interrupted import of the same source and prefix",
+                    "type": "boolean"
+                },
+                "source": {
+                    "description": "Source is the local folder on the server to import, should be under one of the configured import roots",
+                    "type": "string"
+                }
+            }
+        },
+        "admin.ImportResult": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "description": "The total size of the imported files",
+                    "type": "integer"
+                },
+                "failed": {
+                    "description": "The errors of the files failed to import, by their local paths",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "files": {
+                    "description": "The number of the imported files",
+                    "type": "integer"
+                },
+                "skipped": {
+                    "description": "The number of the files skipped as imported before",
+                    "type": "integer"
+                }
+            }
+        },
+        "config.QuotaLimit": {
+            "type": "object",
+            "properties": {
+                "maxBytes": {
+                    "description": "The maximum total size of the files",
+                    "type": "integer"
+                },
+                "maxFiles": {
+                    "description": "The maximum number of files",
+                    "type": "integer"
+                }
             }
-        }
-    },
-    "definitions": {
-        "action.CopyMoveFileRequest": {
+        },
+        "event.Actor": {
             "type": "object",
-            "required": [
-                "onConflict",
-                "sourcePath",
-                "targetPath"
-            ],
             "properties": {
-                "onConflict": {
-                    "description": "OnConflict is the action to take when the file already exists",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/action.OnConflictAction"
-                        }
-                    ]
+                "ip": {
+                    "description": "The IP of the client",
+                    "type": "string"
                 },
-                "sourcePath": {
-                    "description": "SourcePath is the path of the file to copy",
+                "iss": {
+                    "description": "The issuer of the token",
                     "type": "string"
                 },
-                "targetPath": {
-                    "description": "TargetPath is the path where the file will be copied",
+                "jti": {
+                    "description": "The ID of the token",
+                    "type": "string"
+                },
+                "sub": {
+                    "description": "The subject of the token",
                     "type": "string"
                 }
             }
         },
-        "action.CreateFileRequest": {
+        "event.Event": {
             "type": "object",
-            "required": [
-                "path"
-            ],
             "properties": {
+                "actor": {
+                    "description": "The client who caused the event",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/event.Actor"
+                        }
+                    ]
+                },
+                "id": {
+                    "description": "The ID of the event, increasing in the process",
+                    "type": "integer"
+                },
                 "path": {
-                    "description": "Path is the path where the file will be created",
+                    "description": "The relative path of the file",
+                    "type": "string"
+                },
+                "sha256": {
+                    "description": "The sha256 of the file, empty if it has not been computed yet",
+                    "type": "string"
+                },
+                "size": {
+                    "description": "The size of the file",
+                    "type": "integer"
+                },
+                "sourcePath": {
+                    "description": "The relative path of the source file, only for move and copy",
                     "type": "string"
+                },
+                "time": {
+                    "description": "The time of the event",
+                    "type": "integer"
+                },
+                "type": {
+                    "description": "The type of the event",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/event.Type"
+                        }
+                    ]
                 }
             }
         },
-        "action.OnConflictAction": {
+        "event.Type": {
             "type": "string",
             "enum": [
-                "overwrite",
-                "abort"
+                "file.uploaded",
+                "file.hashed",
+                "file.deleted",
+                "file.moved",
+                "file.copied",
+                "file.created",
+                "onlyoffice.saved"
             ],
             "x-enum-varnames": [
-                "OnConflictActionOverwrite",
-                "OnConflictActionAbort"
+                "TypeFileUploaded",
+                "TypeFileHashed",
+                "TypeFileDeleted",
+                "TypeFileMoved",
+                "TypeFileCopied",
+                "TypeFileCreated",
+                "TypeOnlyOfficeSaved"
             ]
         },
+        "image.ExifInfo": {
+            "type": "object",
+            "properties": {
+                "dateTaken": {
+                    "description": "The time the photo was taken, in the local time of the camera",
+                    "type": "string"
+                },
+                "exposureTime": {
+                    "description": "The exposure time in seconds, like 1/125",
+                    "type": "string"
+                },
+                "fNumber": {
+                    "description": "The f-number of the aperture",
+                    "type": "number"
+                },
+                "focalLength": {
+                    "description": "The focal length in millimeters",
+                    "type": "number"
+                },
+                "iso": {
+                    "description": "The ISO speed",
+                    "type": "integer"
+                },
+                "lensModel": {
+                    "description": "The model of the lens",
+                    "type": "string"
+                },
+                "make": {
+                    "description": "The maker of the camera",
+                    "type": "string"
+                },
+                "model": {
+                    "description": "The model of the camera",
+                    "type": "string"
+                },
+                "orientation": {
+                    "description": "The EXIF orientation, from 1 to 8",
+                    "type": "integer"
+                }
+            }
+        },
+        "image.ImageCacheStats": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "description": "The total size of the cached images, counted by the last cleanup and updated since",
+                    "type": "integer"
+                },
+                "evictions": {
+                    "description": "The number of the images evicted over the budget since the start",
+                    "type": "integer"
+                },
+                "files": {
+                    "description": "The number of the cached images, counted by the last cleanup and updated since",
+                    "type": "integer"
+                },
+                "hits": {
+                    "description": "The number of the requests served from the cache since the start",
+                    "type": "integer"
+                },
+                "lastCleanup": {
+                    "description": "The time of the last cleanup, 0 if not run yet",
+                    "type": "integer"
+                },
+                "maxBytes": {
+                    "description": "The maximum total size of the cached images, negative if not limited",
+                    "type": "integer"
+                },
+                "maxFiles": {
+                    "description": "The maximum number of the cached images, negative if not limited",
+                    "type": "integer"
+                },
+                "misses": {
+                    "description": "The number of the requests processing the image since the start",
+                    "type": "integer"
+                }
+            }
+        },
+        "image.ImageInfo": {
+            "type": "object",
+            "properties": {
+                "blurHash": {
+                    "description": "The BlurHash of the image, used as the placeholder",
+                    "type": "string"
+                },
+                "exif": {
+                    "description": "The EXIF information, only if exposeExif is enabled",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/image.ExifInfo"
+                        }
+                    ]
+                },
+                "format": {
+                    "description": "The format of the image, like jpeg, png, gif",
+                    "type": "string"
+                },
+                "frames": {
+                    "description": "The number of frames, more than 1 if the image is animated",
+                    "type": "integer"
+                },
+                "height": {
+                    "description": "The height of the image as displayed",
+                    "type": "integer"
+                },
+                "palette": {
+                    "description": "The dominant colors like #rrggbb, the most used first",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "width": {
+                    "description": "The width of the image as displayed",
+                    "type": "integer"
+                }
+            }
+        },
+        "index.Entry": {
+            "type": "object",
+            "properties": {
+                "isDir": {
+                    "description": "Whether the entry is a folder",
+                    "type": "boolean"
+                },
+                "name": {
+                    "description": "The name of the entry",
+                    "type": "string"
+                },
+                "path": {
+                    "description": "The relative path of the entry",
+                    "type": "string"
+                }
+            }
+        },
+        "meta.UpdateFileMetaRequest": {
+            "type": "object",
+            "properties": {
+                "focalPoint": {
+                    "description": "FocalPoint is the point of interest of the image used by the fill crop, null clears it",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.FocalPoint"
+                        }
+                    ]
+                }
+            }
+        },
         "model.FileHash": {
             "type": "object",
             "properties": {
@@ -956,6 +1933,14 @@ const docTemplate = `{
                     "description": "The name of the file",
                     "type": "string"
                 },
+                "focalPoint": {
+                    "description": "The focal point of the image, used by the fill crop",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.FocalPoint"
+                        }
+                    ]
+                },
                 "hash": {
                     "description": "The hash of the file",
                     "allOf": [
@@ -964,10 +1949,22 @@ const docTemplate = `{
                         }
                     ]
                 },
+                "image": {
+                    "description": "The information of the image, nil if not an image or not analyzed yet",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.ImageMeta"
+                        }
+                    ]
+                },
                 "mimeType": {
                     "description": "The mime type of the file",
                     "type": "string"
                 },
+                "owner": {
+                    "description": "The subject of the token that uploaded the file",
+                    "type": "string"
+                },
                 "relativePath": {
                     "description": "The relative path to the base file storage path",
                     "type": "string"
@@ -978,6 +1975,49 @@ const docTemplate = `{
                 }
             }
         },
+        "model.FocalPoint": {
+            "type": "object",
+            "properties": {
+                "x": {
+                    "type": "number"
+                },
+                "y": {
+                    "type": "number"
+                }
+            }
+        },
+        "model.ImageMeta": {
+            "type": "object",
+            "properties": {
+                "blurHash": {
+                    "description": "The BlurHash of the image, used as the placeholder",
+                    "type": "string"
+                },
+                "format": {
+                    "description": "The format of the image, like jpeg, png, gif",
+                    "type": "string"
+                },
+                "frames": {
+                    "description": "The number of frames, more than 1 if the image is animated",
+                    "type": "integer"
+                },
+                "height": {
+                    "description": "The height of the image as displayed",
+                    "type": "integer"
+                },
+                "palette": {
+                    "description": "The dominant colors like #rrggbb, the most used first",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "width": {
+                    "description": "The width of the image as displayed",
+                    "type": "integer"
+                }
+            }
+        },
         "onlyoffice.onlyOfficeUpdateRequest": {
             "type": "object",
             "properties": {
@@ -990,6 +2030,142 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "quota.Report": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "description": "The limit of the quota",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/config.QuotaLimit"
+                        }
+                    ]
+                },
+                "name": {
+                    "description": "The prefix or the subject of the quota",
+                    "type": "string"
+                },
+                "usage": {
+                    "description": "The current usage of the quota",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/quota.Usage"
+                        }
+                    ]
+                }
+            }
+        },
+        "quota.Usage": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "description": "The total size of the files",
+                    "type": "integer"
+                },
+                "files": {
+                    "description": "The number of files",
+                    "type": "integer"
+                }
+            }
+        },
+        "scrub.Problem": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "The action taken, empty if nothing is done",
+                    "type": "string"
+                },
+                "detail": {
+                    "description": "The detail of the problem",
+                    "type": "string"
+                },
+                "kind": {
+                    "description": "The kind of the problem",
+                    "type": "string"
+                },
+                "path": {
+                    "description": "The fs path of the folder or the file with the problem",
+                    "type": "string"
+                },
+                "relativePath": {
+                    "description": "The relative path of the stored file, empty if it is unknown",
+                    "type": "string"
+                }
+            }
+        },
+        "scrub.Report": {
+            "type": "object",
+            "properties": {
+                "checked": {
+                    "description": "The number of the checked files",
+                    "type": "integer"
+                },
+                "finishedAt": {
+                    "description": "The time the scrub finished",
+                    "type": "integer"
+                },
+                "problems": {
+                    "description": "The problems found",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/scrub.Problem"
+                    }
+                },
+                "startedAt": {
+                    "description": "The time the scrub started",
+                    "type": "integer"
+                }
+            }
+        },
+        "webhook.Delivery": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "description": "The attempt number, starting from 1",
+                    "type": "integer"
+                },
+                "duration": {
+                    "description": "The duration of the attempt, in milliseconds",
+                    "type": "integer"
+                },
+                "error": {
+                    "description": "The error of the attempt",
+                    "type": "string"
+                },
+                "eventId": {
+                    "description": "The ID of the event",
+                    "type": "integer"
+                },
+                "eventType": {
+                    "description": "The type of the event",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/event.Type"
+                        }
+                    ]
+                },
+                "id": {
+                    "description": "The ID of the delivery, the same for all the attempts",
+                    "type": "string"
+                },
+                "statusCode": {
+                    "description": "The status code of the response, 0 if there is no response",
+                    "type": "integer"
+                },
+                "success": {
+                    "description": "Whether the attempt succeeded",
+                    "type": "boolean"
+                },
+                "time": {
+                    "description": "The time of the attempt",
+                    "type": "integer"
+                },
+                "url": {
+                    "description": "The URL of the subscription",
+                    "type": "string"
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -0,0 +1,184 @@
+// Package audit provides the audit log of the mutating operations, written to a rotating file and optionally posted to a webhook
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event"
+	"github.com/vvbbnn00/goflet/util/log"
+	"github.com/vvbbnn00/goflet/worker"
+)
+
+const (
+	webhookMaxWorkers = 2                // The maximum number of workers posting the events
+	webhookBufferSize = 1000             // The buffer size of the events to post
+	webhookTimeout    = 10 * time.Second // The timeout of posting an event
+	rotateTimeFormat  = "20060102T150405.000000"
+)
+
+// rotatingWriter writes the lines to a file, the file is renamed with a timestamp suffix when it reaches the maximum size
+type rotatingWriter struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	lock       sync.Mutex
+}
+
+var (
+	startOnce   sync.Once
+	webhookPool *worker.Pool
+)
+
+// Start subscribes the audit log to the events if it is enabled
+func Start() {
+	startOnce.Do(func() {
+		conf := config.GofletCfg.AuditConfig
+		if !*conf.Enabled {
+			return
+		}
+
+		writer, err := newRotatingWriter(conf.Path, conf.MaxSize, conf.MaxBackups)
+		if err != nil {
+			log.Fatalf("Error opening audit log: %s", err.Error())
+		}
+
+		if conf.WebhookURL != "" {
+			webhookPool = worker.NewPool(webhookMaxWorkers, webhookBufferSize, webhookWorkerFactory(conf.WebhookURL))
+			webhookPool.Start()
+		}
+
+		event.Subscribe(func(e event.Event) {
+			record(writer, e)
+		})
+		log.Infof("Audit log enabled, writing to %s", conf.Path)
+	})
+}
+
+// record writes the event to the audit log and enqueues it to the webhook
+func record(writer *rotatingWriter, e event.Event) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		log.Warnf("Error encoding audit event: %s", err.Error())
+		return
+	}
+
+	if _, err := writer.Write(append(line, '\n')); err != nil {
+		log.Warnf("Error writing audit event: %s", err.Error())
+	}
+
+	if webhookPool == nil {
+		return
+	}
+	select {
+	case webhookPool.JobChain <- worker.Job{Args: string(line)}:
+	default:
+		log.Warnf("Audit webhook queue is full, event %d dropped", e.ID)
+	}
+}
+
+// webhookWorkerFactory creates the factory of the workers posting the events to the URL
+func webhookWorkerFactory(url string) func() worker.Worker {
+	client := &http.Client{Timeout: webhookTimeout}
+	return func() worker.Worker {
+		return worker.Worker{
+			JobName: "AuditWebhook",
+			Do: func(job worker.Job) error {
+				return post(client, url, []byte(job.Args.(string)))
+			},
+		}
+	}
+}
+
+// post posts the encoded event to the URL
+func post(client *http.Client, url string, body []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// newRotatingWriter opens the file for appending, creates the folder if it does not exist
+func newRotatingWriter(path string, maxSize int64, maxBackups int) (*rotatingWriter, error) {
+	w := &rotatingWriter{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open opens the file for appending
+func (w *rotatingWriter) open() error {
+	err := os.MkdirAll(filepath.Dir(w.path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// Write writes the line to the file, rotates the file first if the line makes it exceed the maximum size
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file and opens a new one, the oldest backups exceeding the limit are removed
+func (w *rotatingWriter) rotate() error {
+	_ = w.file.Close()
+
+	backup := w.path + "." + time.Now().Format(rotateTimeFormat)
+	if err := os.Rename(w.path, backup); err != nil {
+		log.Warnf("Error rotating audit log: %s", err.Error())
+	}
+
+	if w.maxBackups > 0 {
+		backups, _ := filepath.Glob(w.path + ".*")
+		sort.Strings(backups) // The timestamp suffix sorts in time order
+		for len(backups) > w.maxBackups {
+			_ = os.Remove(backups[0])
+			backups = backups[1:]
+		}
+	}
+
+	return w.open()
+}
@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event"
+)
+
+func TestRotatingWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writer, err := newRotatingWriter(path, 10, 2)
+	assert.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err := writer.Write([]byte("12345678\n"))
+		assert.NoError(t, err)
+		time.Sleep(time.Millisecond) // Ensure the backups have different names
+	}
+
+	backups, _ := filepath.Glob(path + ".*")
+	assert.Len(t, backups, 2)
+
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "12345678\n", string(content))
+}
+
+func TestAudit(t *testing.T) {
+	received := make(chan event.Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var e event.Event
+		_ = json.Unmarshal(body, &e)
+		received <- e
+	}))
+	defer server.Close()
+
+	enabled := true
+	path := filepath.Join(t.TempDir(), "audit.log")
+	config.GofletCfg.AuditConfig.Enabled = &enabled
+	config.GofletCfg.AuditConfig.Path = path
+	config.GofletCfg.AuditConfig.WebhookURL = server.URL
+	Start()
+
+	published := event.Publish(event.Event{
+		Type:  event.TypeFileDeleted,
+		Actor: event.Actor{Subject: "user", Issuer: "issuer", TokenID: "token", IP: "127.0.0.1"},
+		Path:  "audit/file.txt",
+		Size:  10,
+	})
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer func() {
+		_ = file.Close()
+	}()
+	scanner := bufio.NewScanner(file)
+	assert.True(t, scanner.Scan())
+	var logged event.Event
+	assert.NoError(t, json.Unmarshal(scanner.Bytes(), &logged))
+	assert.Equal(t, published, logged)
+
+	select {
+	case posted := <-received:
+		assert.Equal(t, published, posted)
+	case <-time.After(5 * time.Second):
+		t.Fatal("The event was not posted to the webhook")
+	}
+}
@@ -0,0 +1,125 @@
+// Package event provides the internal bus of the file lifecycle events
+package event
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vvbbnn00/goflet/storage"
+)
+
+// Type is the type of the event
+type Type string
+
+const (
+	// TypeFileUploaded is published when an upload is completed
+	TypeFileUploaded Type = "file.uploaded"
+	// TypeFileHashed is published when the hash of a file is computed
+	TypeFileHashed Type = "file.hashed"
+	// TypeFileDeleted is published when a file is deleted
+	TypeFileDeleted Type = "file.deleted"
+	// TypeFileMoved is published when a file is moved
+	TypeFileMoved Type = "file.moved"
+	// TypeFileCopied is published when a file is copied
+	TypeFileCopied Type = "file.copied"
+	// TypeFileCreated is published when an empty file is created
+	TypeFileCreated Type = "file.created"
+	// TypeOnlyOfficeSaved is published when a file is saved by OnlyOffice
+	TypeOnlyOfficeSaved Type = "onlyoffice.saved"
+)
+
+// Actor is the client who caused the event, empty for the events caused by the server itself
+type Actor struct {
+	Subject string `json:"sub,omitempty"` // The subject of the token
+	Issuer  string `json:"iss,omitempty"` // The issuer of the token
+	TokenID string `json:"jti,omitempty"` // The ID of the token
+	IP      string `json:"ip,omitempty"`  // The IP of the client
+}
+
+// Event is a change of a file
+type Event struct {
+	ID         uint64 `json:"id"`                   // The ID of the event, increasing in the process
+	Type       Type   `json:"type"`                 // The type of the event
+	Time       int64  `json:"time"`                 // The time of the event
+	Actor      Actor  `json:"actor"`                // The client who caused the event
+	Path       string `json:"path"`                 // The relative path of the file
+	SourcePath string `json:"sourcePath,omitempty"` // The relative path of the source file, only for move and copy
+	Size       int64  `json:"size"`                 // The size of the file
+	Sha256     string `json:"sha256,omitempty"`     // The sha256 of the file, empty if it has not been computed yet
+}
+
+// Under checks whether the file or the source file of the event is under the path prefix, an empty prefix matches all
+func (e Event) Under(prefix string) bool {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	for _, path := range []string{e.Path, e.SourcePath} {
+		path = strings.Trim(path, "/")
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler handles the published events, it should return quickly since the events are dispatched synchronously
+type Handler func(e Event)
+
+var (
+	lock         sync.RWMutex
+	handlers     = make(map[int]Handler)
+	nextHandler  int
+	lastEventID  atomic.Uint64
+	publishMutex sync.Mutex // Keep the events in the order of their ID
+)
+
+// Subscribe registers the handler for all the events, returns the function to unsubscribe
+func Subscribe(handler Handler) func() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	id := nextHandler
+	nextHandler++
+	handlers[id] = handler
+
+	return func() {
+		lock.Lock()
+		defer lock.Unlock()
+		delete(handlers, id)
+	}
+}
+
+// Publish assigns the ID and the time to the event and dispatches it to the handlers
+func Publish(e Event) Event {
+	publishMutex.Lock()
+	defer publishMutex.Unlock()
+
+	e.ID = lastEventID.Add(1)
+	if e.Time == 0 {
+		e.Time = time.Now().Unix()
+	}
+
+	lock.RLock()
+	defer lock.RUnlock()
+	for _, handler := range handlers {
+		handler(e)
+	}
+	return e
+}
+
+// NewFileEvent creates the event of the stored file, the size and the hash are read from the file at the fs path
+func NewFileEvent(eventType Type, actor Actor, relativePath string, fsPath string) Event {
+	e := Event{
+		Type:  eventType,
+		Actor: actor,
+		Path:  relativePath,
+	}
+	if fileInfo, err := storage.GetFileInfo(fsPath); err == nil {
+		e.Size = fileInfo.FileSize
+		e.Sha256 = fileInfo.FileMeta.Hash.HashSha256
+	}
+	return e
+}
@@ -0,0 +1,84 @@
+// Package stream provides the buffered feed of the events for the streaming clients
+package stream
+
+import (
+	"sync"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+const listenerBufferSize = 100 // The number of the events queued for a listener before it is disconnected
+
+var (
+	startOnce sync.Once
+	lock      sync.Mutex
+	buffer    []event.Event                         // The latest events, the oldest first
+	listeners = make(map[chan event.Event]struct{}) // The channels of the connected clients
+)
+
+// Enabled returns whether the event stream is enabled
+func Enabled() bool {
+	return *config.GofletCfg.EventStreamConfig.Enabled
+}
+
+// Start subscribes the feed to the events if it is enabled
+func Start() {
+	startOnce.Do(func() {
+		if !Enabled() {
+			return
+		}
+		event.Subscribe(publish)
+		log.Infof("Event stream enabled, keeping the latest %d events", config.GofletCfg.EventStreamConfig.BufferSize)
+	})
+}
+
+// publish appends the event to the buffer and sends it to the listeners, the listeners falling behind are disconnected
+func publish(e event.Event) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	buffer = append(buffer, e)
+	if size := config.GofletCfg.EventStreamConfig.BufferSize; len(buffer) > size {
+		buffer = append([]event.Event(nil), buffer[len(buffer)-size:]...)
+	}
+
+	for ch := range listeners {
+		select {
+		case ch <- e:
+		default:
+			log.Debugf("Event stream listener is too slow, disconnected")
+			delete(listeners, ch)
+			close(ch)
+		}
+	}
+}
+
+// Listen returns the buffered events after the last event ID and the channel of the following events,
+// the channel is closed when the listener falls behind or the returned function is called
+func Listen(lastEventID uint64) ([]event.Event, <-chan event.Event, func()) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	var backlog []event.Event
+	if lastEventID > 0 {
+		for _, e := range buffer {
+			if e.ID > lastEventID {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+
+	ch := make(chan event.Event, listenerBufferSize)
+	listeners[ch] = struct{}{}
+
+	return backlog, ch, func() {
+		lock.Lock()
+		defer lock.Unlock()
+		if _, ok := listeners[ch]; ok {
+			delete(listeners, ch)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Package webhook provides the webhook subscriptions of the file lifecycle events
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/log"
+	"github.com/vvbbnn00/goflet/worker"
+)
+
+const (
+	deliveryMaxWorkers = 4    // The maximum number of workers delivering the events
+	deliveryBufferSize = 1000 // The buffer size of the deliveries
+
+	// HeaderEvent is the header that contains the type of the event
+	HeaderEvent = "X-Goflet-Event"
+	// HeaderDelivery is the header that contains the ID of the delivery
+	HeaderDelivery = "X-Goflet-Delivery"
+	// HeaderTimestamp is the header that contains the time of the delivery attempt
+	HeaderTimestamp = "X-Goflet-Timestamp"
+	// HeaderSignature is the header that contains the signature of the payload,
+	// in the format sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))
+	HeaderSignature = "X-Goflet-Signature"
+)
+
+// Delivery is an attempt to post an event to a subscription
+type Delivery struct {
+	ID         string     `json:"id"`              // The ID of the delivery, the same for all the attempts
+	EventID    uint64     `json:"eventId"`         // The ID of the event
+	EventType  event.Type `json:"eventType"`       // The type of the event
+	URL        string     `json:"url"`             // The URL of the subscription
+	Attempt    int        `json:"attempt"`         // The attempt number, starting from 1
+	Time       int64      `json:"time"`            // The time of the attempt
+	Duration   int64      `json:"duration"`        // The duration of the attempt, in milliseconds
+	StatusCode int        `json:"statusCode"`      // The status code of the response, 0 if there is no response
+	Error      string     `json:"error,omitempty"` // The error of the attempt
+	Success    bool       `json:"success"`         // Whether the attempt succeeded
+}
+
+// job is the delivery of an event to a subscription
+type job struct {
+	id           string
+	subscription config.WebhookSubscription
+	event        event.Event
+	payload      []byte
+}
+
+// String returns the description of the job for the logs
+func (j *job) String() string {
+	return fmt.Sprintf("%s of event %d to %s", j.event.Type, j.event.ID, j.subscription.URL)
+}
+
+var (
+	startOnce    sync.Once
+	deliveryPool *worker.Pool
+	client       *http.Client
+
+	historyLock sync.RWMutex
+	history     []Delivery
+)
+
+// Start subscribes the webhooks to the events if they are enabled
+func Start() {
+	startOnce.Do(func() {
+		conf := config.GofletCfg.WebhookConfig
+		if !*conf.Enabled || len(conf.Subscriptions) == 0 {
+			return
+		}
+
+		client = &http.Client{Timeout: time.Duration(conf.Timeout) * time.Second}
+		deliveryPool = worker.NewPool(deliveryMaxWorkers, deliveryBufferSize, workerFactory)
+		deliveryPool.Start()
+
+		event.Subscribe(dispatch)
+		log.Infof("Webhooks enabled, %d subscriptions", len(conf.Subscriptions))
+	})
+}
+
+// workerFactory creates a new worker
+func workerFactory() worker.Worker {
+	return worker.Worker{
+		JobName: "WebhookDelivery",
+		Do: func(j worker.Job) error {
+			return deliver(j.Args.(*job), j.RetryCount+1)
+		},
+	}
+}
+
+// dispatch enqueues the deliveries of the event to the matching subscriptions
+func dispatch(e event.Event) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		log.Warnf("Error encoding webhook event: %s", err.Error())
+		return
+	}
+
+	for _, subscription := range config.GofletCfg.WebhookConfig.Subscriptions {
+		if !matches(subscription, e) {
+			continue
+		}
+		j := &job{
+			id:           util.RandomString(16),
+			subscription: subscription,
+			event:        e,
+			payload:      payload,
+		}
+		select {
+		case deliveryPool.JobChain <- worker.Job{Args: j}:
+		default:
+			log.Warnf("Webhook queue is full, event %d to %s dropped", e.ID, subscription.URL)
+		}
+	}
+}
+
+// matches checks whether the subscription subscribes to the event
+func matches(subscription config.WebhookSubscription, e event.Event) bool {
+	if len(subscription.Events) > 0 && !slices.Contains(subscription.Events, string(e.Type)) {
+		return false
+	}
+	return e.Under(subscription.Prefix)
+}
+
+// Sign returns the signature of the payload sent at the timestamp
+func Sign(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver posts the event to the subscription and records the attempt, returns an error to retry
+func deliver(j *job, attempt int) error {
+	start := time.Now()
+	delivery := Delivery{
+		ID:        j.id,
+		EventID:   j.event.ID,
+		EventType: j.event.Type,
+		URL:       j.subscription.URL,
+		Attempt:   attempt,
+		Time:      start.Unix(),
+	}
+
+	statusCode, err := post(j, start)
+	delivery.Duration = time.Since(start).Milliseconds()
+	delivery.StatusCode = statusCode
+	if err == nil && (statusCode < 200 || statusCode >= 300) {
+		err = fmt.Errorf("unexpected status code %d", statusCode)
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	} else {
+		delivery.Success = true
+	}
+	record(delivery)
+
+	return err
+}
+
+// post sends the signed payload to the subscription, returns the status code of the response
+func post(j *job, now time.Time) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, j.subscription.URL, bytes.NewReader(j.payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(j.event.Type))
+	req.Header.Set(HeaderDelivery, j.id)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if j.subscription.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(j.subscription.Secret, timestamp, j.payload))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// record appends the delivery to the history, the oldest deliveries exceeding the history size are dropped
+func record(delivery Delivery) {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	history = append(history, delivery)
+	if size := config.GofletCfg.WebhookConfig.HistorySize; len(history) > size {
+		history = slices.Clone(history[len(history)-size:])
+	}
+}
+
+// GetDeliveries returns the latest deliveries first, filtered by the event type and the delivery ID if they are not empty
+func GetDeliveries(eventType string, deliveryID string, limit int) []Delivery {
+	historyLock.RLock()
+	defer historyLock.RUnlock()
+
+	result := make([]Delivery, 0)
+	for i := len(history) - 1; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+		delivery := history[i]
+		if eventType != "" && string(delivery.EventType) != eventType {
+			continue
+		}
+		if deliveryID != "" && delivery.ID != deliveryID {
+			continue
+		}
+		result = append(result, delivery)
+	}
+	return result
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event"
+)
+
+const testSecret = "webhook-secret"
+
+func TestWebhook(t *testing.T) {
+	var requests atomic.Int32
+	received := make(chan event.Event, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first attempt to test the retry
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		signature := Sign(testSecret, r.Header.Get(HeaderTimestamp), body)
+		if r.Header.Get(HeaderSignature) != signature {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var e event.Event
+		_ = json.Unmarshal(body, &e)
+		received <- e
+	}))
+	defer server.Close()
+
+	enabled := true
+	config.GofletCfg.WebhookConfig.Enabled = &enabled
+	config.GofletCfg.WebhookConfig.Timeout = 5
+	config.GofletCfg.WebhookConfig.HistorySize = 10
+	config.GofletCfg.WebhookConfig.Subscriptions = []config.WebhookSubscription{{
+		URL:    server.URL,
+		Secret: testSecret,
+		Events: []string{string(event.TypeFileUploaded)},
+		Prefix: "/webhook-test",
+	}}
+	Start()
+
+	// Neither the type nor the path is subscribed
+	event.Publish(event.Event{Type: event.TypeFileDeleted, Path: "webhook-test/a.txt"})
+	event.Publish(event.Event{Type: event.TypeFileUploaded, Path: "other/a.txt"})
+
+	published := event.Publish(event.Event{Type: event.TypeFileUploaded, Path: "webhook-test/a.txt", Size: 1})
+
+	select {
+	case e := <-received:
+		assert.Equal(t, published, e)
+	case <-time.After(10 * time.Second):
+		t.Fatal("The event was not delivered")
+	}
+
+	// The attempt is recorded after the response is received
+	assert.Eventually(t, func() bool {
+		return len(GetDeliveries("", "", 0)) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	deliveries := GetDeliveries("", "", 0)
+	assert.True(t, deliveries[0].Success)
+	assert.Equal(t, 2, deliveries[0].Attempt)
+	assert.False(t, deliveries[1].Success)
+	assert.Equal(t, http.StatusServiceUnavailable, deliveries[1].StatusCode)
+	assert.Equal(t, deliveries[0].ID, deliveries[1].ID)
+	assert.Equal(t, published.ID, deliveries[0].EventID)
+
+	assert.Len(t, GetDeliveries(string(event.TypeFileDeleted), "", 0), 0)
+	assert.Len(t, GetDeliveries("", deliveries[0].ID, 1), 1)
+}
@@ -2,9 +2,17 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/vvbbnn00/goflet/base"
 	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event/audit"
+	"github.com/vvbbnn00/goflet/event/stream"
+	"github.com/vvbbnn00/goflet/event/webhook"
 	"github.com/vvbbnn00/goflet/route"
+	"github.com/vvbbnn00/goflet/route/rpc"
+	"github.com/vvbbnn00/goflet/route/s3"
 	"github.com/vvbbnn00/goflet/task"
 	"github.com/vvbbnn00/goflet/util/log"
 )
@@ -30,14 +38,31 @@ import (
 // @externalDocs.description  OpenAPI
 // @externalDocs.url          https://swagger.io/resources/open-api/
 func main() {
+	if err := runCommand(os.Args[1:]); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+// serve starts the servers and the scheduled tasks, it never returns
+func serve() {
 	base.PrintBanner()
 
 	gofletCfg := config.GofletCfg
 
+	// Start the event subscribers before serving any request
+	audit.Start()
+	webhook.Start()
+	stream.Start()
+
 	httpConfig := gofletCfg.HTTPConfig
 	router := route.RegisterRoutes()
 	endpoint := gofletCfg.GetEndpoint()
 
+	// The S3 and gRPC APIs listen on their own ports
+	s3.Start()
+	rpc.Start()
+
 	// Start the HTTP and HTTPS servers
 	if *httpConfig.HTTPSConfig.Enabled {
 		go func() {
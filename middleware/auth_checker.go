@@ -2,11 +2,13 @@
 package middleware
 
 import (
+	"encoding/base64"
 	"net/http"
 	"net/url"
 	"strings"
 
 	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event"
 
 	"github.com/gin-gonic/gin"
 
@@ -19,8 +21,14 @@ const (
 	AuthHeader = "Authorization"
 	// Bearer The prefix of the JWT token in the header
 	Bearer = "Bearer "
+	// Basic The prefix of the basic credentials in the header, the password is the JWT token
+	Basic = "Basic "
 	// AuthQuery The query parameter that contains the JWT token
 	AuthQuery = "token"
+	// ClaimsKey The context key of the parsed JWT claims
+	ClaimsKey = "claims"
+	// ChallengeKey The context key of the realm sent in the WWW-Authenticate header of the unauthorized responses
+	ChallengeKey = "authChallenge"
 )
 
 // AuthChecker ensures the request is authenticated and authorized
@@ -50,10 +58,81 @@ func AuthChecker() gin.HandlerFunc {
 			return
 		}
 
+		// Set the claims in the context
+		c.Set(ClaimsKey, claims)
 		c.Next()
 	}
 }
 
+// GetClaims Get the parsed JWT claims from the context, returns nil if JWT is disabled
+func GetClaims(c *gin.Context) *util.JwtClaims {
+	value, ok := c.Get(ClaimsKey)
+	if !ok {
+		return nil
+	}
+	claims, _ := value.(*util.JwtClaims)
+	return claims
+}
+
+// GetSubject Get the subject of the JWT token from the context, returns an empty string if there is no subject
+func GetSubject(c *gin.Context) string {
+	claims := GetClaims(c)
+	if claims == nil || claims.StandardClaims == nil {
+		return ""
+	}
+	return claims.Subject
+}
+
+// GetActor Get the client of the request for the events
+func GetActor(c *gin.Context) event.Actor {
+	actor := event.Actor{IP: c.ClientIP()}
+	if claims := GetClaims(c); claims != nil && claims.StandardClaims != nil {
+		actor.Subject = claims.Subject
+		actor.Issuer = claims.Issuer
+		actor.TokenID = claims.Id
+	}
+	return actor
+}
+
+// CanAccessBucket Check if the token can access the bucket of the relative path,
+// the tokens not bound to a bucket can access every bucket
+func CanAccessBucket(c *gin.Context, relativePath string) bool {
+	return ClaimsCanAccessBucket(GetClaims(c), relativePath)
+}
+
+// ClaimsCanAccessBucket Check if the claims can access the bucket of the relative path, for the APIs not served by gin
+func ClaimsCanAccessBucket(claims *util.JwtClaims, relativePath string) bool {
+	if claims == nil || claims.Bucket == "" {
+		return true
+	}
+	return util.GetBucketName(relativePath) == claims.Bucket
+}
+
+// CanReadFile Check if the token can read the file at the relative path, which means it is authorized to GET /file/{path}
+func CanReadFile(c *gin.Context, relativePath string) bool {
+	if !CanAccessBucket(c, relativePath) {
+		return false
+	}
+	return CanRequest(c, "/file/"+relativePath, http.MethodGet)
+}
+
+// CanRequest Check if the token is authorized to request the path with the method
+func CanRequest(c *gin.Context, path string, method string) bool {
+	return ClaimsCanRequest(GetClaims(c), path, method)
+}
+
+// ClaimsCanRequest Check if the claims are authorized to request the path with the method, the APIs not served
+// by gin map their calls to the HTTP routes, so the same permissions apply
+func ClaimsCanRequest(claims *util.JwtClaims, path string, method string) bool {
+	if !*config.GofletCfg.JWTConfig.Enabled {
+		return true
+	}
+	if claims == nil {
+		return false
+	}
+	return hasPermission(path, method, url.Values{}, claims.Permissions)
+}
+
 // extractToken Extract the JWT token from the request
 func extractToken(c *gin.Context) string {
 	token := c.Query(AuthQuery) // Check the query parameter
@@ -66,6 +145,16 @@ func extractToken(c *gin.Context) string {
 		return strings.TrimPrefix(token, Bearer)
 	}
 
+	// The clients like WebDAV only support the basic authentication, the token is sent as the password
+	if strings.HasPrefix(token, Basic) {
+		credentials, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(token, Basic))
+		if err != nil {
+			return ""
+		}
+		_, password, _ := strings.Cut(string(credentials), ":")
+		return password
+	}
+
 	return ""
 }
 
@@ -99,15 +188,19 @@ func replaceMultipleSlashes(input string) string {
 
 // isAuthorized Check if the token is authorized to access the path
 func isAuthorized(c *gin.Context, permissions []util.Permission) bool {
-	currentPath := replaceMultipleSlashes(c.Request.URL.Path) // Clean the path (only replace multiple slashes)
-	method := c.Request.Method
+	return hasPermission(c.Request.URL.Path, c.Request.Method, c.Request.URL.Query(), permissions)
+}
+
+// hasPermission Check if the permissions allow the request to the path with the method and the query
+func hasPermission(path string, method string, query url.Values, permissions []util.Permission) bool {
+	currentPath := replaceMultipleSlashes(path) // Clean the path (only replace multiple slashes)
 
 	for _, perm := range permissions {
 		// Check if the path matches
 		match := util.Match(currentPath, replaceMultipleSlashes(perm.Path))
 		// Check if the method matches
 		if match || perm.Path == "*" {
-			return util.MatchMethod(method, perm.Methods) && queryMatch(c.Request.URL.Query(), perm.Query)
+			return util.MatchMethod(method, perm.Methods) && queryMatch(query, perm.Query)
 		}
 	}
 
@@ -116,6 +209,9 @@ func isAuthorized(c *gin.Context, permissions []util.Permission) bool {
 
 // unauthorized Return an unauthorized response
 func unauthorized(c *gin.Context, message string) {
+	if realm := c.GetString(ChallengeKey); realm != "" {
+		c.Header("WWW-Authenticate", `Basic realm="`+realm+`"`)
+	}
 	c.JSON(http.StatusUnauthorized, gin.H{"error": message})
 	c.Abort()
 }
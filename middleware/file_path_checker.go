@@ -26,6 +26,12 @@ func FilePathChecker() gin.HandlerFunc {
 			return
 		}
 
+		// The token bound to a bucket can only access the bucket
+		if !CanAccessBucket(c, pathData.RelativePath) {
+			unauthorized(c, "Unauthorized access")
+			return
+		}
+
 		// Set the cleaned path in the context
 		c.Set("cleanPath", pathData.CleanedPath)
 		// Set the relative path in the context
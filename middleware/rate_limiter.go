@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vvbbnn00/goflet/cache"
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/util/hash"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// RateLimitCachePrefix is the cache prefix for the rate limit counters
+const RateLimitCachePrefix = "rate_limit:"
+
+// tokenBucket is a token bucket whose state lives in the cache, so it can be shared between instances
+type tokenBucket struct {
+	rate  float64 // The number of tokens refilled per second
+	burst float64 // The capacity of the bucket
+	ttl   int     // The time to live of the state in the cache
+	lock  sync.Mutex
+}
+
+// concurrencyCounter counts the number of in-flight requests of each client
+type concurrencyCounter struct {
+	limit  int
+	counts map[string]int
+	lock   sync.Mutex
+}
+
+// RateLimiter limits the request rate and the number of concurrent requests of each client in the route group,
+// the client is identified by the JWT subject, the API key header or the client IP, in that order
+func RateLimiter(group string) gin.HandlerFunc {
+	conf := config.GofletCfg.RateLimitConfig
+	rule, ok := conf.Rules[group]
+	if !*conf.Enabled || !ok {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	bucket := newTokenBucket(rule, conf.CacheTTL)
+	counter := &concurrencyCounter{
+		limit:  rule.MaxConcurrent,
+		counts: make(map[string]int),
+	}
+
+	return func(c *gin.Context) {
+		key := group + ":" + clientKey(c, conf.KeyHeader)
+
+		if bucket != nil {
+			if allowed, retryAfter := bucket.take(key); !allowed {
+				log.Debugf("Rate limit exceeded: %s", key)
+				tooManyRequests(c, retryAfter)
+				return
+			}
+		}
+
+		if counter.limit > 0 {
+			if !counter.acquire(key) {
+				log.Debugf("Concurrency limit exceeded: %s", key)
+				tooManyRequests(c, 1)
+				return
+			}
+			defer counter.release(key)
+		}
+
+		c.Next()
+	}
+}
+
+// clientKey returns the key to identify the client of the request
+func clientKey(c *gin.Context, keyHeader string) string {
+	if subject := GetSubject(c); subject != "" {
+		return "sub:" + subject
+	}
+	if keyHeader != "" {
+		if apiKey := c.GetHeader(keyHeader); apiKey != "" {
+			return "key:" + hash.StringSha256(apiKey) // Avoid storing the raw key in the cache
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// tooManyRequests Return a too many requests response
+func tooManyRequests(c *gin.Context, retryAfter int) {
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+}
+
+// newTokenBucket creates a new token bucket for the rule, returns nil if the rate is not limited
+func newTokenBucket(rule config.RateLimitRule, ttl int) *tokenBucket {
+	if rule.Rate <= 0 {
+		return nil
+	}
+	burst := float64(rule.Burst)
+	if burst < 1 {
+		burst = math.Max(1, math.Ceil(rule.Rate))
+	}
+	return &tokenBucket{
+		rate:  rule.Rate,
+		burst: burst,
+		ttl:   ttl,
+	}
+}
+
+// take takes a token from the bucket, returns whether the request is allowed and the seconds to wait if not
+func (b *tokenBucket) take(key string) (bool, int) {
+	// The cache has no atomic operations, so the read-modify-write is serialized in this instance
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	c := cache.GetCache()
+	tokensKey := RateLimitCachePrefix + key + ":tokens"
+	updatedKey := RateLimitCachePrefix + key + ":updated"
+	now := float64(time.Now().UnixNano()) / float64(time.Second)
+
+	tokens, err := c.GetFloat(tokensKey)
+	updated, uerr := c.GetFloat(updatedKey)
+	if err != nil || uerr != nil {
+		// First request of the client, or the state has expired
+		tokens = b.burst
+		updated = now
+	}
+
+	// Refill the bucket
+	tokens = math.Min(b.burst, tokens+(now-updated)*b.rate)
+
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+
+	_ = c.SetEx(tokensKey, tokens, b.ttl)
+	_ = c.SetEx(updatedKey, now, b.ttl)
+
+	if allowed {
+		return true, 0
+	}
+	return false, int(math.Ceil((1 - tokens) / b.rate))
+}
+
+// acquire increases the number of in-flight requests of the client, returns false if the limit is reached
+func (l *concurrencyCounter) acquire(key string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.counts[key] >= l.limit {
+		return false
+	}
+	l.counts[key]++
+	return true
+}
+
+// release decreases the number of in-flight requests of the client
+func (l *concurrencyCounter) release(key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.counts[key]--
+	if l.counts[key] <= 0 {
+		delete(l.counts, key)
+	}
+}
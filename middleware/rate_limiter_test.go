@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vvbbnn00/goflet/config"
+)
+
+// newRateLimitedRouter creates a router with the rate limiter enabled for the group
+func newRateLimitedRouter(group string, rule config.RateLimitRule, handler gin.HandlerFunc) *gin.Engine {
+	enabled := true
+	config.GofletCfg.RateLimitConfig.Enabled = &enabled
+	config.GofletCfg.RateLimitConfig.Rules = map[string]config.RateLimitRule{group: rule}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(group, RateLimiter(group), handler)
+	return router
+}
+
+func doGet(router *gin.Engine, path string, apiKey string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	if apiKey != "" {
+		req.Header.Set("X-Api-Key", apiKey)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	router := newRateLimitedRouter("/burst", config.RateLimitRule{Rate: 1, Burst: 2}, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	assert.Equal(t, http.StatusOK, doGet(router, "/burst", "").Code)
+	assert.Equal(t, http.StatusOK, doGet(router, "/burst", "").Code)
+
+	w := doGet(router, "/burst", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// Another client has its own bucket
+	assert.Equal(t, http.StatusOK, doGet(router, "/burst", "another-key").Code)
+}
+
+func TestRateLimiterConcurrency(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	router := newRateLimitedRouter("/concurrency", config.RateLimitRule{MaxConcurrent: 1}, func(c *gin.Context) {
+		if c.GetHeader("X-Api-Key") == "blocking" {
+			entered <- struct{}{}
+			<-release
+		}
+		c.Status(http.StatusOK)
+	})
+
+	done := make(chan int)
+	go func() {
+		done <- doGet(router, "/concurrency", "blocking").Code
+	}()
+	<-entered
+
+	assert.Equal(t, http.StatusTooManyRequests, doGet(router, "/concurrency", "blocking").Code)
+	assert.Equal(t, http.StatusOK, doGet(router, "/concurrency", "other").Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, http.StatusOK, doGet(router, "/concurrency", "other").Code)
+}
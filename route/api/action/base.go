@@ -6,7 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 
-	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/middleware"
 	"github.com/vvbbnn00/goflet/util"
 	"github.com/vvbbnn00/goflet/util/log"
 )
@@ -44,60 +44,55 @@ func checkPath(path string, c *gin.Context) (*util.Path, error) {
 		return nil, err
 	}
 
+	// The token bound to a bucket can only access the bucket
+	if !middleware.CanAccessBucket(c, pathData.RelativePath) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+		return nil, errors.New("Unauthorized access")
+	}
+
 	return pathData, nil
 }
 
-// preCheckForCopyMoveRoute checks the request body and the source and target paths
-func preCheckForCopyMoveRoute(c *gin.Context) (*util.Path, *util.Path, bool) {
+// parseCopyMoveRequest parses the request body and the source and target paths of the copy and move actions
+func parseCopyMoveRequest(c *gin.Context) (*util.Path, *util.Path, bool, bool) {
 	// Get the request body
 	var req CopyMoveFileRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		log.Debugf("Error binding request: %s", err.Error())
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
-		return nil, nil, false
+		return nil, nil, false, false
 	}
 
 	// Check if the source and target paths are valid
 	sourcePath, err := checkPath(req.SourcePath, c)
 	if err != nil {
-		return nil, nil, false
+		return nil, nil, false, false
 	}
 
 	// Check if the source and target paths are valid
 	targetPath, err := checkPath(req.TargetPath, c)
 	if err != nil {
-		return nil, nil, false
+		return nil, nil, false, false
 	}
 
-	// Check if the source and target paths are the same
-	if sourcePath.FsPath == targetPath.FsPath {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Source and target paths are the same"})
-		return nil, nil, false
-	}
+	return sourcePath, targetPath, req.OnConflict == OnConflictActionOverwrite, true
+}
 
-	// Check if the source file exists
-	if !storage.FileExists(sourcePath.FsPath) {
+// handleCopyMoveError responds with the error of the copy or move action
+func handleCopyMoveError(c *gin.Context, err error, message string) {
+	switch err.Error() {
+	case "same_path":
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Source and target paths are the same"})
+	case "different_buckets":
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Source and target paths are in different buckets"})
+	case "source_file_not_found":
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Source file not found"})
-		return nil, nil, false
-	}
-
-	// Check if the target file exists
-	if storage.FileExists(targetPath.FsPath) {
-		switch req.OnConflict {
-		default:
-			fallthrough
-		case OnConflictActionAbort:
-			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "File already exists"})
-			return nil, nil, false
-		case OnConflictActionOverwrite:
-			// Delete the target file
-			err := storage.DeleteFile(targetPath.FsPath)
-			if err != nil {
-				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error deleting target file"})
-				return nil, nil, false
-			}
-		}
+	case "quota_exceeded":
+		c.AbortWithStatusJSON(http.StatusInsufficientStorage, gin.H{"error": "Quota exceeded"})
+	case "file_exists":
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "File already exists"})
+	default:
+		log.Debugf("%s: %s", message, err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": message})
 	}
-
-	return sourcePath, targetPath, true
 }
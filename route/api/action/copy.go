@@ -5,9 +5,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 
-	"github.com/vvbbnn00/goflet/cache"
-	"github.com/vvbbnn00/goflet/storage"
-	"github.com/vvbbnn00/goflet/util/log"
+	"github.com/vvbbnn00/goflet/middleware"
+	"github.com/vvbbnn00/goflet/storage/fileop"
 )
 
 // routeCopyFile handler for POST /action/copy
@@ -22,28 +21,18 @@ import (
 // @Failure      404  {object} string	"File not found"
 // @Failure      409  {object} string	"File exists"
 // @Failure      500  {object} string	"Internal server error"
+// @Failure      507  {object} string	"Quota exceeded"
 // @Router       /api/action/copy [post]
 // @Security	 Authorization
 func routeCopyFile(c *gin.Context) {
-	sourcePath, targetPath, ok := preCheckForCopyMoveRoute(c)
+	sourcePath, targetPath, overwrite, ok := parseCopyMoveRequest(c)
 	if !ok {
 		return
 	}
 
-	// Lock the file, in case file upload is in progress
-	ca := cache.GetCache()
-	_ = ca.SetEx(storage.CachePrefix+targetPath.FsPath, true, 60)
-
-	// Unlock the file after the operation
-	defer func() {
-		_ = ca.Del(storage.CachePrefix + targetPath.FsPath)
-	}()
-
-	// Copy the whole folder of the source to the target and update the metadata
-	err := storage.CopyFile(sourcePath, targetPath)
+	err := fileop.Copy(middleware.GetActor(c), sourcePath, targetPath, overwrite)
 	if err != nil {
-		log.Debugf("Error copying file: %s", err.Error())
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error copying file"})
+		handleCopyMoveError(c, err, "Error copying file")
 		return
 	}
 
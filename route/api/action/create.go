@@ -5,8 +5,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 
-	"github.com/vvbbnn00/goflet/cache"
-	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/middleware"
+	"github.com/vvbbnn00/goflet/storage/fileop"
 	"github.com/vvbbnn00/goflet/util/log"
 )
 
@@ -27,6 +27,7 @@ type CreateFileRequest struct {
 // @Failure      400  {object} string	"Bad request"
 // @Failure      409  {object} string	"File exists"
 // @Failure      500  {object} string	"Internal server error"
+// @Failure      507  {object} string	"Quota exceeded"
 // @Router       /api/action/create [post]
 // @Security	 Authorization
 func routeCreateFile(c *gin.Context) {
@@ -44,27 +45,18 @@ func routeCreateFile(c *gin.Context) {
 		return
 	}
 
-	// Check if the file already exists
-	if storage.FileExists(pathData.FsPath) {
-		log.Debugf("File already exists: %s", pathData.FsPath)
-		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "File already exists"})
-		return
-	}
-
-	// Lock the file, in case file upload is in progress
-	ca := cache.GetCache()
-	_ = ca.SetEx(storage.CachePrefix+pathData.FsPath, true, 60)
-
-	// Unlock the file after the operation
-	defer func() {
-		_ = ca.Del(storage.CachePrefix + pathData.FsPath)
-	}()
-
-	// Create the file and update the metadata
-	err = storage.CreateFile(pathData)
+	err = fileop.Create(middleware.GetActor(c), pathData)
 	if err != nil {
-		log.Debugf("Error creating file: %s", err.Error())
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error creating file"})
+		switch err.Error() {
+		case "file_exists":
+			log.Debugf("File already exists: %s", pathData.FsPath)
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "File already exists"})
+		case "quota_exceeded":
+			c.AbortWithStatusJSON(http.StatusInsufficientStorage, gin.H{"error": "Quota exceeded"})
+		default:
+			log.Debugf("Error creating file: %s", err.Error())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error creating file"})
+		}
 		return
 	}
 
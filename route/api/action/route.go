@@ -3,11 +3,13 @@ package action
 
 import (
 	"github.com/gin-gonic/gin"
+
+	"github.com/vvbbnn00/goflet/middleware"
 )
 
 // RegisterRoutes load all the enabled routes for the application
 func RegisterRoutes(router *gin.RouterGroup) {
-	r := router.Group("/action")
+	r := router.Group("/action", middleware.RateLimiter("/api/action"))
 	{
 		// Register the routes
 		r.POST("/copy", routeCopyFile)
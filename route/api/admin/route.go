@@ -0,0 +1,203 @@
+// Package admin provides the routes for the admin API
+package admin
+
+import (
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vvbbnn00/goflet/admin"
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/middleware"
+	"github.com/vvbbnn00/goflet/storage/image"
+	"github.com/vvbbnn00/goflet/storage/scrub"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// ImportRequest is the request body for the import
+type ImportRequest struct {
+	// Source is the local folder on the server to import, should be under one of the configured import roots
+	Source string `json:"source" binding:"required"`
+	// Prefix is the path to store the files under
+	Prefix string `json:"prefix"`
+	// Owner is the subject owning the files, the subject of the token if empty
+	Owner string `json:"owner"`
+	// DryRun only reports the files to import
+	DryRun bool `json:"dryRun"`
+	// Parallel is the number of the files imported at the same time
+	Parallel int `json:"parallel"`
+	// Link hard links the files instead of copying them
+	Link bool `json:"link"`
+	// Resume skips the files imported by the interrupted import of the same source and prefix
+	Resume bool `json:"resume"`
+}
+
+// FsckRequest is the request body for the fsck
+type FsckRequest struct {
+	// Repair repairs the missing hashes and the misplaced files, and removes the leftovers
+	Repair bool `json:"repair"`
+	// Quarantine moves the files which cannot be repaired to the quarantine folder
+	Quarantine bool `json:"quarantine"`
+}
+
+// RegisterRoutes load all the enabled routes for the application
+func RegisterRoutes(router *gin.RouterGroup) {
+	r := router.Group("/admin")
+	{
+		// Register the routes
+		r.POST("/import", routeImport)
+		r.POST("/fsck", routeFsck)
+		r.GET("/image-cache", routeGetImageCache)
+		r.POST("/image-cache/clean", routeCleanImageCache)
+	}
+}
+
+// underImportRoot checks whether the folder is one of the import roots or under them
+func underImportRoot(dir string) bool {
+	for _, root := range config.GofletCfg.AdminConfig.ImportRoots {
+		root, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeImport handler for POST /admin/import
+// @Summary      Import Folder
+// @Description  Import the files of a local folder on the server, the folder should be under one of the configured import roots. The files are checked against the quotas like the uploads, the files exceeding them are reported as quota_exceeded in failed. The import returns when the files are stored, the hashes are computed in the background.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        body body ImportRequest true "Request body"
+// @Success      200  {object} admin.ImportResult	"OK"
+// @Failure      400  {object} string	"Bad request"
+// @Failure      401  {object} string	"Unauthorized"
+// @Failure      403  {object} string	"Folder not allowed"
+// @Failure      404  {object} string	"Folder not found"
+// @Router       /api/admin/import [post]
+// @Security	 Authorization
+func routeImport(c *gin.Context) {
+	var req ImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Debugf("Error binding request: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	source, err := filepath.Abs(req.Source)
+	if err != nil || !underImportRoot(source) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Folder not allowed"})
+		return
+	}
+	// The token bound to a bucket can only import into the bucket, the prefix is cleaned first so it cannot leave it
+	prefix := path.Clean("/" + req.Prefix)
+	if !middleware.CanAccessBucket(c, strings.TrimPrefix(prefix, "/")) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+		return
+	}
+	var bucket string
+	if claims := middleware.GetClaims(c); claims != nil {
+		bucket = claims.Bucket
+	}
+	if req.Owner == "" {
+		req.Owner = middleware.GetSubject(c)
+	}
+
+	result, err := admin.Import(admin.ImportOptions{
+		Dir:      source,
+		Prefix:   prefix,
+		Bucket:   bucket,
+		Owner:    req.Owner,
+		DryRun:   req.DryRun,
+		Parallel: req.Parallel,
+		Link:     req.Link,
+		Resume:   req.Resume,
+		Quota:    true, // The quotas of the token apply to the imports through the API
+	})
+	if err != nil {
+		if err.Error() == "folder_not_found" {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+			return
+		}
+		log.Warnf("Error importing folder: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error importing folder"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// routeFsck handler for POST /admin/fsck
+// @Summary      Check Storage
+// @Description  Verify the stored files against their hashes, find the misplaced files and the leftovers of the uploads and the image caches. The problems are fixed as the request allows.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        body body FsckRequest false "Request body"
+// @Success      200  {object} scrub.Report	"OK"
+// @Failure      400  {object} string	"Bad request"
+// @Failure      401  {object} string	"Unauthorized"
+// @Router       /api/admin/fsck [post]
+// @Security	 Authorization
+func routeFsck(c *gin.Context) {
+	var req FsckRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Debugf("Error binding request: %s", err.Error())
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+	}
+	// The scrub covers the whole storage, so the token bound to a bucket is not allowed
+	if claims := middleware.GetClaims(c); claims != nil && claims.Bucket != "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+		return
+	}
+
+	c.JSON(http.StatusOK, scrub.Run(scrub.Options{Repair: req.Repair, Quarantine: req.Quarantine}))
+}
+
+// routeGetImageCache handler for GET /admin/image-cache
+// @Summary      Get Image Cache Stats
+// @Description  Get the usage, the budget and the hits of the processed image cache
+// @Tags         Admin
+// @Produce      json
+// @Success      200  {object} image.ImageCacheStats	"OK"
+// @Failure      401  {object} string	"Unauthorized"
+// @Router       /api/admin/image-cache [get]
+// @Security	 Authorization
+func routeGetImageCache(c *gin.Context) {
+	// The cache is shared by all the buckets
+	if claims := middleware.GetClaims(c); claims != nil && claims.Bucket != "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+		return
+	}
+
+	c.JSON(http.StatusOK, image.GetImageCacheStats())
+}
+
+// routeCleanImageCache handler for POST /admin/image-cache/clean
+// @Summary      Clean Image Cache
+// @Description  Count the processed images and evict the least recently used ones over the budget now, instead of waiting for the scheduled cleanup
+// @Tags         Admin
+// @Produce      json
+// @Success      200  {object} image.ImageCacheStats	"OK"
+// @Failure      401  {object} string	"Unauthorized"
+// @Router       /api/admin/image-cache/clean [post]
+// @Security	 Authorization
+func routeCleanImageCache(c *gin.Context) {
+	// The cache is shared by all the buckets
+	if claims := middleware.GetClaims(c); claims != nil && claims.Bucket != "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+		return
+	}
+
+	c.JSON(http.StatusOK, image.CleanImageCache())
+}
@@ -0,0 +1,108 @@
+// Package events provides the routes for the event stream API
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event"
+	"github.com/vvbbnn00/goflet/event/stream"
+	"github.com/vvbbnn00/goflet/middleware"
+)
+
+// LastEventIDHeader is the header that contains the ID of the last event received by the client
+const LastEventIDHeader = "Last-Event-ID"
+
+// RegisterRoutes load all the enabled routes for the application
+func RegisterRoutes(router *gin.RouterGroup) {
+	if !stream.Enabled() {
+		return
+	}
+	router.GET("/events", routeGetEvents)
+}
+
+// routeGetEvents handler for GET /events
+// @Summary      Event Stream
+// @Description  Stream the changes of the files as server-sent events, only the events of the files the token can read via GET /file/{path} are sent. The id of each event can be sent back with the Last-Event-ID header or the lastEventId query to resume the stream
+// @Tags         Event
+// @Produce      text/event-stream
+// @Param        prefix query string false "Path prefix of the files, e.g. /projects/x"
+// @Param        lastEventId query int false "ID of the last event received, same as the Last-Event-ID header"
+// @Success      200  {object} event.Event	"OK"
+// @Router       /api/events [get]
+// @Security	 Authorization
+func routeGetEvents(c *gin.Context) {
+	prefix := c.Query("prefix")
+
+	lastEventID := c.GetHeader(LastEventIDHeader)
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
+	lastID, _ := strconv.ParseUint(lastEventID, 10, 64)
+
+	backlog, events, cancel := stream.Listen(lastID)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable the buffering of the reverse proxy
+	c.Status(http.StatusOK)
+
+	// visible checks whether the event should be sent to the client
+	visible := func(e event.Event) bool {
+		if !e.Under(prefix) {
+			return false
+		}
+		return middleware.CanReadFile(c, e.Path) ||
+			(e.SourcePath != "" && middleware.CanReadFile(c, e.SourcePath))
+	}
+
+	for _, e := range backlog {
+		if visible(e) {
+			writeEvent(c, e)
+		}
+	}
+	c.Writer.Flush()
+
+	// The heartbeat is disabled if the interval is 0 or less, the nil channel is never ready
+	var heartbeat <-chan time.Time
+	if interval := config.GofletCfg.EventStreamConfig.HeartbeatInterval; interval > 0 {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat:
+			_, _ = fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return // The client falls behind, it can reconnect with the last event ID
+			}
+			if visible(e) {
+				writeEvent(c, e)
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+// writeEvent writes the event in the server-sent events format
+func writeEvent(c *gin.Context, e event.Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
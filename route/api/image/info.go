@@ -0,0 +1,77 @@
+package image
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/image"
+	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// ImageInfo is the information of an image
+type ImageInfo struct {
+	model.ImageMeta
+	Exif *image.ExifInfo `json:"exif,omitempty"` // The EXIF information, only if exposeExif is enabled
+}
+
+// routeGetImageInfo handler for GET /image-info/*path
+// @Summary      Get Image Info
+// @Description  Get the size of the image as displayed, its format, number of frames, dominant colors, BlurHash and EXIF information, {path} should be the relative path of the file, starting from the root directory, e.g. /image-info/path/to/image.jpg
+// @Tags         Image
+// @Produce      json
+// @Param        path path string true "File path"
+// @Success      200  {object} ImageInfo	"OK"
+// @Failure      400  {object} string	"Bad request"
+// @Failure      404  {object} string	"File not found"
+// @Failure      415  {object} string	"Unsupported image format"
+// @Failure      500  {object} string	"Internal server error"
+// @Router       /api/image-info/{path} [get]
+// @Security	 Authorization
+func routeGetImageInfo(c *gin.Context) {
+	fsPath := c.GetString("fsPath")
+
+	fileInfo, err := storage.GetFileInfo(fsPath)
+	if err != nil || !fileInfo.IsImage() {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if !image.IsDecodable(fileInfo.FileMeta.MimeType) {
+		c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported image format"})
+		return
+	}
+
+	reader, err := storage.GetFileReader(fsPath)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error reading file"})
+		return
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	conf := util.GetImageConfig(c.GetString("relativePath"))
+	imageMeta := fileInfo.FileMeta.Image
+	if imageMeta == nil {
+		// Not analyzed yet, like the files uploaded before the analysis was added
+		imageMeta, err = image.AnalyzeImage(reader, conf)
+		if err != nil {
+			log.Warnf("Error analyzing image: %s", err.Error())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error processing image"})
+			return
+		}
+		if err = storage.SetImageMeta(fsPath, imageMeta); err != nil {
+			log.Warnf("Error updating file meta: %s", err.Error())
+		}
+	}
+
+	info := ImageInfo{ImageMeta: *imageMeta}
+	if *conf.ExposeExif {
+		info.Exif = image.ReadExifInfo(reader)
+	}
+
+	c.JSON(http.StatusOK, info)
+}
@@ -8,7 +8,6 @@ import (
 
 	"github.com/gin-gonic/gin"
 
-	"github.com/vvbbnn00/goflet/config"
 	"github.com/vvbbnn00/goflet/middleware"
 	"github.com/vvbbnn00/goflet/route/file"
 	"github.com/vvbbnn00/goflet/storage"
@@ -19,29 +18,47 @@ import (
 
 // RegisterRoutes load all the enabled routes for the application
 func RegisterRoutes(router *gin.RouterGroup) {
-	r := router.Group("/image", middleware.FilePathChecker())
+	r := router.Group("/image", middleware.RateLimiter("/api/image"), middleware.FilePathChecker())
 	{
 		// Register the routes
 		r.GET("/*rpath", routeGetImage)
 	}
+
+	// Can not be nested in /image, which has a catch-all path
+	info := router.Group("/image-info", middleware.RateLimiter("/api/image"), middleware.FilePathChecker())
+	{
+		info.GET("/*rpath", routeGetImageInfo)
+	}
 }
 
 // routeGetImage handler for GET /image/*path
 // @Summary      Get Image
 // @Description  Get processed image, {path} should be the relative path of the file, starting from the root directory, e.g. /image/path/to/image.jpg
 // @Tags         Image
-// @Produce      image/jpeg, image/png, image/gif
+// @Produce      image/jpeg, image/png, image/gif, image/webp, image/avif
 // @Param        path path string true "File path"
+// @Param        p query string false "Preset, the other parameters are ignored if set"
 // @Param        w query int false "Width"
 // @Param        h query int false "Height"
-// @Param        q query int false "Quality, 0-100"
-// @Param        f query string false "Format" Enums(jpg, png, gif)
+// @Param        q query int false "Quality, 0-100, 100 means lossless for webp and avif"
+// @Param        f query string false "Format, auto negotiates from the Accept header" Enums(jpeg, png, gif, webp, avif, auto)
 // @Param        a query int false "Angle, 0-360"
 // @Param        s query string false "Scale type" Enums(fit, fill, resize, fit_width, fit_height)
+// @Param        c query string false "Crop area of the source image, x,y,w,h"
+// @Param        g query string false "Gravity of the fill crop, face keeps the focal point of the file" Enums(center, north, south, east, west, northeast, northwest, southeast, southwest, face)
+// @Param        blur query number false "Sigma of the gaussian blur, limited by maxBlur"
+// @Param        sharpen query number false "Sigma of the sharpening, limited by maxSharpen"
+// @Param        grayscale query bool false "Convert to grayscale"
+// @Param        brightness query int false "Brightness change in percent, -100-100"
+// @Param        contrast query int false "Contrast change in percent, -100-100"
+// @Param        flipH query bool false "Flip horizontally"
+// @Param        flipV query bool false "Flip vertically"
+// @Param        wm query string false "Watermark preset, the preset in the token is always used"
 // @Success      200  {object} string	"OK"
 // @Failure      400  {object} string	"Bad request"
 // @Failure      404  {object} string	"File not found"
 // @Failure      413  {object} string	"File too large"
+// @Failure      415  {object} string	"Unsupported image format"
 // @Failure      500  {object} string	"Internal server error"
 // @Router       /api/image/{path} [get]
 // @Security	 Authorization
@@ -61,10 +78,39 @@ func routeGetImage(c *gin.Context) {
 		return
 	}
 
-	params := image.GetProcessParamsFromQuery(c.Request.URL.Query())
+	// Check if the image can be decoded
+	if !image.IsDecodable(fileInfo.FileMeta.MimeType) {
+		c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported image format"})
+		return
+	}
+
+	imageConfig := util.GetImageConfig(c.GetString("relativePath"))
+	accept := c.GetHeader("Accept")
+	params := image.GetProcessParamsFromQuery(c.Request.URL.Query(), accept, imageConfig)
+	if preset := c.Query("p"); preset != "" || *imageConfig.PresetsOnly {
+		// The presets bound the derivatives, the other parameters are ignored
+		var ok bool
+		if params, ok = image.GetProcessParamsFromPreset(preset, accept, imageConfig); !ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Unknown image preset"})
+			return
+		}
+	}
+	params.SetFocalPoint(fileInfo.FileMeta.FocalPoint)
+
+	// The watermark in the token can not be dropped by the viewer
+	if claims := middleware.GetClaims(c); claims != nil && claims.Watermark != "" {
+		if !params.SetWatermark(claims.Watermark, imageConfig) {
+			log.Warnf("Watermark preset not configured: %s", claims.Watermark)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Watermark not available"})
+			return
+		}
+	}
+	if params.Auto {
+		c.Header("Vary", "Accept")
+	}
 
 	// Check if the file is too large
-	if fileInfo.FileSize > config.GofletCfg.ImageConfig.MaxFileSize {
+	if fileInfo.FileSize > imageConfig.MaxFileSize {
 		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File too large"})
 		return
 	}
@@ -83,6 +129,7 @@ func routeGetImage(c *gin.Context) {
 		}()
 		// Set the content type
 		file.SetCommonHeaders(c, &cachedFileInfo)
+		c.Header("Content-Type", "image/"+string(params.Format))
 		c.Header("Content-Disposition", "")
 		c.Header("X-Cache", "HIT")
 		// Copy the file to the response
@@ -100,13 +147,21 @@ func routeGetImage(c *gin.Context) {
 		_ = reader.Close()
 	}()
 
-	imageProcessed, err := image.ProcessImage(reader, params)
+	imageProcessed, err := image.ProcessImage(reader, params, imageConfig)
 
 	if err != nil {
 		if err.Error() == "image size is too large" {
 			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image size is too large"})
 			return
 		}
+		if err.Error() == "invalid crop area" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid crop area"})
+			return
+		}
+		if err.Error() == "unsupported image format" {
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported image format"})
+			return
+		}
 		log.Warnf("Error processing image: %s", err.Error())
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error processing image"})
 		return
@@ -0,0 +1,67 @@
+// Package list provides the routes for the folder listing API
+package list
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vvbbnn00/goflet/middleware"
+	"github.com/vvbbnn00/goflet/storage/fileop"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// RegisterRoutes load all the enabled routes for the application
+func RegisterRoutes(router *gin.RouterGroup) {
+	r := router.Group("/list")
+	{
+		// Register the routes
+		r.GET("/*rpath", routeListFolder)
+	}
+}
+
+// routeListFolder handler for GET /list/*path
+// @Summary      List Folder
+// @Description  List the files and the folders in a folder sorted by name, {path} should be the relative path of the folder, starting from the root directory, e.g. /list/path/to/folder, /list/ lists the root
+// @Tags         File
+// @Produce      json
+// @Param        path path string true "Folder path"
+// @Success      200  {object} []index.Entry	"OK"
+// @Failure      400  {object} string	"Bad request"
+// @Failure      401  {object} string	"Unauthorized access"
+// @Failure      404  {object} string	"Folder not found"
+// @Router       /api/list/{path} [get]
+// @Security	 Authorization
+func routeListFolder(c *gin.Context) {
+	// The root is not a valid file path, so the path is only parsed below it
+	relativePath := ""
+	if path := c.Param("rpath"); strings.Trim(path, "/") != "" {
+		pathData, err := util.ParsePath(path)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		relativePath = pathData.RelativePath
+	}
+
+	// The token bound to a bucket can only access the bucket, the root is filtered instead
+	if relativePath != "" && !middleware.CanAccessBucket(c, relativePath) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+		return
+	}
+
+	bucket := ""
+	if claims := middleware.GetClaims(c); claims != nil {
+		bucket = claims.Bucket
+	}
+	entries, err := fileop.List(relativePath, bucket)
+	if err != nil {
+		log.Debugf("Error listing folder: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
@@ -10,6 +10,8 @@ import (
 
 	"github.com/vvbbnn00/goflet/middleware"
 	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/image"
+	"github.com/vvbbnn00/goflet/storage/model"
 	"github.com/vvbbnn00/goflet/util/log"
 )
 
@@ -19,9 +21,16 @@ func RegisterRoutes(router *gin.RouterGroup) {
 	{
 		// Register the routes
 		onlyOffice.GET("/*rpath", routeGetFileMeta)
+		onlyOffice.PUT("/*rpath", routeUpdateFileMeta)
 	}
 }
 
+// UpdateFileMetaRequest is the request body for updating the file meta
+type UpdateFileMetaRequest struct {
+	// FocalPoint is the point of interest of the image used by the fill crop, null clears it
+	FocalPoint *model.FocalPoint `json:"focalPoint"`
+}
+
 // routeGetFileMeta handler for GET /meta/*path
 // @Summary      Get File Meta
 // @Description  Get the file meta data, {path} should be the relative path of the file, starting from the root directory, e.g. /meta/path/to/file.txt
@@ -56,3 +65,59 @@ func routeGetFileMeta(c *gin.Context) {
 
 	c.JSON(http.StatusOK, fileInfo)
 }
+
+// routeUpdateFileMeta handler for PUT /meta/*path
+// @Summary      Update File Meta
+// @Description  Update the editable file meta data, {path} should be the relative path of the file, starting from the root directory, e.g. /meta/path/to/file.txt
+// @Tags         File
+// @Accept       json
+// @Produce      json
+// @Param        path path string true "File path"
+// @Param        body body UpdateFileMetaRequest true "Request body"
+// @Success      200  {object} model.FileInfo	"OK"
+// @Failure      400  {object} string	"Bad request"
+// @Failure      404  {object} string	"File not found"
+// @Failure      500  {object} string	"Internal server error"
+// @Router       /api/meta/{path} [put]
+// @Security	 Authorization
+func routeUpdateFileMeta(c *gin.Context) {
+	fsPath := c.GetString("fsPath")
+
+	var req UpdateFileMetaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Debugf("Error binding request: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if fp := req.FocalPoint; fp != nil && (fp.X < 0 || fp.X > 1 || fp.Y < 0 || fp.Y > 1) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Focal point should be between 0 and 1"})
+		return
+	}
+
+	if !storage.FileExists(fsPath) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	err := storage.SetFocalPoint(fsPath, req.FocalPoint)
+	if err != nil {
+		log.Warnf("Error updating file meta: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error updating file meta"})
+		return
+	}
+	// The derivatives cropped with the old focal point are outdated
+	image.RemoveImageCache(fsPath)
+
+	// Read the metadata from the disk, the cache is updated asynchronously
+	fileInfo, err := storage.GetFileInfo(fsPath)
+	if err == nil {
+		fileInfo.FileMeta, err = storage.LoadFileMeta(fsPath)
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error reading file meta"})
+		return
+	}
+	fileInfo.FilePath = filepath.ToSlash(c.GetString("relativePath"))
+
+	c.JSON(http.StatusOK, fileInfo)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/vvbbnn00/goflet/event"
 	"github.com/vvbbnn00/goflet/middleware"
 	"github.com/vvbbnn00/goflet/storage"
 	"github.com/vvbbnn00/goflet/storage/upload"
@@ -39,6 +40,7 @@ type onlyOfficeUpdateRequest struct {
 // @Failure      400  {object} string	"Bad request"
 // @Failure      404  {object} string	"File not found"
 // @Failure      500  {object} string	"Internal server error"
+// @Failure      507  {object} string	"Quota exceeded"
 // @Router       /api/onlyoffice/{path} [post]
 // @Security	 Authorization
 func routeUpdateFile(c *gin.Context) {
@@ -96,9 +98,13 @@ func routeUpdateFile(c *gin.Context) {
 	_ = file.Close()
 
 	// Complete the file upload
-	err = upload.CompleteFileUpload(relativePath)
+	err = upload.CompleteFileUpload(relativePath, middleware.GetActor(c), event.TypeOnlyOfficeSaved)
 	if err != nil {
 		errStr := err.Error()
+		if errStr == "quota_exceeded" {
+			c.AbortWithStatusJSON(http.StatusInsufficientStorage, gin.H{"error": "Quota exceeded"})
+			return
+		}
 		log.Warnf("Error completing file upload: %s", errStr)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error completing file upload"})
 		return
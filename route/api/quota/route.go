@@ -0,0 +1,48 @@
+// Package quota provides the routes for the quota API
+package quota
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vvbbnn00/goflet/middleware"
+	"github.com/vvbbnn00/goflet/storage/quota"
+)
+
+// RegisterRoutes load all the enabled routes for the application
+func RegisterRoutes(router *gin.RouterGroup) {
+	r := router.Group("/quota", middleware.FilePathChecker())
+	{
+		// Register the routes
+		r.GET("/*rpath", routeGetQuota)
+	}
+}
+
+// routeGetQuota handler for GET /quota/*prefix
+// @Summary      Get Quota
+// @Description  Get the limit and the current usage of the quota of a path prefix, {prefix} should be the configured prefix, e.g. /quota/tenant-a, the quota of the token subject is also returned if there is one
+// @Tags         Quota
+// @Produce      json
+// @Param        prefix path string true "Path prefix"
+// @Success      200  {object} quota.Report	"OK"
+// @Failure      400  {object} string	"Bad request"
+// @Failure      404  {object} string	"Quota not found"
+// @Router       /api/quota/{prefix} [get]
+// @Security	 Authorization
+func routeGetQuota(c *gin.Context) {
+	relativePath := c.GetString("relativePath")
+
+	report, ok := quota.GetPrefixReport(relativePath)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Quota not found"})
+		return
+	}
+
+	result := gin.H{"prefix": report}
+	if subjectReport, ok := quota.GetSubjectReport(middleware.GetSubject(c)); ok {
+		result["subject"] = subjectReport
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -7,9 +7,14 @@ import (
 	"github.com/vvbbnn00/goflet/route/api/action"
 
 	"github.com/vvbbnn00/goflet/middleware"
+	"github.com/vvbbnn00/goflet/route/api/admin"
+	"github.com/vvbbnn00/goflet/route/api/events"
 	"github.com/vvbbnn00/goflet/route/api/image"
+	"github.com/vvbbnn00/goflet/route/api/list"
 	"github.com/vvbbnn00/goflet/route/api/meta"
 	"github.com/vvbbnn00/goflet/route/api/onlyoffice"
+	"github.com/vvbbnn00/goflet/route/api/quota"
+	"github.com/vvbbnn00/goflet/route/api/webhook"
 )
 
 // RegisterRoutes load all the enabled routes for the application
@@ -18,7 +23,12 @@ func RegisterRoutes(router *gin.Engine) {
 	{
 		onlyoffice.RegisterRoutes(api)
 		meta.RegisterRoutes(api)
+		list.RegisterRoutes(api)
 		image.RegisterRoutes(api)
 		action.RegisterRoutes(api)
+		quota.RegisterRoutes(api)
+		webhook.RegisterRoutes(api)
+		events.RegisterRoutes(api)
+		admin.RegisterRoutes(api)
 	}
 }
@@ -0,0 +1,50 @@
+// Package webhook provides the routes for the webhook API
+package webhook
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vvbbnn00/goflet/event/webhook"
+	"github.com/vvbbnn00/goflet/middleware"
+)
+
+const defaultDeliveryLimit = 100 // The default number of the deliveries to return
+
+// RegisterRoutes load all the enabled routes for the application
+func RegisterRoutes(router *gin.RouterGroup) {
+	r := router.Group("/webhook")
+	{
+		// Register the routes
+		r.GET("/deliveries", routeGetDeliveries)
+	}
+}
+
+// routeGetDeliveries handler for GET /webhook/deliveries
+// @Summary      Get Webhook Deliveries
+// @Description  Get the latest webhook delivery attempts, the latest first, the tokens bound to a bucket are not allowed
+// @Tags         Webhook
+// @Produce      json
+// @Param        event query string false "Event type, e.g. file.uploaded"
+// @Param        id query string false "Delivery ID"
+// @Param        limit query int false "Maximum number of the deliveries, default 100"
+// @Success      200  {object} []webhook.Delivery	"OK"
+// @Failure      401  {object} string	"Unauthorized"
+// @Router       /api/webhook/deliveries [get]
+// @Security	 Authorization
+func routeGetDeliveries(c *gin.Context) {
+	// The deliveries are shared by all the buckets
+	if claims := middleware.GetClaims(c); claims != nil && claims.Bucket != "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultDeliveryLimit
+	}
+
+	c.JSON(http.StatusOK, webhook.GetDeliveries(c.Query("event"), c.Query("id"), limit))
+}
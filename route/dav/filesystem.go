@@ -0,0 +1,376 @@
+package dav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/webdav"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event"
+	"github.com/vvbbnn00/goflet/middleware"
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/fileop"
+	"github.com/vvbbnn00/goflet/storage/index"
+	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/storage/upload"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// fileSystem maps the WebDAV resources onto the stored files, the collections are the folders of the path index
+type fileSystem struct {
+	c *gin.Context
+}
+
+// resolve parses the name of the resource, returns nil for the root collection
+func (f *fileSystem) resolve(name string) (*util.Path, error) {
+	if strings.Trim(name, "/") == "" {
+		return nil, nil
+	}
+
+	pathData, err := util.ParsePath(name)
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+
+	// The token bound to a bucket can only access the bucket
+	if !middleware.CanAccessBucket(f.c, pathData.RelativePath) {
+		return nil, os.ErrPermission
+	}
+	return pathData, nil
+}
+
+// Mkdir creates the collection, the parent collection should exist
+func (f *fileSystem) Mkdir(_ context.Context, name string, _ os.FileMode) error {
+	pathData, err := f.resolve(name)
+	if err != nil {
+		return err
+	}
+	if pathData == nil || storage.FileExists(pathData.FsPath) || index.IsFolder(pathData.RelativePath) {
+		return os.ErrExist
+	}
+	if !*config.GofletCfg.FileConfig.AllowFolderCreation {
+		return os.ErrPermission
+	}
+
+	if dir := path.Dir(pathData.RelativePath); dir != "." && !index.IsFolder(dir) {
+		return os.ErrNotExist
+	}
+	return index.AddFolder(pathData.RelativePath)
+}
+
+// OpenFile opens the file for reading, or for writing through the upload pipeline
+func (f *fileSystem) OpenFile(_ context.Context, name string, flag int, _ os.FileMode) (webdav.File, error) {
+	pathData, err := f.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0 {
+		if pathData == nil || index.IsFolder(pathData.RelativePath) {
+			return nil, os.ErrExist
+		}
+		return f.openWriter(pathData)
+	}
+
+	if pathData == nil || index.IsFolder(pathData.RelativePath) {
+		return f.openDir(pathData)
+	}
+
+	info, err := storage.GetFileInfo(pathData.FsPath)
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+	file, err := storage.GetFileReader(pathData.FsPath)
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+	return &readFile{File: file, info: newFileInfo(pathData.RelativePath, info)}, nil
+}
+
+// openDir opens the collection for listing
+func (f *fileSystem) openDir(pathData *util.Path) (webdav.File, error) {
+	relativePath := ""
+	if pathData != nil {
+		relativePath = pathData.RelativePath
+	}
+
+	entries, ok := index.List(relativePath)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		// The root lists the buckets, only the accessible ones are shown
+		if relativePath == "" && !middleware.CanAccessBucket(f.c, entry.Path) {
+			continue
+		}
+		if entry.IsDir {
+			infos = append(infos, dirInfo{name: entry.Name})
+			continue
+		}
+		fsPath, err := util.RelativeToFsPath(entry.Path)
+		if err != nil {
+			continue
+		}
+		info, err := storage.GetFileInfo(fsPath)
+		if err != nil {
+			continue // The file is deleted after the listing
+		}
+		infos = append(infos, newFileInfo(entry.Path, info))
+	}
+	return &dirFile{info: dirInfo{name: path.Base("/" + relativePath)}, entries: infos}, nil
+}
+
+// openWriter creates a private temporary file of the upload, the upload is completed when the file is closed
+func (f *fileSystem) openWriter(pathData *util.Path) (webdav.File, error) {
+	file, err := upload.CreateObjectFile(pathData.RelativePath)
+	if err != nil {
+		if err.Error() == "directory_creation" {
+			return nil, os.ErrPermission
+		}
+		return nil, err
+	}
+
+	return &writeFile{
+		File:         file,
+		relativePath: pathData.RelativePath,
+		actor:        middleware.GetActor(f.c),
+		limit:        util.GetUploadLimit(pathData.RelativePath),
+	}, nil
+}
+
+// RemoveAll deletes the file, or the collection with all the files under it
+func (f *fileSystem) RemoveAll(_ context.Context, name string) error {
+	pathData, err := f.resolve(name)
+	if err != nil {
+		return err
+	}
+	if pathData == nil {
+		return os.ErrPermission
+	}
+	actor := middleware.GetActor(f.c)
+
+	if storage.FileExists(pathData.FsPath) {
+		return fileop.Delete(actor, pathData)
+	}
+	if !index.IsFolder(pathData.RelativePath) {
+		return os.ErrNotExist
+	}
+
+	for _, relativePath := range index.Files(pathData.RelativePath) {
+		filePath, err := util.ParsePath(relativePath)
+		if err != nil {
+			return err
+		}
+		if err := fileop.Delete(actor, filePath); err != nil && err.Error() != "file_not_found" {
+			return err
+		}
+	}
+	for _, folder := range index.Folders(pathData.RelativePath) {
+		index.RemoveFolder(folder)
+	}
+	return nil
+}
+
+// Rename moves the file, or the collection with all the files under it
+func (f *fileSystem) Rename(_ context.Context, oldName, newName string) error {
+	sourcePath, err := f.resolve(oldName)
+	if err != nil {
+		return err
+	}
+	targetPath, err := f.resolve(newName)
+	if err != nil {
+		return err
+	}
+	if sourcePath == nil || targetPath == nil {
+		return os.ErrPermission
+	}
+	actor := middleware.GetActor(f.c)
+
+	if storage.FileExists(sourcePath.FsPath) {
+		return fileop.Move(actor, sourcePath, targetPath, false)
+	}
+	if !index.IsFolder(sourcePath.RelativePath) {
+		return os.ErrNotExist
+	}
+	if strings.HasPrefix(targetPath.RelativePath+"/", sourcePath.RelativePath+"/") {
+		return os.ErrInvalid // The collection cannot be moved into itself
+	}
+
+	// Keep the empty folders, the other folders are created by the moved files
+	folders := index.Folders(sourcePath.RelativePath)
+	for _, folder := range folders {
+		if err := index.AddFolder(targetPath.RelativePath + strings.TrimPrefix(folder, sourcePath.RelativePath)); err != nil {
+			return err
+		}
+	}
+	for _, relativePath := range index.Files(sourcePath.RelativePath) {
+		source, err := util.ParsePath(relativePath)
+		if err != nil {
+			return err
+		}
+		target, err := util.ParsePath(targetPath.RelativePath + strings.TrimPrefix(relativePath, sourcePath.RelativePath))
+		if err != nil {
+			return err
+		}
+		if err := fileop.Move(actor, source, target, false); err != nil {
+			return err
+		}
+	}
+	for _, folder := range folders {
+		index.RemoveFolder(folder)
+	}
+	return nil
+}
+
+// Stat returns the information of the file or the collection
+func (f *fileSystem) Stat(_ context.Context, name string) (os.FileInfo, error) {
+	pathData, err := f.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if pathData == nil {
+		return dirInfo{name: "/"}, nil
+	}
+
+	if info, err := storage.GetFileInfo(pathData.FsPath); err == nil {
+		return newFileInfo(pathData.RelativePath, info), nil
+	}
+	if index.IsFolder(pathData.RelativePath) {
+		return dirInfo{name: path.Base(pathData.RelativePath)}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// fileInfo is the information of a stored file, the metadata provides the content type and the ETag
+type fileInfo struct {
+	name string
+	info model.FileInfo
+}
+
+// newFileInfo returns the information of the file at the relative path
+func newFileInfo(relativePath string, info model.FileInfo) *fileInfo {
+	return &fileInfo{name: path.Base(relativePath), info: info}
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.info.FileSize }
+func (i *fileInfo) Mode() os.FileMode  { return model.FilePerm }
+func (i *fileInfo) ModTime() time.Time { return time.Unix(i.info.LastModified, 0) }
+func (i *fileInfo) IsDir() bool        { return false }
+func (i *fileInfo) Sys() any           { return nil }
+
+// ContentType returns the mime type in the metadata
+func (i *fileInfo) ContentType(_ context.Context) (string, error) {
+	if i.info.FileMeta.MimeType == "" {
+		return "", webdav.ErrNotImplemented // Let the handler detect the content type
+	}
+	return i.info.FileMeta.MimeType, nil
+}
+
+// ETag returns the sha256 hash in the metadata, the hash may be not computed yet after the upload
+func (i *fileInfo) ETag(_ context.Context) (string, error) {
+	if i.info.FileMeta.Hash.HashSha256 == "" {
+		return "", webdav.ErrNotImplemented // Fall back to the modification time and the size
+	}
+	return `"` + i.info.FileMeta.Hash.HashSha256 + `"`, nil
+}
+
+// dirInfo is the information of a collection
+type dirInfo struct {
+	name string
+}
+
+func (i dirInfo) Name() string       { return i.name }
+func (i dirInfo) Size() int64        { return 0 }
+func (i dirInfo) Mode() os.FileMode  { return os.ModeDir | 0700 }
+func (i dirInfo) ModTime() time.Time { return time.Time{} }
+func (i dirInfo) IsDir() bool        { return true }
+func (i dirInfo) Sys() any           { return nil }
+
+// readFile is a stored file opened for reading
+type readFile struct {
+	*os.File
+	info *fileInfo
+}
+
+func (f *readFile) Readdir(int) ([]fs.FileInfo, error) { return nil, os.ErrInvalid }
+func (f *readFile) Stat() (fs.FileInfo, error)         { return f.info, nil }
+func (f *readFile) Write([]byte) (int, error)          { return 0, os.ErrPermission }
+
+// dirFile is a collection opened for listing
+type dirFile struct {
+	info    dirInfo
+	entries []os.FileInfo
+	offset  int
+}
+
+func (f *dirFile) Close() error                   { return nil }
+func (f *dirFile) Read([]byte) (int, error)       { return 0, os.ErrInvalid }
+func (f *dirFile) Seek(int64, int) (int64, error) { return 0, nil }
+func (f *dirFile) Stat() (fs.FileInfo, error)     { return f.info, nil }
+func (f *dirFile) Write([]byte) (int, error)      { return 0, os.ErrPermission }
+
+// Readdir returns the next entries of the collection, all the remaining entries if count is not positive
+func (f *dirFile) Readdir(count int) ([]fs.FileInfo, error) {
+	left := len(f.entries) - f.offset
+	if count <= 0 {
+		count = left
+	} else if left == 0 {
+		return nil, io.EOF
+	}
+	count = min(count, left)
+
+	entries := f.entries[f.offset : f.offset+count]
+	f.offset += count
+	return entries, nil
+}
+
+// writeFile is the temporary file of an upload
+type writeFile struct {
+	*os.File
+	relativePath string
+	actor        event.Actor
+	limit        int64
+	written      int64
+}
+
+func (f *writeFile) Readdir(int) ([]fs.FileInfo, error) { return nil, os.ErrInvalid }
+
+// Write writes to the temporary file, fails if the file exceeds the upload limit
+func (f *writeFile) Write(p []byte) (int, error) {
+	f.written += int64(len(p))
+	if f.limit > 0 && f.written > f.limit {
+		return 0, errors.New("file_too_large")
+	}
+	return f.File.Write(p)
+}
+
+// Close closes the temporary file and completes the upload
+func (f *writeFile) Close() error {
+	if err := f.File.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if f.limit > 0 && f.written > f.limit {
+		_ = os.Remove(f.Name())
+		return errors.New("file_too_large")
+	}
+
+	err := upload.CompleteObjectUpload(f.relativePath, f.Name(), f.actor, event.TypeFileUploaded)
+	if err != nil {
+		log.Debugf("Error completing upload: %s", err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Package dav provides the WebDAV endpoint, so the storage can be mounted as a network drive
+package dav
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/webdav"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/middleware"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// Prefix is the path prefix of the WebDAV endpoint
+const Prefix = "/dav"
+
+// methods are the WebDAV methods besides the ones of HTTP
+var methods = []string{"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK"}
+
+// lockSystem keeps the locks of the WebDAV resources in memory
+var lockSystem = webdav.NewMemLS()
+
+// RegisterRoutes load all the enabled routes for the application
+func RegisterRoutes(router *gin.Engine) {
+	if !*config.GofletCfg.WebDAVConfig.Enabled {
+		return
+	}
+
+	d := router.Group(Prefix,
+		challenge,
+		middleware.AuthChecker(),
+		middleware.RateLimiter(Prefix))
+	{
+		d.Any("/*rpath", routeDav)
+		for _, method := range methods {
+			d.Handle(method, "/*rpath", routeDav)
+		}
+	}
+}
+
+// challenge asks the clients to send the token as the password of the basic authentication
+func challenge(c *gin.Context) {
+	c.Set(middleware.ChallengeKey, "goflet")
+	c.Next()
+}
+
+// routeDav handler for the WebDAV requests to /dav/*path, the token should be authorized for the methods,
+// the token of COPY and MOVE should be authorized for the destination too
+func routeDav(c *gin.Context) {
+	if c.Request.Method == "COPY" || c.Request.Method == "MOVE" {
+		destination, err := url.Parse(c.GetHeader("Destination"))
+		if err == nil && !middleware.CanRequest(c, destination.Path, c.Request.Method) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+			return
+		}
+	}
+
+	handler := &webdav.Handler{
+		Prefix:     Prefix,
+		FileSystem: &fileSystem{c: c},
+		LockSystem: lockSystem,
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				log.Debugf("WebDAV %s %s: %s", r.Method, r.URL.Path, err.Error())
+			}
+		},
+	}
+	handler.ServeHTTP(c.Writer, c.Request)
+}
@@ -1,16 +1,19 @@
 package file
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/vvbbnn00/goflet/config"
 	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/image"
 	"github.com/vvbbnn00/goflet/storage/model"
 	"github.com/vvbbnn00/goflet/util"
 	"github.com/vvbbnn00/goflet/util/log"
@@ -24,8 +27,11 @@ import (
 // @Success      200  {object} string	"OK"
 // @Failure      400  {object} string	"Bad request"
 // @Failure      404  {object} string	"File not found"
+// @Failure      413  {object} string	"Image too large to be stripped"
+// @Failure      415  {object} string	"Only JPEG images can be stripped"
 // @Failure      500  {object} string	"Internal server error"
 // @Param        path path string true "File path"
+// @Param        stripExif query bool false "Serve the JPEG image without the EXIF, XMP and IPTC metadata, the EXIF orientation is applied, range requests are not supported"
 // @Router       /file/{path} [get]
 // @Router       /file/{path} [head]
 // @Header 200,206 {string} Content-Type "application/octet-stream"
@@ -47,6 +53,12 @@ func routeGetFile(c *gin.Context) {
 		return
 	}
 
+	// Serve the image without its metadata
+	if strip, _ := strconv.ParseBool(c.Query("stripExif")); strip {
+		serveStrippedImage(c, fsPath, &fileInfo)
+		return
+	}
+
 	// Set common headers
 	SetCommonHeaders(c, &fileInfo)
 
@@ -57,6 +69,7 @@ func routeGetFile(c *gin.Context) {
 
 	// For HEAD requests, return here after setting headers
 	if c.Request.Method == http.MethodHead {
+		c.Header("Content-Length", strconv.FormatInt(fileInfo.FileSize, 10))
 		return
 	}
 
@@ -75,10 +88,64 @@ func routeGetFile(c *gin.Context) {
 	handleRangeRequests(c, file, &fileInfo)
 }
 
+// serveStrippedImage serves the JPEG image without the metadata, which is generated on each request
+func serveStrippedImage(c *gin.Context, fsPath string, fileInfo *model.FileInfo) {
+	if fileInfo.FileMeta.MimeType != "image/jpeg" {
+		c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": "Only JPEG images can be stripped"})
+		return
+	}
+
+	// The image may be decoded, so the limits of the image processing apply
+	imageConfig := util.GetImageConfig(c.GetString("relativePath"))
+	if fileInfo.FileSize > imageConfig.MaxFileSize {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File too large"})
+		return
+	}
+
+	// The stripped image is another representation of the file
+	etag := strings.TrimSuffix(generateETag(fileInfo), `"`) + `-stripped"`
+	SetCommonHeaders(c, fileInfo)
+	c.Header("ETag", etag)
+	if canMakeFastResponse(c, fileInfo, etag) {
+		return
+	}
+
+	file, err := storage.GetFileReader(fsPath)
+	if err != nil {
+		log.Warnf("Error getting file reader: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error reading file"})
+		return
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	var buf bytes.Buffer
+	if err := image.StripExif(file, &buf, imageConfig); err != nil {
+		if err.Error() == "image size is too large" {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image size is too large"})
+			return
+		}
+		log.Warnf("Error stripping image: %s", err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error processing image"})
+		return
+	}
+
+	c.Header("Content-Length", strconv.Itoa(buf.Len()))
+	c.Status(http.StatusOK)
+	if c.Request.Method != http.MethodHead {
+		_, _ = io.Copy(c.Writer, &buf)
+	}
+}
+
 // CanMakeFastResponse checks if the request can be responded to without reading the file
 func CanMakeFastResponse(c *gin.Context, fileInfo *model.FileInfo) bool {
+	return canMakeFastResponse(c, fileInfo, generateETag(fileInfo))
+}
+
+// canMakeFastResponse checks if the request can be responded to without reading the file, with the ETag of the response
+func canMakeFastResponse(c *gin.Context, fileInfo *model.FileInfo, etag string) bool {
 	// Check ETag header
-	etag := generateETag(fileInfo)
 	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && ifMatch != etag {
 		c.Status(http.StatusPreconditionFailed)
 		return true
@@ -123,7 +190,7 @@ func handleRangeRequests(c *gin.Context, file *os.File, fileInfo *model.FileInfo
 	if rangeHeader == "" {
 		c.Header("Content-Length", strconv.FormatInt(fileInfo.FileSize, 10))
 		c.Status(http.StatusOK)
-		_, err := io.Copy(c.Writer, file)
+		_, err := io.Copy(throttleDownload(c, c.Writer), file)
 		if err != nil {
 			log.Warnf("Error copying file: %s", err.Error())
 		}
@@ -147,7 +214,7 @@ func handleRangeRequests(c *gin.Context, file *os.File, fileInfo *model.FileInfo
 	}
 
 	c.Status(http.StatusPartialContent)
-	_, err = io.CopyN(c.Writer, file, contentLength)
+	_, err = io.CopyN(throttleDownload(c, c.Writer), file, contentLength)
 	if err != nil {
 		log.Warnf("Error copying file: %s", err.Error())
 	}
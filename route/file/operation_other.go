@@ -7,9 +7,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 
-	"github.com/vvbbnn00/goflet/config"
-	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/event"
+	"github.com/vvbbnn00/goflet/middleware"
+	"github.com/vvbbnn00/goflet/storage/fileop"
 	"github.com/vvbbnn00/goflet/storage/upload"
+	"github.com/vvbbnn00/goflet/util"
 	"github.com/vvbbnn00/goflet/util/log"
 )
 
@@ -26,11 +28,12 @@ import (
 // @Failure      409  {object} string	"File completion in progress"
 // @Failure      413  {object} string	"File too large, please use PUT method to upload large files"
 // @Failure      500  {object} string	"Internal server error"
+// @Failure      507  {object} string	"Quota exceeded"
 // @Router       /file/{path} [post]
 // @Security	 Authorization
 func routePostFile(c *gin.Context) {
-	// Set the request body limit
-	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.GofletCfg.FileConfig.MaxPostSize)
+	// Set the request body limit and the upload bandwidth
+	c.Request.Body = throttleUpload(c, http.MaxBytesReader(c.Writer, c.Request.Body, util.GetMaxPostSize(c.GetString("relativePath"))))
 	file, err := c.FormFile("file")
 
 	// If error is not nil and the error is "http: request body too large", return a 413 status code
@@ -66,9 +69,12 @@ func routePostFile(c *gin.Context) {
 // @Router       /file/{path} [delete]
 // @Security	 Authorization
 func routeDeleteFile(c *gin.Context) {
-	fsPath := c.GetString("fsPath")
+	pathData := &util.Path{
+		FsPath:       c.GetString("fsPath"),
+		RelativePath: c.GetString("relativePath"),
+	}
 
-	err := storage.DeleteFile(fsPath)
+	err := fileop.Delete(middleware.GetActor(c), pathData)
 	if err != nil {
 		errStr := err.Error()
 		if errStr == "file_not_found" {
@@ -125,9 +131,13 @@ func handleSingleFileUpload(file *multipart.FileHeader, c *gin.Context) error {
 
 // handleCompleteFileUpload handles the completion of the file upload
 func handleCompleteFileUpload(relativePath string, c *gin.Context) {
-	err := upload.CompleteFileUpload(relativePath)
+	err := upload.CompleteFileUpload(relativePath, middleware.GetActor(c), event.TypeFileUploaded)
 	if err != nil {
 		errStr := err.Error()
+		if errStr == "quota_exceeded" {
+			c.AbortWithStatusJSON(http.StatusInsufficientStorage, gin.H{"error": "Quota exceeded"})
+			return
+		}
 		if errStr == "file_uploading" {
 			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "The file completion is in progress"})
 			return
@@ -5,7 +5,7 @@ import (
 	"net/http"
 	"os"
 
-	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/middleware"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
@@ -26,20 +26,34 @@ import (
 // @Failure      403  {object} string	"Directory creation not allowed"
 // @Failure		 413  {object} string   "File too large"
 // @Failure      500  {object} string	"Internal server error"
+// @Failure      507  {object} string	"Quota exceeded"
 // @Router       /upload/{path} [put]
 // @Security	 Authorization
 func routePutUpload(c *gin.Context) {
-	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.GofletCfg.FileConfig.MaxPostSize)
-
 	relativePath := c.GetString("relativePath")
 
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, util.GetMaxPostSize(relativePath))
+
 	// Parse the range
-	byteStart, byteEnd, _, err := util.HeaderParseRangeUpload(c.GetHeader("Content-Range"), c.GetHeader("Content-Length"))
+	byteStart, byteEnd, total, err := util.HeaderParseRangeUpload(c.GetHeader("Content-Range"), c.GetHeader("Content-Length"), util.GetUploadLimit(relativePath))
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Check if the file exceeds the quota
+	err = upload.CheckQuota(relativePath, middleware.GetSubject(c), total)
+	if err != nil {
+		errStr := err.Error()
+		if errStr == "quota_exceeded" {
+			c.AbortWithStatusJSON(http.StatusInsufficientStorage, gin.H{"error": "Quota exceeded"})
+			return
+		}
+		log.Warnf("Error checking quota: %s", errStr)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error checking quota"})
+		return
+	}
+
 	// Get the write stream
 	writeStream, err := upload.GetTempFileWriteStream(relativePath)
 	if err != nil {
@@ -58,8 +72,8 @@ func routePutUpload(c *gin.Context) {
 		}
 	}()
 
-	// Write the file
-	body := c.Request.Body
+	// Write the file, the body is limited by the upload bandwidth
+	body := throttleUpload(c, c.Request.Body)
 	defer func(body io.ReadCloser) {
 		_ = body.Close()
 	}(body)
@@ -105,6 +119,7 @@ func routePutUpload(c *gin.Context) {
 // @Failure      404  {object} string	"File not found or upload not started"
 // @Failure      409  {object} string	"File completion in progress"
 // @Failure      500  {object} string	"Internal server error"
+// @Failure      507  {object} string	"Quota exceeded"
 // @Router       /upload/{path} [post]
 // @Security	 Authorization
 func routePostUpload(c *gin.Context) {
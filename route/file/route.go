@@ -11,6 +11,7 @@ import (
 func RegisterRoutes(router *gin.Engine) {
 	f := router.Group("/file",
 		middleware.AuthChecker(),
+		middleware.RateLimiter("/file"),
 		middleware.FilePathChecker())
 	{
 		// Register the routes for file operations
@@ -22,6 +23,7 @@ func RegisterRoutes(router *gin.Engine) {
 
 	u := router.Group("/upload",
 		middleware.AuthChecker(),
+		middleware.RateLimiter("/upload"),
 		middleware.FilePathChecker())
 	{
 		// Register the routes for partial file upload
@@ -0,0 +1,37 @@
+package file
+
+import (
+	"io"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/middleware"
+	"github.com/vvbbnn00/goflet/util/throttle"
+)
+
+// perConnectionBps returns the bandwidth of the connection, the token claim overrides the configured value
+func perConnectionBps(c *gin.Context, configured int64) int64 {
+	if claims := middleware.GetClaims(c); claims != nil && claims.RateLimitBps > 0 {
+		return claims.RateLimitBps
+	}
+	return configured
+}
+
+// throttleDownload wraps the writer with the download bandwidth limiters
+func throttleDownload(c *gin.Context, w io.Writer) io.Writer {
+	perConnBps := perConnectionBps(c, config.GofletCfg.BandwidthConfig.DownloadPerConnBps)
+	return throttle.NewWriter(w, throttle.GlobalDownload, throttle.NewLimiter(perConnBps))
+}
+
+// throttleUpload wraps the reader with the upload bandwidth limiters
+func throttleUpload(c *gin.Context, r io.ReadCloser) io.ReadCloser {
+	perConnBps := perConnectionBps(c, config.GofletCfg.BandwidthConfig.UploadPerConnBps)
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: throttle.NewReader(r, throttle.GlobalUpload, throttle.NewLimiter(perConnBps)),
+		Closer: r,
+	}
+}
@@ -3,6 +3,7 @@ package route
 
 import (
 	"io"
+	"net/http"
 	"os"
 	"time"
 
@@ -18,7 +19,9 @@ import (
 	"github.com/vvbbnn00/goflet/config"
 	"github.com/vvbbnn00/goflet/middleware"
 	"github.com/vvbbnn00/goflet/route/api"
+	"github.com/vvbbnn00/goflet/route/dav"
 	"github.com/vvbbnn00/goflet/route/file"
+	"github.com/vvbbnn00/goflet/util"
 )
 
 // RegisterRoutes load all the enabled routes for the application
@@ -44,18 +47,25 @@ func RegisterRoutes() *gin.Engine {
 	// Enable CORS
 	corsConfig := config.GofletCfg.HTTPConfig.Cors
 	if *corsConfig.Enabled {
-		router.Use(cors.New(cors.Config{
+		conf := cors.Config{
 			AllowOrigins:     corsConfig.Origins,
 			AllowMethods:     corsConfig.Methods,
 			AllowHeaders:     corsConfig.Headers,
 			AllowCredentials: false,
 			MaxAge:           12 * time.Hour,
-		}))
+		}
+		// Each bucket has its own allowed origins
+		if util.BucketsEnabled() {
+			conf.AllowOrigins = nil
+			conf.AllowOriginWithContextFunc = allowBucketOrigin
+		}
+		router.Use(cors.New(conf))
 	}
 
 	// Register the routes
 	file.RegisterRoutes(router)
 	api.RegisterRoutes(router)
+	dav.RegisterRoutes(router)
 
 	// Enable swagger doc if it is enabled
 	if *config.GofletCfg.SwaggerEnabled {
@@ -65,3 +75,30 @@ func RegisterRoutes() *gin.Engine {
 
 	return router
 }
+
+// allowBucketOrigin checks whether the origin is allowed by the bucket of the request, the preflight requests
+// carry no path parameter, so they are allowed if any bucket allows the origin
+func allowBucketOrigin(c *gin.Context, origin string) bool {
+	if _, bucket, ok := util.GetBucket(c.Param("rpath")); ok {
+		return originAllowed(origin, bucket.CorsOrigins)
+	}
+
+	if c.Request.Method == http.MethodOptions {
+		for _, bucket := range config.GofletCfg.BucketConfig.Buckets {
+			if originAllowed(origin, bucket.CorsOrigins) {
+				return true
+			}
+		}
+	}
+	return originAllowed(origin, config.GofletCfg.HTTPConfig.Cors.Origins)
+}
+
+// originAllowed checks whether the origin is in the allowed origins
+func originAllowed(origin string, origins []string) bool {
+	for _, o := range origins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/vvbbnn00/goflet/route/rpc/pb"
+	"github.com/vvbbnn00/goflet/storage/fileop"
+	"github.com/vvbbnn00/goflet/util"
+)
+
+// parseCopyMoveRequest parses the source and target paths of the copy and move actions
+func parseCopyMoveRequest(ctx context.Context, req *pb.CopyMoveRequest, route string) (*util.Path, *util.Path, error) {
+	if err := authorize(ctx, route, http.MethodPost); err != nil {
+		return nil, nil, err
+	}
+	sourcePath, err := parsePath(ctx, req.GetSourcePath())
+	if err != nil {
+		return nil, nil, err
+	}
+	targetPath, err := parsePath(ctx, req.GetTargetPath())
+	if err != nil {
+		return nil, nil, err
+	}
+	return sourcePath, targetPath, nil
+}
+
+// Copy handler for the copy action, like POST /api/action/copy
+func (s *server) Copy(ctx context.Context, req *pb.CopyMoveRequest) (*pb.ActionResponse, error) {
+	sourcePath, targetPath, err := parseCopyMoveRequest(ctx, req, "/api/action/copy")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fileop.Copy(getActor(ctx), sourcePath, targetPath, req.GetOverwrite()); err != nil {
+		return nil, statusError(err, "Error copying file")
+	}
+	return &pb.ActionResponse{Message: "File copied"}, nil
+}
+
+// Move handler for the move action, like POST /api/action/move
+func (s *server) Move(ctx context.Context, req *pb.CopyMoveRequest) (*pb.ActionResponse, error) {
+	sourcePath, targetPath, err := parseCopyMoveRequest(ctx, req, "/api/action/move")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fileop.Move(getActor(ctx), sourcePath, targetPath, req.GetOverwrite()); err != nil {
+		return nil, statusError(err, "Error moving file")
+	}
+	return &pb.ActionResponse{Message: "File moved"}, nil
+}
+
+// Create handler for the create action, like POST /api/action/create
+func (s *server) Create(ctx context.Context, req *pb.CreateRequest) (*pb.ActionResponse, error) {
+	if err := authorize(ctx, "/api/action/create", http.MethodPost); err != nil {
+		return nil, err
+	}
+	pathData, err := parsePath(ctx, req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fileop.Create(getActor(ctx), pathData); err != nil {
+		return nil, statusError(err, "Error creating file")
+	}
+	return &pb.ActionResponse{Message: "File created"}, nil
+}
@@ -0,0 +1,127 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event"
+	"github.com/vvbbnn00/goflet/middleware"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// claimsKey is the context key of the parsed JWT claims
+type claimsKey struct{}
+
+// extractToken extracts the JWT token from the authorization metadata, the Bearer prefix is optional
+func extractToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(strings.ToLower(middleware.AuthHeader))
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(values[0], middleware.Bearer)
+}
+
+// authenticate parses the token of the call, the permissions are checked by each call against its HTTP route
+func authenticate(ctx context.Context) (context.Context, error) {
+	if !*config.GofletCfg.JWTConfig.Enabled {
+		return ctx, nil
+	}
+
+	token := extractToken(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "Missing token")
+	}
+	claims, err := util.ParseJwtToken(token)
+	if err != nil {
+		log.Debugf("Error parsing token: %s", err.Error())
+		return nil, status.Error(codes.Unauthenticated, "Invalid token")
+	}
+	return context.WithValue(ctx, claimsKey{}, claims), nil
+}
+
+// unaryAuthenticator authenticates the unary calls
+func unaryAuthenticator(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authenticatedStream replaces the context of the stream with the authenticated one
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// streamAuthenticator authenticates the streaming calls
+func streamAuthenticator(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// getClaims returns the parsed JWT claims of the call, nil if JWT is disabled
+func getClaims(ctx context.Context) *util.JwtClaims {
+	claims, _ := ctx.Value(claimsKey{}).(*util.JwtClaims)
+	return claims
+}
+
+// getActor returns the client of the call for the events
+func getActor(ctx context.Context) event.Actor {
+	var actor event.Actor
+	if p, ok := peer.FromContext(ctx); ok {
+		actor.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(actor.IP); err == nil {
+			actor.IP = host
+		}
+	}
+	if claims := getClaims(ctx); claims != nil && claims.StandardClaims != nil {
+		actor.Subject = claims.Subject
+		actor.Issuer = claims.Issuer
+		actor.TokenID = claims.Id
+	}
+	return actor
+}
+
+// authorize checks whether the call is authorized like the request to the HTTP route with the method
+func authorize(ctx context.Context, route string, method string) error {
+	if !middleware.ClaimsCanRequest(getClaims(ctx), route, method) {
+		return status.Error(codes.PermissionDenied, "Unauthorized access")
+	}
+	return nil
+}
+
+// parsePath parses the path of the call, the token bound to a bucket can only access the bucket
+func parsePath(ctx context.Context, path string) (*util.Path, error) {
+	if path == "" {
+		return nil, status.Error(codes.InvalidArgument, "Path is required")
+	}
+	pathData, err := util.ParsePath(path)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !middleware.ClaimsCanAccessBucket(getClaims(ctx), pathData.RelativePath) {
+		return nil, status.Error(codes.PermissionDenied, "Unauthorized access")
+	}
+	return pathData, nil
+}
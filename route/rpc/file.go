@@ -0,0 +1,279 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event"
+	"github.com/vvbbnn00/goflet/route/rpc/pb"
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/fileop"
+	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/storage/upload"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/log"
+	"github.com/vvbbnn00/goflet/util/throttle"
+)
+
+// statusError converts the error of the storage to the status of the call, the messages are the ones of the HTTP API
+func statusError(err error, message string) error {
+	switch err.Error() {
+	case "file_not_found":
+		return status.Error(codes.NotFound, "File not found")
+	case "source_file_not_found":
+		return status.Error(codes.NotFound, "Source file not found")
+	case "folder_not_found":
+		return status.Error(codes.NotFound, "Folder not found")
+	case "file_exists":
+		return status.Error(codes.AlreadyExists, "File already exists")
+	case "quota_exceeded":
+		return status.Error(codes.ResourceExhausted, "Quota exceeded")
+	case "same_path":
+		return status.Error(codes.InvalidArgument, "Source and target paths are the same")
+	case "different_buckets":
+		return status.Error(codes.InvalidArgument, "Source and target paths are in different buckets")
+	case "file_uploading":
+		return status.Error(codes.Aborted, "The file completion is in progress")
+	case "directory_creation":
+		return status.Error(codes.PermissionDenied, "Directory creation not allowed")
+	}
+	log.Warnf("%s: %s", message, err.Error())
+	return status.Error(codes.Internal, message)
+}
+
+// perConnectionBps returns the bandwidth of the call, the token claim overrides the configured value
+func perConnectionBps(ctx context.Context, configured int64) int64 {
+	if claims := getClaims(ctx); claims != nil && claims.RateLimitBps > 0 {
+		return claims.RateLimitBps
+	}
+	return configured
+}
+
+// toFileInfo converts the file info to the message, the path is the relative one
+func toFileInfo(relativePath string, info model.FileInfo) *pb.FileInfo {
+	meta := info.FileMeta
+	return &pb.FileInfo{
+		FilePath:     relativePath,
+		FileSize:     info.FileSize,
+		LastModified: info.LastModified,
+		FileName:     meta.FileName,
+		MimeType:     meta.MimeType,
+		UploadedAt:   meta.UploadedAt,
+		Owner:        meta.Owner,
+		Hash: &pb.FileHash{
+			Sha1:   meta.Hash.HashSha1,
+			Sha256: meta.Hash.HashSha256,
+			Md5:    meta.Hash.HashMd5,
+		},
+	}
+}
+
+// Upload handler for the upload stream, like POST /file/{path}, the file is in place when the call returns
+func (s *server) Upload(stream pb.FileService_UploadServer) error {
+	ctx := stream.Context()
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	pathData, err := parsePath(ctx, first.GetPath())
+	if err != nil {
+		return err
+	}
+	if err := authorize(ctx, "/file/"+pathData.RelativePath, http.MethodPost); err != nil {
+		return err
+	}
+
+	relativePath := pathData.RelativePath
+	tmpPath, err := receiveFile(stream, relativePath)
+	if err != nil {
+		return err
+	}
+
+	err = upload.CompleteObjectUpload(relativePath, tmpPath, getActor(ctx), event.TypeFileUploaded)
+	if err != nil {
+		return statusError(err, "Error completing file upload")
+	}
+
+	info, err := storage.GetFileInfo(pathData.FsPath)
+	if err != nil {
+		return statusError(err, "Error getting file info")
+	}
+	return stream.SendAndClose(&pb.UploadResponse{Info: toFileInfo(relativePath, info)})
+}
+
+// receiveFile writes the chunks of the upload stream to a private temporary file of the upload, returns the path
+// of the file, the file is removed if the stream fails
+func receiveFile(stream pb.FileService_UploadServer, relativePath string) (string, error) {
+	writeStream, err := upload.CreateObjectFile(relativePath)
+	if err != nil {
+		return "", statusError(err, "Error writing file")
+	}
+	err = writeChunks(stream, writeStream, relativePath)
+	if closeErr := writeStream.Close(); err == nil && closeErr != nil {
+		err = statusError(closeErr, "Error writing file")
+	}
+	if err != nil {
+		_ = os.Remove(writeStream.Name())
+		return "", err
+	}
+	return writeStream.Name(), nil
+}
+
+// writeChunks writes the chunks of the upload stream to the file
+func writeChunks(stream pb.FileService_UploadServer, writeStream *os.File, relativePath string) error {
+	perConnBps := perConnectionBps(stream.Context(), config.GofletCfg.BandwidthConfig.UploadPerConnBps)
+	writer := throttle.NewWriter(writeStream, throttle.GlobalUpload, throttle.NewLimiter(perConnBps))
+	limit := util.GetUploadLimit(relativePath)
+	var size int64
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		chunk := req.GetChunk()
+		size += int64(len(chunk))
+		if limit > 0 && size > limit {
+			return status.Error(codes.InvalidArgument, "File too large")
+		}
+		if _, err := writer.Write(chunk); err != nil {
+			return statusError(err, "Error writing file")
+		}
+	}
+}
+
+// chunkWriter sends the content written to it in the download stream
+type chunkWriter struct {
+	stream pb.FileService_DownloadServer
+}
+
+func (w chunkWriter) Write(p []byte) (int, error) {
+	if err := w.stream.Send(&pb.DownloadResponse{Data: &pb.DownloadResponse_Chunk{Chunk: p}}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Download handler for the download stream, like GET /file/{path} with a range
+func (s *server) Download(req *pb.DownloadRequest, stream pb.FileService_DownloadServer) error {
+	ctx := stream.Context()
+	pathData, err := parsePath(ctx, req.GetPath())
+	if err != nil {
+		return err
+	}
+	if err := authorize(ctx, "/file/"+pathData.RelativePath, http.MethodGet); err != nil {
+		return err
+	}
+
+	info, err := storage.GetFileInfo(pathData.FsPath)
+	if err != nil {
+		log.Debugf("Error getting file info: %s", err.Error())
+		return status.Error(codes.NotFound, "File not found")
+	}
+
+	// The length of 0 downloads to the end of the file
+	offset, length := req.GetOffset(), req.GetLength()
+	if offset < 0 || length < 0 || offset > info.FileSize {
+		return status.Error(codes.OutOfRange, "Invalid range")
+	}
+	if length == 0 || offset+length > info.FileSize {
+		length = info.FileSize - offset
+	}
+
+	file, err := storage.GetFileReader(pathData.FsPath)
+	if err != nil {
+		return statusError(err, "Error reading file")
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return statusError(err, "Error reading file")
+	}
+
+	err = stream.Send(&pb.DownloadResponse{Data: &pb.DownloadResponse_Info{Info: toFileInfo(pathData.RelativePath, info)}})
+	if err != nil {
+		return err
+	}
+
+	perConnBps := perConnectionBps(ctx, config.GofletCfg.BandwidthConfig.DownloadPerConnBps)
+	writer := throttle.NewWriter(chunkWriter{stream: stream}, throttle.GlobalDownload, throttle.NewLimiter(perConnBps))
+	_, err = io.CopyBuffer(writer, io.LimitReader(file, length), make([]byte, chunkSize))
+	return err
+}
+
+// GetMeta handler for the file info, like GET /api/meta/{path}
+func (s *server) GetMeta(ctx context.Context, req *pb.GetMetaRequest) (*pb.FileInfo, error) {
+	pathData, err := parsePath(ctx, req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(ctx, "/api/meta/"+pathData.RelativePath, http.MethodGet); err != nil {
+		return nil, err
+	}
+
+	info, err := storage.GetFileInfo(pathData.FsPath)
+	if err != nil {
+		log.Debugf("Error getting file info: %s", err.Error())
+		return nil, status.Error(codes.NotFound, "File not found")
+	}
+	return toFileInfo(pathData.RelativePath, info), nil
+}
+
+// Delete handler for the file deletion, like DELETE /file/{path}
+func (s *server) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.ActionResponse, error) {
+	pathData, err := parsePath(ctx, req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(ctx, "/file/"+pathData.RelativePath, http.MethodDelete); err != nil {
+		return nil, err
+	}
+
+	if err := fileop.Delete(getActor(ctx), pathData); err != nil {
+		return nil, statusError(err, "Error deleting file")
+	}
+	return &pb.ActionResponse{Message: "File deleted"}, nil
+}
+
+// List handler for the folder listing, like GET /api/list/{path}
+func (s *server) List(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
+	// The root is not a valid file path, so the path is only parsed below it
+	relativePath := ""
+	if strings.Trim(req.GetPath(), "/") != "" {
+		pathData, err := parsePath(ctx, req.GetPath())
+		if err != nil {
+			return nil, err
+		}
+		relativePath = pathData.RelativePath
+	}
+	if err := authorize(ctx, "/api/list/"+relativePath, http.MethodGet); err != nil {
+		return nil, err
+	}
+
+	bucket := ""
+	if claims := getClaims(ctx); claims != nil {
+		bucket = claims.Bucket
+	}
+	entries, err := fileop.List(relativePath, bucket)
+	if err != nil {
+		return nil, statusError(err, "Error listing folder")
+	}
+
+	resp := &pb.ListResponse{Entries: make([]*pb.Entry, 0, len(entries))}
+	for _, entry := range entries {
+		resp.Entries = append(resp.Entries, &pb.Entry{Name: entry.Name, Path: entry.Path, IsDir: entry.IsDir})
+	}
+	return resp, nil
+}
@@ -0,0 +1,4 @@
+// Package pb provides the protobuf messages and the gRPC stubs of the file service
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative goflet.proto
@@ -0,0 +1,165 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/route/rpc/pb"
+	"github.com/vvbbnn00/goflet/util"
+)
+
+var client pb.FileServiceClient
+
+func init() {
+	config.InitConfig()
+	*config.GofletCfg.JWTConfig.Enabled = false
+
+	listener := bufconn.Listen(1024 * 1024)
+	go func() {
+		_ = NewServer().Serve(listener)
+	}()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		panic(err)
+	}
+	client = pb.NewFileServiceClient(conn)
+}
+
+// uploadFile uploads the content to the path in two chunks
+func uploadFile(ctx context.Context, path string, content []byte) (*pb.UploadResponse, error) {
+	stream, err := client.Upload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	requests := []*pb.UploadRequest{
+		{Data: &pb.UploadRequest_Path{Path: path}},
+		{Data: &pb.UploadRequest_Chunk{Chunk: content[:len(content)/2]}},
+		{Data: &pb.UploadRequest_Chunk{Chunk: content[len(content)/2:]}},
+	}
+	for _, req := range requests {
+		if err := stream.Send(req); err != nil {
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// downloadFile downloads the range of the file, returns the file info and the content
+func downloadFile(ctx context.Context, path string, offset, length int64) (*pb.FileInfo, []byte, error) {
+	stream, err := client.Download(ctx, &pb.DownloadRequest{Path: path, Offset: offset, Length: length})
+	if err != nil {
+		return nil, nil, err
+	}
+	var info *pb.FileInfo
+	var content bytes.Buffer
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return info, content.Bytes(), nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if resp.GetInfo() != nil {
+			info = resp.GetInfo()
+		}
+		content.Write(resp.GetChunk())
+	}
+}
+
+func TestFileService(t *testing.T) {
+	ctx := context.Background()
+	folder := "/grpc/" + util.RandomString(8)
+	content := bytes.Repeat([]byte("goflet"), 20000)
+
+	resp, err := uploadFile(ctx, folder+"/a.txt", content)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(len(content)), resp.GetInfo().GetFileSize())
+	assert.Equal(t, "a.txt", resp.GetInfo().GetFileName())
+
+	info, data, err := downloadFile(ctx, folder+"/a.txt", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(content)), info.GetFileSize())
+	assert.Equal(t, content, data)
+
+	_, data, err = downloadFile(ctx, folder+"/a.txt", 6, 12)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("gofletgoflet"), data)
+
+	_, _, err = downloadFile(ctx, folder+"/missing.txt", 0, 0)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	meta, err := client.GetMeta(ctx, &pb.GetMetaRequest{Path: folder + "/a.txt"})
+	assert.NoError(t, err)
+	assert.Equal(t, folder[1:]+"/a.txt", meta.GetFilePath())
+
+	_, err = client.Copy(ctx, &pb.CopyMoveRequest{SourcePath: folder + "/a.txt", TargetPath: folder + "/b.txt"})
+	assert.NoError(t, err)
+	_, err = client.Copy(ctx, &pb.CopyMoveRequest{SourcePath: folder + "/a.txt", TargetPath: folder + "/b.txt"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	_, err = client.Move(ctx, &pb.CopyMoveRequest{SourcePath: folder + "/b.txt", TargetPath: folder + "/sub/c.txt"})
+	assert.NoError(t, err)
+	_, err = client.Create(ctx, &pb.CreateRequest{Path: folder + "/empty.txt"})
+	assert.NoError(t, err)
+
+	list, err := client.List(ctx, &pb.ListRequest{Path: folder})
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range list.GetEntries() {
+		names = append(names, entry.GetName())
+	}
+	assert.Equal(t, []string{"a.txt", "empty.txt", "sub"}, names)
+
+	for _, name := range []string{"a.txt", "sub/c.txt", "empty.txt"} {
+		_, err = client.Delete(ctx, &pb.DeleteRequest{Path: folder + "/" + name})
+		assert.NoError(t, err)
+	}
+	_, err = client.Delete(ctx, &pb.DeleteRequest{Path: folder + "/a.txt"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestAuth(t *testing.T) {
+	*config.GofletCfg.JWTConfig.Enabled = true
+	defer func() {
+		*config.GofletCfg.JWTConfig.Enabled = false
+	}()
+
+	_, err := client.GetMeta(context.Background(), &pb.GetMetaRequest{Path: "/grpc/a.txt"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &util.JwtClaims{
+		StandardClaims: &jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Hour).Unix()},
+		Permissions:    []util.Permission{{Path: "/api/meta/*", Methods: []string{"GET"}}},
+	}).SignedString([]byte(config.GofletCfg.JWTConfig.Security.SigningKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+
+	// The token can get the meta, but cannot delete the file
+	_, err = client.GetMeta(ctx, &pb.GetMetaRequest{Path: "/grpc/missing.txt"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.Delete(ctx, &pb.DeleteRequest{Path: "/grpc/missing.txt"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
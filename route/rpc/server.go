@@ -0,0 +1,66 @@
+// Package rpc provides the gRPC API, which mirrors the file and action endpoints of the HTTP API
+package rpc
+
+import (
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/route/rpc/pb"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// chunkSize is the size of the chunks of the download stream
+const chunkSize = 64 * 1024
+
+// server implements the file service
+type server struct {
+	pb.UnimplementedFileServiceServer
+}
+
+// Enabled returns whether the gRPC API is enabled
+func Enabled() bool {
+	return *config.GofletCfg.GRPCConfig.Enabled
+}
+
+// Start starts the listener of the gRPC API if it is enabled
+func Start() {
+	if !Enabled() {
+		return
+	}
+
+	var opts []grpc.ServerOption
+	httpsConfig := config.GofletCfg.HTTPConfig.HTTPSConfig
+	if *httpsConfig.Enabled {
+		creds, err := credentials.NewServerTLSFromFile(httpsConfig.Cert, httpsConfig.Key)
+		if err != nil {
+			log.Fatalf("Error loading the TLS certificate: %s", err.Error())
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	endpoint := config.GofletCfg.GetGRPCEndpoint()
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		log.Fatalf("Error listening on %s: %s", endpoint, err.Error())
+	}
+	s := NewServer(opts...)
+	go func() {
+		if err := s.Serve(listener); err != nil {
+			panic(err)
+		}
+	}()
+	log.Infof("gRPC API started on %s", endpoint)
+}
+
+// NewServer returns the gRPC server with the file service registered
+func NewServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unaryAuthenticator),
+		grpc.ChainStreamInterceptor(streamAuthenticator))
+	s := grpc.NewServer(opts...)
+	pb.RegisterFileServiceServer(s, &server{})
+	return s
+}
@@ -0,0 +1,260 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vvbbnn00/goflet/config"
+)
+
+const (
+	// signAlgorithm is the only supported signing algorithm
+	signAlgorithm = "AWS4-HMAC-SHA256"
+	// amzDateFormat is the format of the X-Amz-Date header
+	amzDateFormat = "20060102T150405Z"
+	// maxClockSkew is the maximum difference between the request time and the server time
+	maxClockSkew = 15 * time.Minute
+	// maxPresignExpires is the maximum validity of the presigned URLs, in seconds
+	maxPresignExpires = 7 * 24 * 3600
+
+	unsignedPayload          = "UNSIGNED-PAYLOAD"
+	streamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	emptySHA256              = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// signature is the parsed signature of a request
+type signature struct {
+	credential    *config.S3Credential
+	amzDate       string   // The time of the request in the X-Amz-Date format
+	scope         string   // The credential scope, date/region/s3/aws4_request
+	signedHeaders []string // The names of the signed headers
+	signature     string   // The signature provided by the client
+	payloadHash   string   // The hash of the payload, or one of the special values
+	signingKey    []byte   // The key derived from the secret access key
+}
+
+// findCredential returns the credential of the access key ID
+func findCredential(accessKeyID string) *config.S3Credential {
+	credentials := config.GofletCfg.S3Config.Credentials
+	for i := range credentials {
+		if credentials[i].AccessKeyID == accessKeyID {
+			return &credentials[i]
+		}
+	}
+	return nil
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// sha256Hex returns the hex encoded SHA256 of the data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// deriveSigningKey derives the signing key from the secret access key
+func deriveSigningKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+// uriEncode encodes the string as required by SigV4, every byte except the unreserved characters is encoded
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9') ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' || (ch == '/' && !encodeSlash) {
+			b.WriteByte(ch)
+			continue
+		}
+		b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{ch})))
+	}
+	return b.String()
+}
+
+// canonicalQuery returns the canonical query string, the signature itself is excluded
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for key, values := range query {
+		if key == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// canonicalHeaders returns the canonical headers of the signed headers
+func canonicalHeaders(r *http.Request, signedHeaders []string) string {
+	var b strings.Builder
+	for _, name := range signedHeaders {
+		var values []string
+		if name == "host" {
+			values = []string{r.Host}
+		} else {
+			values = r.Header.Values(name)
+		}
+		for i, value := range values {
+			values[i] = strings.Join(strings.Fields(value), " ")
+		}
+		b.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+	return b.String()
+}
+
+// stringToSign returns the string to sign of the request
+func (s *signature) stringToSign(r *http.Request) string {
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		uriEncode(r.URL.Path, false),
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders(r, s.signedHeaders),
+		strings.Join(s.signedHeaders, ";"),
+		s.payloadHash,
+	}, "\n")
+	return strings.Join([]string{signAlgorithm, s.amzDate, s.scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+}
+
+// parseCredential parses the credential of the access key ID and the scope, derives the signing key
+func (s *signature) parseCredential(value string) *apiError {
+	parts := strings.Split(value, "/")
+	if len(parts) != 5 || parts[3] != "s3" || parts[4] != "aws4_request" {
+		return &errMalformedAuth
+	}
+	if parts[2] != config.GofletCfg.S3Config.Region {
+		e := errMalformedAuth
+		e.Message = "The region '" + parts[2] + "' is wrong; expecting '" + config.GofletCfg.S3Config.Region + "'"
+		return &e
+	}
+
+	s.credential = findCredential(parts[0])
+	if s.credential == nil {
+		return &errInvalidAccessKeyID
+	}
+	s.scope = strings.Join(parts[1:], "/")
+	s.signingKey = deriveSigningKey(s.credential.SecretAccessKey, parts[1], parts[2], parts[3])
+	return nil
+}
+
+// parseAuthHeader parses the signature in the Authorization header
+func parseAuthHeader(r *http.Request, header string) (*signature, *apiError) {
+	fields, ok := strings.CutPrefix(header, signAlgorithm+" ")
+	if !ok {
+		return nil, &errNotImplemented // Only SigV4 is supported
+	}
+
+	s := &signature{
+		amzDate:     r.Header.Get("X-Amz-Date"),
+		payloadHash: r.Header.Get("X-Amz-Content-Sha256"),
+	}
+	var credential string
+	for _, field := range strings.Split(fields, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch key {
+		case "Credential":
+			credential = value
+		case "SignedHeaders":
+			s.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			s.signature = value
+		}
+	}
+	if credential == "" || s.signedHeaders == nil || s.signature == "" || s.payloadHash == "" {
+		return nil, &errMalformedAuth
+	}
+	if err := s.parseCredential(credential); err != nil {
+		return nil, err
+	}
+
+	date, err := time.Parse(amzDateFormat, s.amzDate)
+	if err != nil || !strings.HasPrefix(s.scope, s.amzDate[:8]+"/") {
+		return nil, &errMalformedAuth
+	}
+	if skew := time.Since(date); skew > maxClockSkew || skew < -maxClockSkew {
+		return nil, &errRequestTimeTooSkewed
+	}
+	return s, nil
+}
+
+// parsePresignedQuery parses the signature in the query of a presigned URL
+func parsePresignedQuery(query url.Values) (*signature, *apiError) {
+	if query.Get("X-Amz-Algorithm") != signAlgorithm {
+		return nil, &errNotImplemented
+	}
+
+	s := &signature{
+		amzDate:       query.Get("X-Amz-Date"),
+		signedHeaders: strings.Split(query.Get("X-Amz-SignedHeaders"), ";"),
+		signature:     query.Get("X-Amz-Signature"),
+		payloadHash:   unsignedPayload,
+	}
+	if s.signature == "" || query.Get("X-Amz-SignedHeaders") == "" {
+		return nil, &errMalformedAuth
+	}
+	if err := s.parseCredential(query.Get("X-Amz-Credential")); err != nil {
+		return nil, err
+	}
+
+	date, err := time.Parse(amzDateFormat, s.amzDate)
+	if err != nil || !strings.HasPrefix(s.scope, s.amzDate[:8]+"/") {
+		return nil, &errMalformedAuth
+	}
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || expires < 0 || expires > maxPresignExpires {
+		return nil, &errMalformedAuth
+	}
+	if time.Until(date) > maxClockSkew {
+		return nil, &errRequestTimeTooSkewed
+	}
+	if time.Since(date) > time.Duration(expires)*time.Second {
+		return nil, &errExpiredRequest
+	}
+	return s, nil
+}
+
+// verifyRequest verifies the signature of the request, returns the signature with the credential of the client
+func verifyRequest(r *http.Request) (*signature, *apiError) {
+	var s *signature
+	var err *apiError
+	if header := r.Header.Get("Authorization"); header != "" {
+		s, err = parseAuthHeader(r, header)
+	} else if r.URL.Query().Has("X-Amz-Signature") {
+		s, err = parsePresignedQuery(r.URL.Query())
+	} else {
+		return nil, &errMissingAuth
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The host should always be signed, so the request cannot be replayed to another server
+	if !slices.Contains(s.signedHeaders, "host") {
+		return nil, &errMalformedAuth
+	}
+
+	expected := hex.EncodeToString(hmacSHA256(s.signingKey, s.stringToSign(r)))
+	if !hmac.Equal([]byte(expected), []byte(s.signature)) {
+		return nil, &errSignatureMismatch
+	}
+	return s, nil
+}
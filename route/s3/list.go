@@ -0,0 +1,182 @@
+package s3
+
+import (
+	"encoding/base64"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/index"
+	"github.com/vvbbnn00/goflet/util"
+)
+
+// startTime is reported as the creation time of the buckets, which are configured when the server starts
+var startTime = time.Now()
+
+// routeListBuckets handler for GET /
+func routeListBuckets(c *gin.Context) {
+	actor := getActor(c)
+	resp := listBucketsResponse{
+		Xmlns: xmlNamespace,
+		Owner: owner{ID: actor.Subject, DisplayName: actor.Subject},
+	}
+
+	for name := range config.GofletCfg.S3Config.Buckets {
+		if canAccess(c, name, false) {
+			resp.Buckets = append(resp.Buckets, bucketInfo{Name: name, CreationDate: formatTime(startTime)})
+		}
+	}
+	sort.Slice(resp.Buckets, func(i, j int) bool {
+		return resp.Buckets[i].Name < resp.Buckets[j].Name
+	})
+	writeXML(c, resp)
+}
+
+// parseMaxKeys parses the max-keys query, at most 1000 keys are returned
+func parseMaxKeys(value string) (int, bool) {
+	if value == "" {
+		return 1000, true
+	}
+	maxKeys, err := strconv.Atoi(value)
+	if err != nil || maxKeys < 0 {
+		return 0, false
+	}
+	return min(maxKeys, 1000), true
+}
+
+// routeListObjects handler for GET /{bucket}, both ListObjects and ListObjectsV2 are supported,
+// the keys are listed from the path index
+func routeListObjects(c *gin.Context, bucket string) {
+	query := c.Request.URL.Query()
+	v2 := query.Get("list-type") == "2"
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	encodeURL := query.Get("encoding-type") == "url"
+
+	maxKeys, ok := parseMaxKeys(query.Get("max-keys"))
+	if !ok {
+		writeError(c, errInvalidArgument)
+		return
+	}
+
+	// The keys after the marker are listed
+	marker := query.Get("marker")
+	if v2 {
+		marker = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.URLEncoding.DecodeString(token)
+			if err != nil {
+				writeError(c, errInvalidArgument)
+				return
+			}
+			marker = string(decoded)
+		}
+	}
+
+	encode := func(key string) string {
+		if encodeURL {
+			return url.QueryEscape(key)
+		}
+		return key
+	}
+
+	resp := listObjectsResponse{
+		Xmlns:     xmlNamespace,
+		Name:      bucket,
+		Prefix:    encode(prefix),
+		MaxKeys:   maxKeys,
+		Delimiter: encode(delimiter),
+	}
+	if encodeURL {
+		resp.EncodingType = "url"
+	}
+
+	// Only the folder of the prefix needs to be walked
+	root, _ := bucketRoot(bucket)
+	folder := root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		folder = path.Join(root, prefix[:i])
+	}
+
+	count := 0
+	last := ""
+	lastPrefix := ""
+	withOwner := !v2 || query.Get("fetch-owner") == "true"
+	for _, relativePath := range index.Files(folder) {
+		key := relativePath
+		if root != "" {
+			key = strings.TrimPrefix(relativePath, root+"/")
+		}
+		if !strings.HasPrefix(key, prefix) || key <= marker {
+			continue
+		}
+
+		// The keys containing the delimiter after the prefix are grouped
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				common := key[:len(prefix)+i+len(delimiter)]
+				if common == lastPrefix || common <= marker {
+					continue
+				}
+				if count == maxKeys {
+					resp.IsTruncated = true
+					break
+				}
+				resp.CommonPrefixes = append(resp.CommonPrefixes, commonPrefix{Prefix: encode(common)})
+				lastPrefix, last = common, common
+				count++
+				continue
+			}
+		}
+
+		fsPath, err := util.RelativeToFsPath(relativePath)
+		if err != nil {
+			continue
+		}
+		info, err := storage.GetFileInfo(fsPath)
+		if err != nil {
+			continue // The file is deleted after the listing
+		}
+		if count == maxKeys {
+			resp.IsTruncated = true
+			break
+		}
+
+		o := object{
+			Key:          encode(key),
+			LastModified: formatTime(time.Unix(info.LastModified, 0)),
+			ETag:         objectETag(info),
+			Size:         info.FileSize,
+			StorageClass: "STANDARD",
+		}
+		if withOwner {
+			o.Owner = &owner{ID: info.FileMeta.Owner, DisplayName: info.FileMeta.Owner}
+		}
+		resp.Contents = append(resp.Contents, o)
+		last = key
+		count++
+	}
+
+	if v2 {
+		resp.KeyCount = &count
+		resp.ContinuationToken = query.Get("continuation-token")
+		resp.StartAfter = encode(query.Get("start-after"))
+		if resp.IsTruncated {
+			resp.NextContinuationToken = base64.URLEncoding.EncodeToString([]byte(last))
+		}
+	} else {
+		encodedMarker := encode(query.Get("marker"))
+		resp.Marker = &encodedMarker
+		if resp.IsTruncated {
+			resp.NextMarker = encode(last)
+		}
+	}
+	writeXML(c, resp)
+}
@@ -0,0 +1,330 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/storage/upload"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+const (
+	// uploadIDLength is the length of the IDs of the multipart uploads
+	uploadIDLength = 32
+	// maxPartNumber is the maximum number of the parts of a multipart upload
+	maxPartNumber = 10000
+	// partAppend is the append of the files of the parts
+	partAppend = ".part"
+	// etagAppend is the append of the files storing the MD5 of the parts
+	etagAppend = ".etag"
+)
+
+// multipartUpload is the manifest of a multipart upload, the parts are stored beside it in the upload path,
+// the upload is cleaned as a whole once the manifest is outdated
+type multipartUpload struct {
+	Bucket    string `json:"bucket"`
+	Key       string `json:"key"`
+	Initiated int64  `json:"initiated"`
+}
+
+// uploadDir returns the folder of the multipart upload, fails if the ID is malformed
+func uploadDir(uploadID string) (string, bool) {
+	if len(uploadID) != uploadIDLength {
+		return "", false
+	}
+	for _, ch := range uploadID {
+		if !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", ch) {
+			return "", false
+		}
+	}
+	return filepath.Join(upload.GetMultipartPath(), uploadID), true
+}
+
+// loadUpload loads the multipart upload of the request, the bucket and the key should match the ones initiated
+func loadUpload(c *gin.Context, bucket, key string) (string, *apiError) {
+	dir, ok := uploadDir(c.Query("uploadId"))
+	if !ok {
+		return "", &errNoSuchUpload
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, upload.MultipartManifest))
+	if err != nil {
+		return "", &errNoSuchUpload
+	}
+	var manifest multipartUpload
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Bucket != bucket || manifest.Key != key {
+		return "", &errNoSuchUpload
+	}
+	return dir, nil
+}
+
+// touchUpload updates the modification time of the manifest, so the upload does not expire while it goes on
+func touchUpload(dir string) {
+	now := time.Now()
+	_ = os.Chtimes(filepath.Join(dir, upload.MultipartManifest), now, now)
+}
+
+// partPath returns the path of the file of the part
+func partPath(dir string, partNumber int) string {
+	return filepath.Join(dir, strconv.Itoa(partNumber)+partAppend)
+}
+
+// readPartETag returns the MD5 of the part, false if the part is not uploaded
+func readPartETag(dir string, partNumber int) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, strconv.Itoa(partNumber)+etagAppend))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// routeCreateMultipartUpload handler for POST /{bucket}/{key}?uploads
+func routeCreateMultipartUpload(c *gin.Context, bucket, key string) {
+	if _, apiErr := objectPath(bucket, key); apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+
+	uploadID := util.RandomString(uploadIDLength)
+	dir, _ := uploadDir(uploadID)
+	data, _ := json.Marshal(multipartUpload{Bucket: bucket, Key: key, Initiated: time.Now().Unix()})
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		log.Warnf("Error creating multipart upload: %s", err.Error())
+		writeError(c, errInternalError)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, upload.MultipartManifest), data, model.FilePerm); err != nil {
+		log.Warnf("Error creating multipart upload: %s", err.Error())
+		writeError(c, errInternalError)
+		return
+	}
+
+	writeXML(c, initiateMultipartUploadResponse{Xmlns: xmlNamespace, Bucket: bucket, Key: key, UploadID: uploadID})
+}
+
+// routeUploadPart handler for PUT /{bucket}/{key}?partNumber&uploadId
+func routeUploadPart(c *gin.Context, bucket, key string) {
+	if c.GetHeader("X-Amz-Copy-Source") != "" {
+		writeError(c, errNotImplemented) // UploadPartCopy is not supported
+		return
+	}
+
+	dir, apiErr := loadUpload(c, bucket, key)
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+	partNumber, err := strconv.Atoi(c.Query("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+		writeError(c, errInvalidArgument)
+		return
+	}
+	pathData, apiErr := objectPath(bucket, key)
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+
+	// Keep the upload from expiring while the part is received
+	touchUpload(dir)
+
+	// The part is written to a temporary file first, so a failed upload does not replace the uploaded part
+	file, err := os.CreateTemp(dir, "*.tmp")
+	if err != nil {
+		log.Warnf("Error creating part: %s", err.Error())
+		writeError(c, errInternalError)
+		return
+	}
+	length := decodedLength(c.Request, getSignature(c))
+	md5Hex, apiErr := receivePayload(c, file, length, util.GetUploadLimit(pathData.RelativePath))
+	_ = file.Close()
+	if apiErr != nil {
+		_ = os.Remove(file.Name())
+		writeError(c, *apiErr)
+		return
+	}
+
+	err = os.Rename(file.Name(), partPath(dir, partNumber))
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, strconv.Itoa(partNumber)+etagAppend), []byte(md5Hex), model.FilePerm)
+	}
+	if err != nil {
+		log.Warnf("Error saving part: %s", err.Error())
+		writeError(c, errInternalError)
+		return
+	}
+
+	touchUpload(dir)
+
+	c.Header("ETag", `"`+md5Hex+`"`)
+	c.Status(http.StatusOK)
+}
+
+// routeListParts handler for GET /{bucket}/{key}?uploadId
+func routeListParts(c *gin.Context, bucket, key string) {
+	dir, apiErr := loadUpload(c, bucket, key)
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+
+	resp := listPartsResponse{Xmlns: xmlNamespace, Bucket: bucket, Key: key, UploadID: c.Query("uploadId")}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		partNumber, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), partAppend))
+		if err != nil || !strings.HasSuffix(entry.Name(), partAppend) {
+			continue
+		}
+		info, err := entry.Info()
+		md5Hex, ok := readPartETag(dir, partNumber)
+		if err != nil || !ok {
+			continue
+		}
+		resp.Parts = append(resp.Parts, part{
+			PartNumber:   partNumber,
+			LastModified: formatTime(info.ModTime()),
+			ETag:         `"` + md5Hex + `"`,
+			Size:         info.Size(),
+		})
+	}
+	sort.Slice(resp.Parts, func(i, j int) bool {
+		return resp.Parts[i].PartNumber < resp.Parts[j].PartNumber
+	})
+	writeXML(c, resp)
+}
+
+// routeCompleteMultipartUpload handler for POST /{bucket}/{key}?uploadId, the parts are joined into the temporary
+// file of the upload, which is completed like the other uploads
+func routeCompleteMultipartUpload(c *gin.Context, bucket, key string) {
+	dir, apiErr := loadUpload(c, bucket, key)
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+	touchUpload(dir)
+	pathData, apiErr := objectPath(bucket, key)
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+
+	body, apiErr := readXMLBody(c.Request, getSignature(c))
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+	var req completeMultipartUploadRequest
+	if err := xml.Unmarshal(body, &req); err != nil || len(req.Parts) == 0 {
+		writeError(c, errMalformedXML)
+		return
+	}
+
+	// Check the parts, the ETag of the object is the MD5 of the MD5s of the parts
+	var size int64
+	etagHash := md5.New()
+	for i, p := range req.Parts {
+		if i > 0 && p.PartNumber <= req.Parts[i-1].PartNumber {
+			writeError(c, errInvalidPartOrder)
+			return
+		}
+		md5Hex, ok := readPartETag(dir, p.PartNumber)
+		info, err := os.Stat(partPath(dir, p.PartNumber))
+		if !ok || err != nil || strings.Trim(p.ETag, `"`) != md5Hex {
+			writeError(c, errInvalidPart)
+			return
+		}
+		sum, _ := hex.DecodeString(md5Hex)
+		etagHash.Write(sum)
+		size += info.Size()
+	}
+
+	if limit := util.GetUploadLimit(pathData.RelativePath); limit > 0 && size > limit {
+		writeError(c, errEntityTooLarge)
+		return
+	}
+	if err := upload.CheckQuota(pathData.RelativePath, getActor(c).Subject, size); err != nil {
+		writeError(c, errQuotaExceeded)
+		return
+	}
+
+	tmpPath, apiErr := joinParts(dir, pathData.RelativePath, req.Parts)
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+	if apiErr := completeUpload(c, pathData.RelativePath, tmpPath); apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+	_ = os.RemoveAll(dir)
+
+	writeXML(c, completeMultipartUploadResponse{
+		Xmlns:    xmlNamespace,
+		Location: c.Request.URL.Path,
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     `"` + hex.EncodeToString(etagHash.Sum(nil)) + "-" + strconv.Itoa(len(req.Parts)) + `"`,
+	})
+}
+
+// joinParts joins the parts into a private temporary file of the upload, returns the path of the file
+func joinParts(dir string, relativePath string, parts []completePart) (string, *apiError) {
+	file, apiErr := createObjectFile(relativePath)
+	if apiErr != nil {
+		return "", apiErr
+	}
+
+	var err error
+	for _, p := range parts {
+		if err = appendFile(file, partPath(dir, p.PartNumber)); err != nil {
+			break
+		}
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Warnf("Error joining parts: %s", err.Error())
+		_ = os.Remove(file.Name())
+		return "", &errInternalError
+	}
+	return file.Name(), nil
+}
+
+// appendFile appends the content of the file at the path to the writer
+func appendFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// routeAbortMultipartUpload handler for DELETE /{bucket}/{key}?uploadId
+func routeAbortMultipartUpload(c *gin.Context, bucket, key string) {
+	dir, apiErr := loadUpload(c, bucket, key)
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+	_ = os.RemoveAll(dir)
+	c.Status(http.StatusNoContent)
+}
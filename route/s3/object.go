@@ -0,0 +1,357 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event"
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/fileop"
+	"github.com/vvbbnn00/goflet/storage/index"
+	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/storage/upload"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/hash"
+	"github.com/vvbbnn00/goflet/util/log"
+	"github.com/vvbbnn00/goflet/util/throttle"
+)
+
+// emptyMD5 is the MD5 of the empty content
+const emptyMD5 = "d41d8cd98f00b204e9800998ecf8427e"
+
+// objectETag returns the ETag of the stored file, the MD5 is computed if the hasher has not done it yet
+func objectETag(info model.FileInfo) string {
+	md5Hex := info.FileMeta.Hash.HashMd5
+	if md5Hex == "" {
+		md5Hex, _ = hash.FileMd5(info.FilePath)
+	}
+	return `"` + md5Hex + `"`
+}
+
+// throttledWriter limits the bandwidth of the response body
+type throttledWriter struct {
+	http.ResponseWriter
+	writer io.Writer
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	return w.writer.Write(p)
+}
+
+// routeGetObject handler for GET and HEAD /{bucket}/{key}, the ranges and the conditions are supported
+func routeGetObject(c *gin.Context, bucket, key string) {
+	pathData, apiErr := objectPath(bucket, key)
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+
+	info, err := storage.GetFileInfo(pathData.FsPath)
+	if err != nil {
+		writeError(c, errNoSuchKey)
+		return
+	}
+	file, err := storage.GetFileReader(pathData.FsPath)
+	if err != nil {
+		writeError(c, errNoSuchKey)
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	c.Header("ETag", objectETag(info))
+	c.Header("Content-Type", info.FileMeta.MimeType)
+	c.Header("Last-Modified", time.Unix(info.LastModified, 0).UTC().Format(http.TimeFormat))
+
+	writer := &throttledWriter{ResponseWriter: c.Writer}
+	writer.writer = throttle.NewWriter(c.Writer, throttle.GlobalDownload,
+		throttle.NewLimiter(config.GofletCfg.BandwidthConfig.DownloadPerConnBps))
+	http.ServeContent(writer, c.Request, path.Base(key), time.Unix(info.LastModified, 0), file)
+}
+
+// receivePayload writes the payload of the length to the writer, returns the hex encoded MD5
+func receivePayload(c *gin.Context, w io.Writer, length int64, limit int64) (string, *apiError) {
+	sig := getSignature(c)
+	if length < 0 {
+		return "", &errMissingContentLength
+	}
+	if limit > 0 && length > limit {
+		return "", &errEntityTooLarge
+	}
+
+	reader, apiErr := newPayloadReader(c.Request, sig)
+	if apiErr != nil {
+		return "", apiErr
+	}
+	reader = throttle.NewReader(reader, throttle.GlobalUpload,
+		throttle.NewLimiter(config.GofletCfg.BandwidthConfig.UploadPerConnBps))
+
+	md5Hash := md5.New()
+	size, err := io.Copy(io.MultiWriter(w, md5Hash), io.LimitReader(reader, length+1))
+	if err != nil {
+		return "", payloadError(sig, err)
+	}
+	if size != length {
+		return "", &errIncompleteBody
+	}
+
+	md5Sum := md5Hash.Sum(nil)
+	if contentMD5 := c.GetHeader("Content-MD5"); contentMD5 != "" && contentMD5 != base64.StdEncoding.EncodeToString(md5Sum) {
+		return "", &errBadDigest
+	}
+	return hex.EncodeToString(md5Sum), nil
+}
+
+// createObjectFile creates the private temporary file of the upload, it is not shared with the chunked uploads
+func createObjectFile(relativePath string) (*os.File, *apiError) {
+	file, err := upload.CreateObjectFile(relativePath)
+	if err != nil {
+		if err.Error() == "directory_creation" {
+			return nil, &errAccessDenied
+		}
+		log.Warnf("Error creating temporary file: %s", err.Error())
+		return nil, &errInternalError
+	}
+	return file, nil
+}
+
+// writeTempFile writes the payload to a private temporary file of the upload, returns the path of the file and
+// the hex encoded MD5
+func writeTempFile(c *gin.Context, relativePath string, length int64) (string, string, *apiError) {
+	file, apiErr := createObjectFile(relativePath)
+	if apiErr != nil {
+		return "", "", apiErr
+	}
+
+	md5Hex, apiErr := receivePayload(c, file, length, util.GetUploadLimit(relativePath))
+	_ = file.Close()
+	if apiErr != nil {
+		_ = os.Remove(file.Name())
+		return "", "", apiErr
+	}
+	return file.Name(), md5Hex, nil
+}
+
+// completeUpload completes the upload of the temporary file, the file is removed if it fails
+func completeUpload(c *gin.Context, relativePath string, tmpPath string) *apiError {
+	err := upload.CompleteObjectUpload(relativePath, tmpPath, getActor(c), event.TypeFileUploaded)
+	if err == nil {
+		return nil
+	}
+
+	switch err.Error() {
+	case "quota_exceeded":
+		return &errQuotaExceeded
+	case "file_uploading":
+		return &errOperationAborted
+	}
+	log.Warnf("Error completing upload: %s", err.Error())
+	return &errInternalError
+}
+
+// routePutObject handler for PUT /{bucket}/{key}, the keys ending with a slash create the folders
+func routePutObject(c *gin.Context, bucket, key string) {
+	if folder, ok := strings.CutSuffix(key, "/"); ok {
+		pathData, apiErr := objectPath(bucket, folder)
+		if apiErr != nil {
+			writeError(c, *apiErr)
+			return
+		}
+		if !*config.GofletCfg.FileConfig.AllowFolderCreation {
+			writeError(c, errAccessDenied)
+			return
+		}
+		if err := index.AddFolder(pathData.RelativePath); err != nil {
+			log.Warnf("Error creating folder %s: %s", pathData.RelativePath, err.Error())
+			writeError(c, errInternalError)
+			return
+		}
+		c.Header("ETag", `"`+emptyMD5+`"`)
+		c.Status(http.StatusOK)
+		return
+	}
+
+	pathData, apiErr := objectPath(bucket, key)
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+
+	// Check the quota before receiving the payload
+	length := decodedLength(c.Request, getSignature(c))
+	if length < 0 {
+		writeError(c, errMissingContentLength)
+		return
+	}
+	if err := upload.CheckQuota(pathData.RelativePath, getActor(c).Subject, length); err != nil {
+		writeError(c, errQuotaExceeded)
+		return
+	}
+
+	tmpPath, md5Hex, apiErr := writeTempFile(c, pathData.RelativePath, length)
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+	if apiErr := completeUpload(c, pathData.RelativePath, tmpPath); apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+
+	c.Header("ETag", `"`+md5Hex+`"`)
+	c.Status(http.StatusOK)
+}
+
+// parseCopySource parses the X-Amz-Copy-Source header, like /bucket/key or bucket/key
+func parseCopySource(c *gin.Context) (string, string, *apiError) {
+	source, err := url.PathUnescape(c.GetHeader("X-Amz-Copy-Source"))
+	if err != nil {
+		return "", "", &errInvalidArgument
+	}
+	source, _, _ = strings.Cut(source, "?") // The versions are not supported
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if !ok || key == "" {
+		return "", "", &errInvalidArgument
+	}
+	return bucket, key, nil
+}
+
+// routeCopyObject handler for PUT /{bucket}/{key} with X-Amz-Copy-Source
+func routeCopyObject(c *gin.Context, bucket, key string) {
+	sourceBucket, sourceKey, apiErr := parseCopySource(c)
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+	if !canAccess(c, sourceBucket, false) {
+		writeError(c, errAccessDenied)
+		return
+	}
+
+	sourcePath, apiErr := objectPath(sourceBucket, sourceKey)
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+	targetPath, apiErr := objectPath(bucket, key)
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+
+	err := fileop.Copy(getActor(c), sourcePath, targetPath, true)
+	if err != nil {
+		switch err.Error() {
+		case "same_path":
+			// Copying the object to itself replaces the metadata, which is not supported, the object is kept
+		case "source_file_not_found":
+			writeError(c, errNoSuchKey)
+			return
+		case "quota_exceeded":
+			writeError(c, errQuotaExceeded)
+			return
+		case "different_buckets":
+			writeError(c, errInvalidRequest)
+			return
+		default:
+			log.Warnf("Error copying object: %s", err.Error())
+			writeError(c, errInternalError)
+			return
+		}
+	}
+
+	info, err := storage.GetFileInfo(targetPath.FsPath)
+	if err != nil {
+		writeError(c, errInternalError)
+		return
+	}
+	writeXML(c, copyObjectResponse{
+		Xmlns:        xmlNamespace,
+		LastModified: formatTime(time.Unix(info.LastModified, 0)),
+		ETag:         objectETag(info),
+	})
+}
+
+// deleteObject deletes the object, the keys ending with a slash remove the empty folders
+func deleteObject(c *gin.Context, bucket, key string) *apiError {
+	if folder, ok := strings.CutSuffix(key, "/"); ok {
+		pathData, apiErr := objectPath(bucket, folder)
+		if apiErr != nil {
+			return apiErr
+		}
+		index.RemoveFolder(pathData.RelativePath)
+		return nil
+	}
+
+	pathData, apiErr := objectPath(bucket, key)
+	if apiErr != nil {
+		return apiErr
+	}
+	err := fileop.Delete(getActor(c), pathData)
+	if err != nil && err.Error() != "file_not_found" && !os.IsNotExist(err) {
+		log.Warnf("Error deleting object: %s", err.Error())
+		return &errInternalError
+	}
+	return nil // Deleting a missing object succeeds
+}
+
+// routeDeleteObject handler for DELETE /{bucket}/{key}
+func routeDeleteObject(c *gin.Context, bucket, key string) {
+	if apiErr := deleteObject(c, bucket, key); apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// routeDeleteObjects handler for POST /{bucket}?delete
+func routeDeleteObjects(c *gin.Context, bucket string) {
+	body, apiErr := readXMLBody(c.Request, getSignature(c))
+	if apiErr != nil {
+		writeError(c, *apiErr)
+		return
+	}
+
+	// The Content-MD5 is required by the multi-object delete
+	contentMD5 := c.GetHeader("Content-MD5")
+	if contentMD5 == "" {
+		writeError(c, errMissingContentMD5)
+		return
+	}
+	md5Sum := md5.Sum(body)
+	if contentMD5 != base64.StdEncoding.EncodeToString(md5Sum[:]) {
+		writeError(c, errBadDigest)
+		return
+	}
+
+	var req deleteRequest
+	if err := xml.Unmarshal(body, &req); err != nil || len(req.Objects) > 1000 {
+		writeError(c, errMalformedXML)
+		return
+	}
+
+	resp := deleteResponse{Xmlns: xmlNamespace}
+	for _, o := range req.Objects {
+		if apiErr := deleteObject(c, bucket, o.Key); apiErr != nil {
+			resp.Errors = append(resp.Errors, deleteError{Key: o.Key, Code: apiErr.Code, Message: apiErr.Message})
+			continue
+		}
+		if !req.Quiet {
+			resp.Deleted = append(resp.Deleted, deletedObject{Key: o.Key})
+		}
+	}
+	writeXML(c, resp)
+}
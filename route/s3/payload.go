@@ -0,0 +1,191 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// maxChunkSize is the maximum size of a chunk of the aws-chunked payload
+const maxChunkSize = 16 << 20
+
+var (
+	errPayloadMismatch = errors.New("payload_mismatch")
+	errChunkMalformed  = errors.New("chunk_malformed")
+)
+
+// newPayloadReader returns the reader of the request payload, the payload is verified against the signature,
+// the reader fails with errPayloadMismatch at the end if it does not match
+func newPayloadReader(r *http.Request, s *signature) (io.Reader, *apiError) {
+	switch s.payloadHash {
+	case unsignedPayload:
+		return r.Body, nil
+	case streamingPayload:
+		return &chunkedReader{reader: bufio.NewReader(r.Body), sig: s, prevSignature: s.signature}, nil
+	case streamingUnsignedTrailer:
+		return &chunkedReader{reader: bufio.NewReader(r.Body), trailer: true}, nil
+	}
+
+	expected, err := hex.DecodeString(s.payloadHash)
+	if err != nil || len(expected) != sha256.Size {
+		return nil, &errNotImplemented // Like the signed trailers
+	}
+	return &hashReader{reader: r.Body, hash: sha256.New(), expected: expected}, nil
+}
+
+// payloadError returns the error of reading the payload
+func payloadError(s *signature, err error) *apiError {
+	switch {
+	case errors.Is(err, errPayloadMismatch) && s.payloadHash == streamingPayload:
+		return &errSignatureMismatch
+	case errors.Is(err, errPayloadMismatch):
+		return &errContentSHA256Mismatch
+	}
+	log.Debugf("Error reading payload: %s", err.Error())
+	return &errIncompleteBody
+}
+
+// readXMLBody reads the whole XML request body, the body is returned only after the payload is verified
+func readXMLBody(r *http.Request, s *signature) ([]byte, *apiError) {
+	reader, apiErr := newPayloadReader(r, s)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	body, err := io.ReadAll(io.LimitReader(reader, maxXMLSize+1))
+	if err != nil {
+		return nil, payloadError(s, err)
+	}
+	if len(body) > maxXMLSize {
+		return nil, &errMessageTooLarge
+	}
+	return body, nil
+}
+
+// decodedLength returns the length of the decoded payload, -1 if unknown
+func decodedLength(r *http.Request, s *signature) int64 {
+	if s.payloadHash == streamingPayload || s.payloadHash == streamingUnsignedTrailer {
+		length, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil {
+			return -1
+		}
+		return length
+	}
+	return r.ContentLength
+}
+
+// hashReader verifies the SHA256 of the payload when the payload is read to the end
+type hashReader struct {
+	reader   io.Reader
+	hash     hash.Hash
+	expected []byte
+}
+
+func (h *hashReader) Read(p []byte) (int, error) {
+	n, err := h.reader.Read(p)
+	h.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(h.hash.Sum(nil), h.expected) {
+		return n, errPayloadMismatch
+	}
+	return n, err
+}
+
+// chunkedReader decodes the aws-chunked payload, the signature of each chunk is verified if the payload is signed
+type chunkedReader struct {
+	reader        *bufio.Reader
+	sig           *signature // The signature of the request, nil if the chunks are not signed
+	prevSignature string
+	trailer       bool // Whether the trailers follow the last chunk
+	chunk         []byte
+	done          bool
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for len(c.chunk) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.chunk)
+	c.chunk = c.chunk[n:]
+	return n, nil
+}
+
+// readLine reads a line ending with CRLF
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		if err == io.EOF {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// nextChunk reads and verifies the next chunk
+func (c *chunkedReader) nextChunk() error {
+	header, err := c.readLine()
+	if err != nil {
+		return err
+	}
+
+	sizeHex, extension, _ := strings.Cut(header, ";")
+	size, err := strconv.ParseInt(sizeHex, 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return errChunkMalformed
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		return err
+	}
+
+	if c.sig != nil {
+		chunkSignature, ok := strings.CutPrefix(extension, "chunk-signature=")
+		if !ok {
+			return errChunkMalformed
+		}
+		stringToSign := strings.Join([]string{
+			signAlgorithm + "-PAYLOAD", c.sig.amzDate, c.sig.scope, c.prevSignature, emptySHA256, sha256Hex(data),
+		}, "\n")
+		expected := hex.EncodeToString(hmacSHA256(c.sig.signingKey, stringToSign))
+		if !hmac.Equal([]byte(expected), []byte(chunkSignature)) {
+			return errPayloadMismatch
+		}
+		c.prevSignature = chunkSignature
+	}
+
+	if size == 0 {
+		c.done = true
+		if c.trailer {
+			// The trailers like the checksums end with an empty line, they are not verified
+			for {
+				line, err := c.readLine()
+				if err != nil || line == "" {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	// The data of the chunk ends with CRLF
+	if line, err := c.readLine(); err != nil || line != "" {
+		return errChunkMalformed
+	}
+	c.chunk = data
+	return nil
+}
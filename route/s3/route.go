@@ -0,0 +1,207 @@
+// Package s3 provides the S3-compatible API, the S3 buckets are mapped to the path prefixes of the storage
+package s3
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event"
+	"github.com/vvbbnn00/goflet/middleware"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+const (
+	// requestIDKey is the context key of the ID of the request
+	requestIDKey = "s3RequestID"
+	// signatureKey is the context key of the verified signature
+	signatureKey = "s3Signature"
+)
+
+// Enabled returns whether the S3 API is enabled
+func Enabled() bool {
+	return *config.GofletCfg.S3Config.Enabled
+}
+
+// Start starts the listener of the S3 API if it is enabled
+func Start() {
+	if !Enabled() {
+		return
+	}
+
+	router := NewRouter()
+	endpoint := config.GofletCfg.GetS3Endpoint()
+	httpsConfig := config.GofletCfg.HTTPConfig.HTTPSConfig
+	go func() {
+		var err error
+		if *httpsConfig.Enabled {
+			err = router.RunTLS(endpoint, httpsConfig.Cert, httpsConfig.Key)
+		} else {
+			err = router.Run(endpoint)
+		}
+		if err != nil {
+			panic(err)
+		}
+	}()
+	log.Infof("S3 API started on %s", endpoint)
+}
+
+// NewRouter returns the router of the S3 API
+func NewRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery(), middleware.SafeLogger(), authenticate)
+	router.Any("/*path", routeS3)
+	return router
+}
+
+// authenticate verifies the signature of the request
+func authenticate(c *gin.Context) {
+	requestID := newRequestID()
+	c.Set(requestIDKey, requestID)
+	c.Header("X-Amz-Request-Id", requestID)
+
+	sig, err := verifyRequest(c.Request)
+	if err != nil {
+		log.Debugf("S3 authentication failed: %s", err.Message)
+		writeError(c, *err)
+		return
+	}
+	c.Set(signatureKey, sig)
+	c.Next()
+}
+
+// getSignature returns the verified signature of the request
+func getSignature(c *gin.Context) *signature {
+	value, _ := c.Get(signatureKey)
+	sig, _ := value.(*signature)
+	return sig
+}
+
+// getActor returns the client of the request for the events, the subject is the one of the access key
+func getActor(c *gin.Context) event.Actor {
+	credential := getSignature(c).credential
+	subject := credential.Subject
+	if subject == "" {
+		subject = credential.AccessKeyID
+	}
+	return event.Actor{Subject: subject, IP: c.ClientIP()}
+}
+
+// canAccess checks whether the access key can access the bucket, the write operations need a writable key
+func canAccess(c *gin.Context, bucket string, write bool) bool {
+	credential := getSignature(c).credential
+	if write && credential.ReadOnly {
+		return false
+	}
+	if len(credential.Buckets) == 0 {
+		return true
+	}
+	for _, b := range credential.Buckets {
+		if b == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// bucketRoot returns the relative path of the folder the bucket is mapped to
+func bucketRoot(bucket string) (string, bool) {
+	prefix, ok := config.GofletCfg.S3Config.Buckets[bucket]
+	if !ok {
+		return "", false
+	}
+	return strings.Trim(path.Clean("/"+prefix), "/"), true
+}
+
+// objectPath returns the path of the object in the bucket, fails if the key escapes the bucket
+func objectPath(bucket, key string) (*util.Path, *apiError) {
+	root, ok := bucketRoot(bucket)
+	if !ok {
+		return nil, &errNoSuchBucket
+	}
+
+	// The keys are kept as they are, the ones like a/../b or a//b cannot be stored
+	objectPath := strings.TrimSuffix("/"+root, "/") + "/" + key
+	if key == "" || path.Clean(objectPath) != objectPath {
+		return nil, &errInvalidArgument
+	}
+
+	pathData, err := util.ParsePath(objectPath)
+	if err != nil {
+		return nil, &errInvalidArgument
+	}
+	return pathData, nil
+}
+
+// routeS3 dispatches the request by the method, the bucket, the key and the query
+func routeS3(c *gin.Context) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(c.Param("path"), "/"), "/")
+	query := c.Request.URL.Query()
+	method := c.Request.Method
+
+	if bucket == "" {
+		if method != http.MethodGet {
+			writeError(c, errNotImplemented)
+			return
+		}
+		routeListBuckets(c)
+		return
+	}
+
+	if _, ok := bucketRoot(bucket); !ok {
+		writeError(c, errNoSuchBucket)
+		return
+	}
+	write := method != http.MethodGet && method != http.MethodHead
+	if !canAccess(c, bucket, write) {
+		writeError(c, errAccessDenied)
+		return
+	}
+
+	if key == "" {
+		switch {
+		case method == http.MethodHead:
+			c.Status(http.StatusOK)
+		case method == http.MethodPut:
+			c.Status(http.StatusOK) // The buckets are configured, creating an existing bucket succeeds
+		case method == http.MethodGet && query.Has("location"):
+			writeXML(c, locationResponse{Xmlns: xmlNamespace, Location: config.GofletCfg.S3Config.Region})
+		case method == http.MethodGet && query.Has("uploads"):
+			writeError(c, errNotImplemented)
+		case method == http.MethodGet:
+			routeListObjects(c, bucket)
+		case method == http.MethodPost && query.Has("delete"):
+			routeDeleteObjects(c, bucket)
+		default:
+			writeError(c, errNotImplemented)
+		}
+		return
+	}
+
+	switch {
+	case method == http.MethodGet && query.Has("uploadId"):
+		routeListParts(c, bucket, key)
+	case method == http.MethodGet, method == http.MethodHead:
+		routeGetObject(c, bucket, key)
+	case method == http.MethodPut && query.Has("uploadId"):
+		routeUploadPart(c, bucket, key)
+	case method == http.MethodPut && c.GetHeader("X-Amz-Copy-Source") != "":
+		routeCopyObject(c, bucket, key)
+	case method == http.MethodPut:
+		routePutObject(c, bucket, key)
+	case method == http.MethodPost && query.Has("uploads"):
+		routeCreateMultipartUpload(c, bucket, key)
+	case method == http.MethodPost && query.Has("uploadId"):
+		routeCompleteMultipartUpload(c, bucket, key)
+	case method == http.MethodDelete && query.Has("uploadId"):
+		routeAbortMultipartUpload(c, bucket, key)
+	case method == http.MethodDelete:
+		routeDeleteObject(c, bucket, key)
+	default:
+		writeError(c, errNotImplemented)
+	}
+}
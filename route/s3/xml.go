@@ -0,0 +1,247 @@
+package s3
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/vvbbnn00/goflet/util"
+)
+
+// xmlNamespace is the namespace of the S3 responses
+const xmlNamespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// maxXMLSize is the maximum size of the XML request bodies
+const maxXMLSize = 1 << 20
+
+// timeFormat is the format of the times in the S3 responses
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// apiError is an error of the S3 API
+type apiError struct {
+	Code       string
+	Message    string
+	StatusCode int
+}
+
+var (
+	errAccessDenied          = apiError{"AccessDenied", "Access Denied.", http.StatusForbidden}
+	errBadDigest             = apiError{"BadDigest", "The Content-MD5 you specified did not match what was received.", http.StatusBadRequest}
+	errContentSHA256Mismatch = apiError{"XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.", http.StatusBadRequest}
+	errEntityTooLarge        = apiError{"EntityTooLarge", "Your proposed upload exceeds the maximum allowed object size.", http.StatusBadRequest}
+	errExpiredRequest        = apiError{"AccessDenied", "Request has expired.", http.StatusForbidden}
+	errIncompleteBody        = apiError{"IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.", http.StatusBadRequest}
+	errInternalError         = apiError{"InternalError", "We encountered an internal error. Please try again.", http.StatusInternalServerError}
+	errInvalidAccessKeyID    = apiError{"InvalidAccessKeyId", "The AWS access key ID you provided does not exist in our records.", http.StatusForbidden}
+	errInvalidArgument       = apiError{"InvalidArgument", "Invalid argument.", http.StatusBadRequest}
+	errInvalidPart           = apiError{"InvalidPart", "One or more of the specified parts could not be found.", http.StatusBadRequest}
+	errInvalidPartOrder      = apiError{"InvalidPartOrder", "The list of parts was not in ascending order.", http.StatusBadRequest}
+	errInvalidRequest        = apiError{"InvalidRequest", "Invalid request.", http.StatusBadRequest}
+	errMalformedAuth         = apiError{"AuthorizationHeaderMalformed", "The authorization header is malformed.", http.StatusBadRequest}
+	errMalformedXML          = apiError{"MalformedXML", "The XML you provided was not well-formed.", http.StatusBadRequest}
+	errMessageTooLarge       = apiError{"MaxMessageLengthExceeded", "Your request was too big.", http.StatusBadRequest}
+	errMissingContentLength  = apiError{"MissingContentLength", "You must provide the Content-Length HTTP header.", http.StatusLengthRequired}
+	errMissingContentMD5     = apiError{"InvalidRequest", "Missing required header for this request: Content-MD5.", http.StatusBadRequest}
+	errMissingAuth           = apiError{"AccessDenied", "Request is not signed.", http.StatusForbidden}
+	errNoSuchBucket          = apiError{"NoSuchBucket", "The specified bucket does not exist.", http.StatusNotFound}
+	errNoSuchKey             = apiError{"NoSuchKey", "The specified key does not exist.", http.StatusNotFound}
+	errNoSuchUpload          = apiError{"NoSuchUpload", "The specified multipart upload does not exist.", http.StatusNotFound}
+	errNotImplemented        = apiError{"NotImplemented", "A header or query you provided implies functionality that is not implemented.", http.StatusNotImplemented}
+	errOperationAborted      = apiError{"OperationAborted", "A conflicting operation is currently in progress against this resource.", http.StatusConflict}
+	errQuotaExceeded         = apiError{"QuotaExceeded", "The storage quota is exceeded.", http.StatusInsufficientStorage}
+	errRequestTimeTooSkewed  = apiError{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", http.StatusForbidden}
+	errSignatureMismatch     = apiError{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", http.StatusForbidden}
+)
+
+// errorResponse is the body of the error responses
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource"`
+	RequestID string   `xml:"RequestId"`
+}
+
+// writeError responds with the error
+func writeError(c *gin.Context, e apiError) {
+	body := errorResponse{
+		Code:      e.Code,
+		Message:   e.Message,
+		Resource:  c.Request.URL.Path,
+		RequestID: c.GetString(requestIDKey),
+	}
+	c.Abort()
+	if c.Request.Method == http.MethodHead {
+		c.Status(e.StatusCode) // The response of HEAD has no body
+		return
+	}
+	c.XML(e.StatusCode, body)
+}
+
+// writeXML responds with the body in the namespace of S3
+func writeXML(c *gin.Context, body any) {
+	c.Header("Content-Type", "application/xml")
+	c.Status(http.StatusOK)
+	_, _ = c.Writer.WriteString(xml.Header)
+	_ = xml.NewEncoder(c.Writer).Encode(body)
+}
+
+// newRequestID returns a random ID of the request
+func newRequestID() string {
+	return util.RandomString(16)
+}
+
+// formatTime formats the time for the S3 responses
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// bucketInfo is a bucket in the ListBuckets response
+type bucketInfo struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+// listBucketsResponse is the body of the ListBuckets response
+type listBucketsResponse struct {
+	XMLName xml.Name     `xml:"ListAllMyBucketsResult"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Owner   owner        `xml:"Owner"`
+	Buckets []bucketInfo `xml:"Buckets>Bucket"`
+}
+
+// owner is the owner of the buckets and the objects
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+// object is an object in the listing
+type object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	Owner        *owner `xml:"Owner,omitempty"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+// commonPrefix is a group of the keys sharing the prefix up to the delimiter
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// listObjectsResponse is the body of the ListObjects and ListObjectsV2 responses
+type listObjectsResponse struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Xmlns                 string         `xml:"xmlns,attr"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Marker                *string        `xml:"Marker,omitempty"`
+	NextMarker            string         `xml:"NextMarker,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	KeyCount              *int           `xml:"KeyCount,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	EncodingType          string         `xml:"EncodingType,omitempty"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []object       `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+// locationResponse is the body of the GetBucketLocation response
+type locationResponse struct {
+	XMLName  xml.Name `xml:"LocationConstraint"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:",chardata"`
+}
+
+// copyObjectResponse is the body of the CopyObject response
+type copyObjectResponse struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+// deleteRequest is the body of the DeleteObjects request
+type deleteRequest struct {
+	XMLName xml.Name `xml:"Delete"`
+	Quiet   bool     `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+// deletedObject is an object deleted by the DeleteObjects request
+type deletedObject struct {
+	Key string `xml:"Key"`
+}
+
+// deleteError is an object failed to be deleted by the DeleteObjects request
+type deleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// deleteResponse is the body of the DeleteObjects response
+type deleteResponse struct {
+	XMLName xml.Name        `xml:"DeleteResult"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Deleted []deletedObject `xml:"Deleted"`
+	Errors  []deleteError   `xml:"Error"`
+}
+
+// initiateMultipartUploadResponse is the body of the CreateMultipartUpload response
+type initiateMultipartUploadResponse struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+// completePart is a part in the CompleteMultipartUpload request
+type completePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// completeMultipartUploadRequest is the body of the CompleteMultipartUpload request
+type completeMultipartUploadRequest struct {
+	XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+	Parts   []completePart `xml:"Part"`
+}
+
+// completeMultipartUploadResponse is the body of the CompleteMultipartUpload response
+type completeMultipartUploadResponse struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// part is a part in the ListParts response
+type part struct {
+	PartNumber   int    `xml:"PartNumber"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+}
+
+// listPartsResponse is the body of the ListParts response
+type listPartsResponse struct {
+	XMLName  xml.Name `xml:"ListPartsResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+	Parts    []part   `xml:"Part"`
+}
@@ -7,12 +7,16 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/vvbbnn00/goflet/config"
 	"github.com/vvbbnn00/goflet/route"
+	"github.com/vvbbnn00/goflet/storage/index"
+	"github.com/vvbbnn00/goflet/util"
 )
 
 var (
@@ -20,6 +24,7 @@ var (
 	targetPath   = "/tmp/target.txt"
 	invalidPath  = "/tmp/invalid.txt"
 	imagePath    = "/tmp/image.jpg"
+	svgPath      = "/tmp/image.svg"
 	metaFilePath = "/tmp/meta.txt"
 	newFilePath  = "/tmp/newfile.txt"
 )
@@ -52,6 +57,7 @@ func TestPrepareFileActions(_ *testing.T) {
 
 	postUploadFile(sourcePath, gifData)
 	postUploadFile(imagePath, gifData)
+	postUploadFile(svgPath, []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="1" height="1"></svg>`))
 	postUploadFile(metaFilePath, gifData)
 
 	time.Sleep(100 * time.Millisecond)
@@ -167,6 +173,9 @@ func TestGetImage(t *testing.T) {
 		{"Get Existing Image", imagePath, "", http.StatusOK},
 		{"Get Non-Existing Image", invalidPath, "", http.StatusNotFound},
 		{"Get Image with Parameters", imagePath, "?w=100&h=100&q=80&f=jpg&a=90&s=fit", http.StatusOK},
+		{"Get Image as WebP", imagePath, "?f=webp", http.StatusOK},
+		{"Get Unsupported Image", svgPath, "", http.StatusUnsupportedMediaType},
+		{"Get Unknown Preset", imagePath, "?p=missing", http.StatusBadRequest},
 	}
 
 	for _, tt := range tests {
@@ -180,6 +189,83 @@ func TestGetImage(t *testing.T) {
 	}
 }
 
+func TestGetImageInfo(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"Get Image Info", "/api/image-info" + imagePath, http.StatusOK},
+		{"Get Non-Existing Image Info", "/api/image-info" + invalidPath, http.StatusNotFound},
+		{"Get Unsupported Image Info", "/api/image-info" + svgPath, http.StatusUnsupportedMediaType},
+		{"Strip Non-JPEG Image", "/file" + imagePath + "?stripExif=1", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/image-info"+imagePath, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	info := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, "gif", info["format"])
+	assert.Equal(t, float64(1), info["frames"])
+	assert.NotEmpty(t, info["blurHash"])
+}
+
+// TestImageCacheRemoved tests the processed images in the image cache path are removed with the replaced files
+func TestImageCacheRemoved(t *testing.T) {
+	// cached counts the processed images of the path in the image cache path
+	cached := func(path string) int {
+		pathData, err := util.ParsePath(path)
+		if err != nil {
+			return 0
+		}
+		images, _ := filepath.Glob(filepath.Join(config.GofletCfg.ImageCacheConfig.Path, "*", "*",
+			filepath.Base(pathData.FsPath), "*"))
+		return len(images)
+	}
+	// request sends the request and returns the status code
+	request := func(method string, path string, body any) int {
+		data, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewReader(data))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	processed := func(path string) {
+		assert.Equal(t, http.StatusOK, request(http.MethodGet, "/api/image"+path+"?w=10", nil))
+		assert.Eventually(t, func() bool { return cached(path) == 1 }, time.Second, 10*time.Millisecond)
+	}
+
+	target := "/tmp/cached-" + util.RandomString(8) + ".gif"
+	moved := "/tmp/cached-" + util.RandomString(8) + ".gif"
+	copyRequest := CopyMoveFileRequest{OnConflict: "overwrite", SourcePath: imagePath, TargetPath: target}
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "/api/action/copy", copyRequest))
+
+	processed(target)
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "/api/action/copy", copyRequest))
+	assert.Equal(t, 0, cached(target))
+
+	processed(target)
+	moveRequest := CopyMoveFileRequest{OnConflict: "overwrite", SourcePath: target, TargetPath: moved}
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "/api/action/move", moveRequest))
+	assert.Equal(t, 0, cached(target))
+	assert.Equal(t, 0, cached(moved))
+
+	processed(moved)
+	assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, "/file"+moved, nil))
+	assert.Equal(t, 0, cached(moved))
+}
+
 func TestGetFileMeta(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -200,3 +286,62 @@ func TestGetFileMeta(t *testing.T) {
 		})
 	}
 }
+
+// TestUpdateFileMeta tests setting the focal point of a file
+func TestUpdateFileMeta(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"Set Focal Point", imagePath, `{"focalPoint":{"x":0.25,"y":0.75}}`, http.StatusOK},
+		{"Invalid Focal Point", imagePath, `{"focalPoint":{"x":2,"y":0.75}}`, http.StatusBadRequest},
+		{"Non-Existing File", invalidPath, `{"focalPoint":{"x":0.5,"y":0.5}}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPut, "/api/meta"+tt.path, bytes.NewReader([]byte(tt.body)))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/api/meta"+imagePath, bytes.NewReader([]byte(`{"focalPoint":null}`)))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "focalPoint")
+}
+
+// TestListFolder tests listing the folders
+func TestListFolder(t *testing.T) {
+	folder := "/list/" + util.RandomString(8)
+	postUploadFile(folder+"/a.txt", gifData)
+	postUploadFile(folder+"/sub/b.txt", gifData)
+	time.Sleep(100 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/list"+folder, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var entries []index.Entry
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Equal(t, []index.Entry{
+		{Name: "a.txt", Path: folder[1:] + "/a.txt"},
+		{Name: "sub", Path: folder[1:] + "/sub", IsDir: true},
+	}, entries)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/api/list"+folder+"/missing", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	for _, name := range []string{"/a.txt", "/sub/b.txt"} {
+		req, _ = http.NewRequest(http.MethodDelete, "/file"+folder+name, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
@@ -0,0 +1,158 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/image"
+	"github.com/vvbbnn00/goflet/util"
+)
+
+// importRequest sends the import request with the body
+func importRequest(body map[string]any) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest(http.MethodPost, "/api/admin/import", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestImportFolder tests importing a folder under the import roots
+func TestImportFolder(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "share")
+	assert.NoError(t, os.MkdirAll(src, os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "imported.gif"), gifData, 0644))
+	prefix := "/tmp/" + util.RandomString(8)
+
+	// The folders not under the import roots are not allowed
+	w := importRequest(map[string]any{"source": src, "prefix": prefix})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	config.GofletCfg.AdminConfig.ImportRoots = []string{root}
+	defer func() {
+		config.GofletCfg.AdminConfig.ImportRoots = nil
+	}()
+	w = importRequest(map[string]any{"source": filepath.Join(root, "missing"), "prefix": prefix})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = importRequest(map[string]any{"source": src, "prefix": prefix})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"files":1`)
+
+	req, _ := http.NewRequest(http.MethodGet, "/file"+prefix+"/imported.gif", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, gifData, w.Body.Bytes())
+	assert.Equal(t, "image/gif", w.Header().Get("Content-Type"))
+}
+
+// TestImportBucket tests the token bound to a bucket cannot import out of the bucket
+func TestImportBucket(t *testing.T) {
+	image.WaitAnalysis() // The images uploaded before read the bucket config while they are analyzed
+	root := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "imported.txt"), []byte("imported"), 0644))
+	config.GofletCfg.AdminConfig.ImportRoots = []string{root}
+	*config.GofletCfg.JWTConfig.Enabled = true
+	*config.GofletCfg.BucketConfig.Enabled = true
+	config.GofletCfg.BucketConfig.Buckets = map[string]config.Bucket{
+		"tmp": {JWTConfig: config.BucketJWTConfig{SigningKey: "bucket-key"}},
+	}
+	config.InitBuckets()
+	defer func() {
+		config.GofletCfg.AdminConfig.ImportRoots = nil
+		*config.GofletCfg.JWTConfig.Enabled = false
+		*config.GofletCfg.BucketConfig.Enabled = false
+		config.GofletCfg.BucketConfig.Buckets = nil
+	}()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &util.JwtClaims{
+		StandardClaims: &jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Hour).Unix()},
+		Permissions:    []util.Permission{{Path: "/api/admin/import", Methods: []string{"POST"}}},
+		Bucket:         "tmp",
+	}).SignedString([]byte("bucket-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := func(prefix string) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(map[string]any{"source": root, "prefix": prefix})
+		req, _ := http.NewRequest(http.MethodPost, "/api/admin/import", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// exists checks whether the file is stored at the path
+	exists := func(path string) bool {
+		pathData, err := util.ParsePath(path)
+		return err == nil && storage.FileExists(pathData.FsPath)
+	}
+
+	name := util.RandomString(8)
+	assert.Equal(t, http.StatusUnauthorized, request("tmp/../"+name).Code)
+	assert.False(t, exists("/"+name+"/imported.txt"))
+
+	w := request("tmp/" + name + "/../" + name)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"files":1`)
+	assert.True(t, exists("/tmp/"+name+"/imported.txt"))
+}
+
+// TestImageCacheStats tests getting the stats of the image cache and cleaning it
+func TestImageCacheStats(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/api/admin/image-cache", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"maxFiles":100000`)
+
+	req, _ = http.NewRequest(http.MethodPost, "/api/admin/image-cache/clean", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"lastCleanup":0`)
+}
+
+// TestImportQuota tests the files imported through the API are checked against the quotas
+func TestImportQuota(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, name), []byte(name), 0644))
+	}
+	prefix := "/tmp/" + util.RandomString(8)
+	config.GofletCfg.AdminConfig.ImportRoots = []string{root}
+	*config.GofletCfg.QuotaConfig.Enabled = true
+	config.GofletCfg.QuotaConfig.Prefixes = map[string]config.QuotaLimit{prefix: {MaxFiles: 1}}
+	defer func() {
+		config.GofletCfg.AdminConfig.ImportRoots = nil
+		*config.GofletCfg.QuotaConfig.Enabled = false
+		config.GofletCfg.QuotaConfig.Prefixes = nil
+	}()
+
+	w := importRequest(map[string]any{"source": root, "prefix": prefix, "parallel": 2})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result struct {
+		Files  int               `json:"files"`
+		Failed map[string]string `json:"failed"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 1, result.Files)
+	assert.Len(t, result.Failed, 1)
+	for _, err := range result.Failed {
+		assert.Equal(t, "quota_exceeded", err)
+	}
+}
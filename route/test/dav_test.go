@@ -0,0 +1,126 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/util"
+)
+
+// davRequest sends the WebDAV request to the router
+func davRequest(method string, path string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "/dav"+path, strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestDavAuth(t *testing.T) {
+	*config.GofletCfg.JWTConfig.Enabled = true
+	defer func() {
+		*config.GofletCfg.JWTConfig.Enabled = false
+	}()
+
+	w := davRequest("PROPFIND", "/", "", map[string]string{"Depth": "0"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Basic realm="goflet"`, w.Header().Get("WWW-Authenticate"))
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &util.JwtClaims{
+		StandardClaims: &jwt.StandardClaims{ExpiresAt: time.Now().Add(time.Hour).Unix()},
+		Permissions:    []util.Permission{{Path: "/dav/*", Methods: []string{"PROPFIND"}}},
+	}).SignedString([]byte(config.GofletCfg.JWTConfig.Security.SigningKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("PROPFIND", "/dav/", http.NoBody)
+	req.Header.Set("Depth", "0")
+	req.SetBasicAuth("goflet", token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	// The token is not authorized for the method
+	req, _ = http.NewRequest(http.MethodDelete, "/dav/tmp", http.NoBody)
+	req.SetBasicAuth("goflet", token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestDav(t *testing.T) {
+	folder := "/tmp/dav-" + util.RandomString(8)
+
+	assert.Equal(t, http.StatusCreated, davRequest("MKCOL", folder, "", nil).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, davRequest("MKCOL", folder, "", nil).Code)
+	assert.Equal(t, http.StatusConflict, davRequest("MKCOL", folder+"/a/b", "", nil).Code)
+	assert.Equal(t, http.StatusCreated, davRequest("MKCOL", folder+"/empty", "", nil).Code)
+
+	assert.Equal(t, http.StatusCreated, davRequest(http.MethodPut, folder+"/a.gif", string(gifData), nil).Code)
+
+	// The upload is completed in the background
+	assert.Eventually(t, func() bool {
+		return davRequest(http.MethodGet, folder+"/a.gif", "", nil).Code == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+	w := davRequest(http.MethodGet, folder+"/a.gif", "", nil)
+	assert.Equal(t, gifData, w.Body.Bytes())
+
+	w = davRequest("PROPFIND", folder, "", map[string]string{"Depth": "1"})
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, folder+"/a.gif")
+	assert.Contains(t, body, folder+"/empty/")
+	assert.Contains(t, body, "<D:getcontenttype>image/gif</D:getcontenttype>")
+
+	// Move the folder with the file and the empty folder
+	w = davRequest("MOVE", folder, "", map[string]string{"Destination": "/dav" + folder + "-moved"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, http.StatusNotFound, davRequest("PROPFIND", folder, "", map[string]string{"Depth": "0"}).Code)
+	assert.Equal(t, http.StatusMultiStatus, davRequest("PROPFIND", folder+"-moved/empty", "", map[string]string{"Depth": "0"}).Code)
+
+	w = davRequest(http.MethodGet, folder+"-moved/a.gif", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, gifData, w.Body.Bytes())
+
+	assert.Equal(t, http.StatusNoContent, davRequest(http.MethodDelete, folder+"-moved", "", nil).Code)
+	assert.Equal(t, http.StatusNotFound, davRequest(http.MethodGet, folder+"-moved/a.gif", "", nil).Code)
+	assert.Equal(t, http.StatusNotFound, davRequest("PROPFIND", folder+"-moved", "", map[string]string{"Depth": "0"}).Code)
+}
+
+// TestDavUploadSession tests that the WebDAV upload does not touch the chunked upload of the same path
+func TestDavUploadSession(t *testing.T) {
+	path := "/tmp/dav-" + util.RandomString(8) + ".txt"
+	uploadRange := func(method string, body string, contentRange string) int {
+		req, _ := http.NewRequest(method, "/upload"+path, strings.NewReader(body))
+		if contentRange != "" {
+			req.Header.Set("Content-Range", contentRange)
+			req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusAccepted, uploadRange(http.MethodPut, "hello", "bytes 0-4/10"))
+	assert.Equal(t, http.StatusCreated, davRequest(http.MethodPut, path, "dav", nil).Code)
+	assert.Equal(t, "dav", davRequest(http.MethodGet, path, "", nil).Body.String())
+
+	assert.Equal(t, http.StatusAccepted, uploadRange(http.MethodPut, "world", "bytes 5-9/10"))
+	assert.Equal(t, http.StatusCreated, uploadRange(http.MethodPost, "", ""))
+	assert.Eventually(t, func() bool {
+		return davRequest(http.MethodGet, path, "", nil).Body.String() == "helloworld"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, http.StatusNoContent, davRequest(http.MethodDelete, path, "", nil).Code)
+}
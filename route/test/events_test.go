@@ -0,0 +1,95 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event/stream"
+	"github.com/vvbbnn00/goflet/util"
+)
+
+// openEventStream connects to the event stream, the client is subscribed once the response is received
+func openEventStream(t *testing.T, server *httptest.Server, query string, lastEventID string) *http.Response {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/events"+query, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = resp.Body.Close()
+	})
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	return resp
+}
+
+// readEventIDs reads the ids of the server-sent events until the count is reached or the stream ends
+func readEventIDs(resp *http.Response, count int) []string {
+	var ids []string
+	scanner := bufio.NewScanner(resp.Body)
+	for len(ids) < count && scanner.Scan() {
+		if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func createFile(path string) {
+	body, _ := json.Marshal(CreateFileRequest{Path: path})
+	req, _ := http.NewRequest(http.MethodPost, "/api/action/create", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestEventStream(t *testing.T) {
+	stream.Start()
+
+	// The stream should work without the heartbeat, it is restored after the server is closed
+	interval := config.GofletCfg.EventStreamConfig.HeartbeatInterval
+	config.GofletCfg.EventStreamConfig.HeartbeatInterval = 0
+	t.Cleanup(func() {
+		config.GofletCfg.EventStreamConfig.HeartbeatInterval = interval
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close) // After the streams are closed
+
+	prefix := "/tmp/events-" + util.RandomString(8)
+	resp := openEventStream(t, server, "?prefix="+prefix, "")
+	ids := make(chan []string)
+	go func() {
+		ids <- readEventIDs(resp, 2)
+	}()
+
+	createFile("/tmp/events-other.txt") // Not under the prefix
+	createFile(prefix + "/a.txt")
+	createFile(prefix + "/b.txt")
+
+	received := <-ids
+	assert.Len(t, received, 2)
+
+	// Resume from the first event
+	resumed := readEventIDs(openEventStream(t, server, "?prefix="+prefix, received[0]), 1)
+	assert.Equal(t, received[1:], resumed)
+
+	for _, path := range []string{"/tmp/events-other.txt", prefix + "/a.txt", prefix + "/b.txt"} {
+		req, _ := http.NewRequest(http.MethodDelete, "/file"+path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
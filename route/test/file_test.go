@@ -5,6 +5,8 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
@@ -27,6 +29,10 @@ var (
 func init() {
 	config.InitConfig()
 	*config.GofletCfg.JWTConfig.Enabled = false
+	*config.GofletCfg.EventStreamConfig.Enabled = true
+	*config.GofletCfg.WebDAVConfig.Enabled = true
+	// The processed images are stored in the image cache path, so they are kept apart from the files
+	config.GofletCfg.ImageCacheConfig.Path = filepath.Join(os.TempDir(), "goflet-image-cache-"+util.RandomString(8))
 	router = route.RegisterRoutes()
 	prepareFileUpload()
 }
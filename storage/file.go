@@ -5,9 +5,11 @@ import (
 	"encoding/gob"
 	"errors"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -20,10 +22,12 @@ import (
 const (
 	maxRetryCount = 100          // The maximum number of times to retry the file uploadconst (
 	CachePrefix   = "uploading:" // CachePrefix is the cache prefix for the file upload
+	deleteRetries = 3            // The maximum number of times to retry removing the folder of a deleted file
 )
 
 var (
 	basePath string
+	metaLock sync.Mutex // Serializes the updates of the file metadata, the hash and the image info are stored concurrently
 )
 
 // init initializes the storage package
@@ -103,20 +107,11 @@ func GetFileMeta(fsPath string) model.FileMeta {
 	}
 
 	log.Debugf("Cache miss: %s", metaFilePath)
-	metaFile, err := os.OpenFile(metaFilePath, os.O_RDONLY, model.FilePerm)
-
-	fileMeta := model.FileMeta{}
-
-	if err == nil {
-		gerr := gob.NewDecoder(metaFile).Decode(&fileMeta)
-		if gerr != nil {
-			log.Warnf("Error decoding meta file: %s", gerr.Error())
-		}
+	fileMeta, err := LoadFileMeta(fsPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("Error decoding meta file: %s", err.Error())
 	}
 
-	// Close the file
-	_ = metaFile.Close()
-
 	// Cache the file metadata
 	go func() {
 		metaFileString := strings.Builder{}
@@ -127,9 +122,60 @@ func GetFileMeta(fsPath string) model.FileMeta {
 	return fileMeta
 }
 
+// LoadFileMeta reads the file metadata for the file at the provided path from the disk, bypassing the cache
+func LoadFileMeta(fsPath string) (model.FileMeta, error) {
+	metaFilePath := filepath.Join(fsPath, model.MetaAppend)
+	fileMeta := model.FileMeta{}
+
+	metaFile, err := os.OpenFile(metaFilePath, os.O_RDONLY, model.FilePerm)
+	if err != nil {
+		return fileMeta, err
+	}
+	defer func() {
+		_ = metaFile.Close()
+	}()
+
+	err = gob.NewDecoder(metaFile).Decode(&fileMeta)
+	return fileMeta, err
+}
+
+// WalkFiles calls the function with the fs path, the size and the metadata of every stored file in the storage roots,
+// the files without valid metadata are skipped
+func WalkFiles(fn func(fsPath string, size int64, meta model.FileMeta)) {
+	for _, root := range util.GetStorageRoots() {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // Skip the unreadable entries
+			}
+			if d.IsDir() || d.Name() != model.FileAppend {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			fsPath := filepath.Dir(path)
+			meta, err := LoadFileMeta(fsPath)
+			if err != nil || meta.RelativePath == "" {
+				log.Debugf("Skip file without valid meta: %s", path)
+				return nil
+			}
+			fn(fsPath, info.Size(), meta)
+			return nil
+		})
+	}
+}
+
 // UpdateFileMeta updates the file metadata for the file at the provided path
 func UpdateFileMeta(fsPath string, fileMeta model.FileMeta) error {
-	oldFileMeta := GetFileMeta(fsPath)
+	metaLock.Lock()
+	defer metaLock.Unlock()
+
+	// Read from the disk, the cache may not contain the latest concurrent update yet
+	oldFileMeta, err := LoadFileMeta(fsPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("Error decoding meta file: %s", err.Error())
+	}
 
 	// Merge the old and new file metadata
 	if fileMeta.RelativePath == "" {
@@ -153,8 +199,60 @@ func UpdateFileMeta(fsPath string, fileMeta model.FileMeta) error {
 	if fileMeta.MimeType == "" {
 		fileMeta.MimeType = oldFileMeta.MimeType
 	}
+	if fileMeta.Owner == "" {
+		fileMeta.Owner = oldFileMeta.Owner
+	}
+	if fileMeta.FocalPoint == nil {
+		fileMeta.FocalPoint = oldFileMeta.FocalPoint
+	}
+	if fileMeta.Image == nil {
+		fileMeta.Image = oldFileMeta.Image
+	}
+
+	return saveFileMeta(fsPath, fileMeta)
+}
+
+// SetFocalPoint sets the focal point of the file at the provided path, nil clears the focal point
+func SetFocalPoint(fsPath string, focalPoint *model.FocalPoint) error {
+	metaLock.Lock()
+	defer metaLock.Unlock()
+
+	fileMeta, err := LoadFileMeta(fsPath)
+	if err != nil {
+		return err
+	}
+	fileMeta.FocalPoint = focalPoint
+	return saveFileMeta(fsPath, fileMeta)
+}
+
+// SetFileHash sets the hashes of the file at the provided path, empty hashes mark the content as not hashed yet
+func SetFileHash(fsPath string, fileHash model.FileHash) error {
+	metaLock.Lock()
+	defer metaLock.Unlock()
 
-	// Save the new file metadata
+	fileMeta, err := LoadFileMeta(fsPath)
+	if err != nil {
+		return err
+	}
+	fileMeta.Hash = fileHash
+	return saveFileMeta(fsPath, fileMeta)
+}
+
+// SetImageMeta sets the image information of the file at the provided path
+func SetImageMeta(fsPath string, imageMeta *model.ImageMeta) error {
+	metaLock.Lock()
+	defer metaLock.Unlock()
+
+	fileMeta, err := LoadFileMeta(fsPath)
+	if err != nil {
+		return err
+	}
+	fileMeta.Image = imageMeta
+	return saveFileMeta(fsPath, fileMeta)
+}
+
+// saveFileMeta writes the file metadata of the file at the provided path and caches it
+func saveFileMeta(fsPath string, fileMeta model.FileMeta) error {
 	metaFilePath := filepath.Join(fsPath, model.MetaAppend)
 	tmpFilePath := filepath.Join(fsPath, "tmp-meta-"+util.RandomString(10))
 	metaFile, err := os.OpenFile(tmpFilePath, os.O_CREATE|os.O_RDWR, model.FilePerm)
@@ -175,14 +273,12 @@ func UpdateFileMeta(fsPath string, fileMeta model.FileMeta) error {
 		return err
 	}
 
-	// Cache the file metadata
-	go func() {
-		c := cache.GetCache()
-		cacheKey := model.FileMetaCachePrefix + metaFilePath
-		metaFileString := strings.Builder{}
-		_ = gob.NewEncoder(&metaFileString).Encode(fileMeta)
-		_ = c.Set(cacheKey, metaFileString.String())
-	}()
+	// Cache the file metadata, synchronously so the concurrent updates are cached in order
+	c := cache.GetCache()
+	cacheKey := model.FileMetaCachePrefix + metaFilePath
+	metaFileString := strings.Builder{}
+	_ = gob.NewEncoder(&metaFileString).Encode(fileMeta)
+	_ = c.Set(cacheKey, metaFileString.String())
 
 	return nil
 }
@@ -195,12 +291,17 @@ func DeleteFile(fsPath string) error {
 		return errors.New("file_not_found")
 	}
 
-	// Delete the folder and its contents
-	err = os.RemoveAll(fsPath)
-	if err != nil {
-		return err
+	// Hold the meta lock, so the pending hash and image tasks cannot write the metadata into the folder being removed
+	metaLock.Lock()
+	defer metaLock.Unlock()
+
+	// Delete the folder and its contents, retry if a processed image is written into the folder meanwhile
+	for attempt := 0; ; attempt++ {
+		err = os.RemoveAll(fsPath)
+		if err == nil || attempt >= deleteRetries || !errors.Is(err, syscall.ENOTEMPTY) {
+			return err
+		}
 	}
-	return nil
 }
 
 // moveFileCrossDevice If the source and destination are on different devices, use io.Copy to move the file
@@ -288,6 +389,9 @@ func copyFolderContents(srcDir, dstDir string) error {
 		if entry.IsDir() {
 			continue // Skip directories
 		}
+		if strings.HasPrefix(entry.Name(), "tmp-") {
+			continue // Skip the temporary files being written
+		}
 
 		srcPath := filepath.Join(srcDir, entry.Name())
 		dstPath := filepath.Join(dstDir, entry.Name())
@@ -300,15 +404,17 @@ func copyFolderContents(srcDir, dstDir string) error {
 }
 
 // CopyFile copies the whole folder of the source to the target and update the metadata
-// src and dst are absolute path of file
-func CopyFile(src, dst *util.Path) error {
+// src and dst are absolute path of file, the owner of the copy is kept as the source if owner is empty
+func CopyFile(src, dst *util.Path, owner string) error {
 	// Check if the source file exists
 	if !FileExists(src.FsPath) {
 		return errors.New("source_file_not_found")
 	}
 
-	// Copy the folder
+	// Copy the folder, the metadata of the source is not updated meanwhile
+	metaLock.Lock()
 	err := copyFolderContents(src.FsPath, dst.FsPath)
+	metaLock.Unlock()
 	if err != nil {
 		log.Debugf("Error copying folder contents: %s", err.Error())
 		return err
@@ -323,6 +429,9 @@ func CopyFile(src, dst *util.Path) error {
 	srcMeta.RelativePath = dst.RelativePath
 	srcMeta.FileName = filepath.Base(dst.RelativePath)
 	srcMeta.UploadedAt = time.Now().Unix()
+	if owner != "" {
+		srcMeta.Owner = owner
+	}
 
 	// Update the metadata
 	return UpdateFileMeta(dst.FsPath, srcMeta)
@@ -373,7 +482,7 @@ func MoveFile(src, dst *util.Path) error {
 }
 
 // CreateFile creates a new file at the provided path and updates the metadata
-func CreateFile(pathData *util.Path) error {
+func CreateFile(pathData *util.Path, owner string) error {
 	// Make sure the folder exists
 	err := os.MkdirAll(pathData.FsPath, os.ModePerm)
 	if err != nil {
@@ -396,6 +505,7 @@ func CreateFile(pathData *util.Path) error {
 		FileName:     filepath.Base(pathData.FsPath),
 		RelativePath: pathData.RelativePath,
 		UploadedAt:   time.Now().Unix(),
+		Owner:        owner,
 	}
 
 	return UpdateFileMeta(pathData.FsPath, fileMeta)
@@ -0,0 +1,213 @@
+// Package fileop provides the file operations shared by the APIs, the quotas are reserved and applied
+// and the events are published in the same way whichever API the operation comes from
+package fileop
+
+import (
+	"errors"
+
+	"github.com/vvbbnn00/goflet/cache"
+	"github.com/vvbbnn00/goflet/event"
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/image"
+	"github.com/vvbbnn00/goflet/storage/index"
+	"github.com/vvbbnn00/goflet/storage/quota"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// lockFile locks the file in case the file upload is in progress, returns the function to unlock it
+func lockFile(fsPath string) func() {
+	ca := cache.GetCache()
+	_ = ca.SetEx(storage.CachePrefix+fsPath, true, 60)
+	return func() {
+		_ = ca.Del(storage.CachePrefix + fsPath)
+	}
+}
+
+// Create creates an empty file owned by the actor, fails if the file already exists
+func Create(actor event.Actor, pathData *util.Path) error {
+	if storage.FileExists(pathData.FsPath) {
+		return errors.New("file_exists")
+	}
+
+	// Reserve the quota for the new file
+	release, err := quota.Reserve(quota.Delta{
+		RelativePath: pathData.RelativePath,
+		Owner:        actor.Subject,
+		Files:        1,
+	})
+	if err != nil {
+		return err
+	}
+
+	unlock := lockFile(pathData.FsPath)
+	defer unlock()
+
+	// Create the file and update the metadata
+	if err := storage.CreateFile(pathData, actor.Subject); err != nil {
+		release()
+		return err
+	}
+	event.Publish(event.NewFileEvent(event.TypeFileCreated, actor, pathData.RelativePath, pathData.FsPath))
+	return nil
+}
+
+// Delete deletes the file
+func Delete(actor event.Actor, pathData *util.Path) error {
+	deleted, _ := quota.Existing(pathData.FsPath)
+	deletedEvent := event.NewFileEvent(event.TypeFileDeleted, actor, pathData.RelativePath, pathData.FsPath)
+	if err := storage.DeleteFile(pathData.FsPath); err != nil {
+		return err
+	}
+	image.RemoveImageCache(pathData.FsPath) // The processed images may be stored in the image cache path
+	quota.Apply(deleted.Negate())
+	event.Publish(deletedEvent)
+	return nil
+}
+
+// copyMoveDeltas returns the quota changes of copying or moving the source to the target,
+// the removal of the existing target is returned separately
+func copyMoveDeltas(actor event.Actor, sourcePath, targetPath *util.Path, move bool) ([]quota.Delta, *quota.Delta) {
+	source, _ := quota.Existing(sourcePath.FsPath)
+	copied := source
+	copied.RelativePath = targetPath.RelativePath
+
+	deltas := []quota.Delta{copied}
+	if move {
+		deltas = append(deltas, source.Negate())
+	} else if actor.Subject != "" {
+		deltas[0].Owner = actor.Subject // The copy belongs to the subject copying it
+	}
+
+	if target, exists := quota.Existing(targetPath.FsPath); exists {
+		removed := target.Negate()
+		return deltas, &removed
+	}
+	return deltas, nil
+}
+
+// prepareCopyMove checks the source and target paths and reserves the quota, the existing target is deleted if
+// overwrite is set, returns the function to release the quota if the operation fails
+func prepareCopyMove(actor event.Actor, sourcePath, targetPath *util.Path, overwrite, move bool) (func(), error) {
+	if sourcePath.FsPath == targetPath.FsPath {
+		return nil, errors.New("same_path")
+	}
+
+	// The buckets are isolated, the files cannot be copied or moved across them
+	if util.BucketsEnabled() && util.GetBucketName(sourcePath.RelativePath) != util.GetBucketName(targetPath.RelativePath) {
+		return nil, errors.New("different_buckets")
+	}
+
+	if !storage.FileExists(sourcePath.FsPath) {
+		return nil, errors.New("source_file_not_found")
+	}
+
+	// Reserve the quota for the operation, with the removal of the target if it is overwritten
+	release := func() {}
+	var removed *quota.Delta
+	if quota.Enabled() {
+		var deltas []quota.Delta
+		deltas, removed = copyMoveDeltas(actor, sourcePath, targetPath, move)
+		if removed != nil && !overwrite {
+			removed = nil // The operation fails as the target exists
+		}
+		if removed != nil {
+			deltas = append(deltas, *removed)
+		}
+		var err error
+		if release, err = quota.Reserve(deltas...); err != nil {
+			return nil, err
+		}
+	}
+
+	if storage.FileExists(targetPath.FsPath) {
+		if !overwrite {
+			release()
+			return nil, errors.New("file_exists")
+		}
+		if err := storage.DeleteFile(targetPath.FsPath); err != nil {
+			log.Debugf("Error deleting target file: %s", err.Error())
+			release()
+			return nil, err
+		}
+	} else if removed != nil {
+		quota.Apply(removed.Negate()) // The target is deleted by another operation, which counted the removal
+	}
+	if removed == nil {
+		return release, nil
+	}
+
+	// The target is removed whether the operation succeeds or not
+	return func() {
+		release()
+		quota.Apply(*removed)
+	}, nil
+}
+
+// publishCopyMoveEvent publishes the event of the target file copied or moved from the source
+func publishCopyMoveEvent(actor event.Actor, eventType event.Type, sourcePath, targetPath *util.Path) {
+	e := event.NewFileEvent(eventType, actor, targetPath.RelativePath, targetPath.FsPath)
+	e.SourcePath = sourcePath.RelativePath
+	event.Publish(e)
+}
+
+// Copy copies the source file to the target, the copy is owned by the actor
+func Copy(actor event.Actor, sourcePath, targetPath *util.Path, overwrite bool) error {
+	release, err := prepareCopyMove(actor, sourcePath, targetPath, overwrite, false)
+	if err != nil {
+		return err
+	}
+
+	unlock := lockFile(targetPath.FsPath)
+	defer unlock()
+
+	// Copy the whole folder of the source to the target and update the metadata
+	if err := storage.CopyFile(sourcePath, targetPath, actor.Subject); err != nil {
+		release()
+		return err
+	}
+	image.RemoveImageCache(targetPath.FsPath) // The processed images of the replaced target
+	publishCopyMoveEvent(actor, event.TypeFileCopied, sourcePath, targetPath)
+	return nil
+}
+
+// Move moves the source file to the target
+func Move(actor event.Actor, sourcePath, targetPath *util.Path, overwrite bool) error {
+	release, err := prepareCopyMove(actor, sourcePath, targetPath, overwrite, true)
+	if err != nil {
+		return err
+	}
+
+	unlock := lockFile(targetPath.FsPath)
+	defer unlock()
+
+	// Move the folder of the source to the target and update the metadata
+	if err := storage.MoveFile(sourcePath, targetPath); err != nil {
+		release()
+		return err
+	}
+	image.RemoveImageCache(sourcePath.FsPath)
+	image.RemoveImageCache(targetPath.FsPath) // The processed images of the replaced target
+	publishCopyMoveEvent(actor, event.TypeFileMoved, sourcePath, targetPath)
+	return nil
+}
+
+// List lists the folder at the relative path, the root is the empty path and only lists the bucket
+// the token is bound to, unless the bucket is empty
+func List(relativePath string, bucket string) ([]index.Entry, error) {
+	entries, ok := index.List(relativePath)
+	if !ok {
+		return nil, errors.New("folder_not_found")
+	}
+	if relativePath != "" || bucket == "" {
+		return entries, nil
+	}
+
+	result := make([]index.Entry, 0, 1)
+	for _, entry := range entries {
+		if util.GetBucketName(entry.Path) == bucket {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
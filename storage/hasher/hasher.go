@@ -2,8 +2,12 @@
 package hasher
 
 import (
+	"errors"
+	"os"
 	"path/filepath"
+	"sync"
 
+	"github.com/vvbbnn00/goflet/event"
 	"github.com/vvbbnn00/goflet/storage"
 	"github.com/vvbbnn00/goflet/storage/model"
 	"github.com/vvbbnn00/goflet/util/hash"
@@ -18,6 +22,8 @@ const (
 
 var hashTaskPool *worker.Pool = worker.NewPool(hashTaskMaxWorkers, hashTaskBufferSize, workerFactory) // The pool of workers for the hash task
 
+var pending sync.WaitGroup // The hash tasks not finished yet
+
 func init() {
 	// Start the hash task pool
 	hashTaskPool.Start()
@@ -29,7 +35,14 @@ func workerFactory() worker.Worker {
 		JobName: "HashTask",
 		Do: func(job worker.Job) error {
 			args := job.Args.([1]string)
-			return updateFileHash(args[0])
+			err := updateFileHash(args[0])
+			if errors.Is(err, os.ErrNotExist) {
+				err = nil // The file is deleted, there is nothing to store
+			}
+			if err == nil || job.RetryCount >= worker.MaxJobRetries {
+				pending.Done() // The task will not be retried
+			}
+			return err
 		},
 	}
 }
@@ -67,6 +80,9 @@ func hashFile(path string) model.FileHash {
 
 // updateFileHash updates the hash of the file
 func updateFileHash(fsPath string) error {
+	if !storage.FileExists(fsPath) {
+		return nil // The file is deleted before it is hashed
+	}
 	fileHash := hashFile(fsPath)
 	err := storage.UpdateFileMeta(fsPath, model.FileMeta{
 		Hash: fileHash,
@@ -75,13 +91,22 @@ func updateFileHash(fsPath string) error {
 		log.Warnf("Error updating file meta: %s", err.Error())
 		return err
 	}
+
+	relativePath := storage.GetFileMeta(fsPath).RelativePath
+	event.Publish(event.NewFileEvent(event.TypeFileHashed, event.Actor{}, relativePath, fsPath))
 	return nil
 }
 
 // HashFileAsync updates the hash of the file asynchronously
 func HashFileAsync(fsPath string) {
+	pending.Add(1)
 	hashTaskPool.JobChain <- worker.Job{
 		RetryCount: 0,
 		Args:       [1]string{fsPath},
 	}
 }
+
+// Wait waits for the hash tasks added before to finish, for the commands exiting after the files are stored
+func Wait() {
+	pending.Wait()
+}
@@ -0,0 +1,298 @@
+package image
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/buckket/go-blurhash"
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/log"
+	"github.com/vvbbnn00/goflet/worker"
+)
+
+const (
+	analyzeTaskMaxWorkers = 2     // The maximum number of workers for the analyze task
+	analyzeTaskBufferSize = 10000 // The buffer size for the analyze task
+
+	analyzeSampleSize  = 64 // The image is downscaled to fit this size before computing the palette and the BlurHash
+	paletteSize        = 5  // The maximum number of the dominant colors
+	paletteBucketShift = 5  // Each channel is reduced to 3 bits, so the similar colors fall in the same bucket
+	blurHashComponents = 4  // The number of the BlurHash components along the longer side
+)
+
+var analyzeTaskPool *worker.Pool = worker.NewPool(analyzeTaskMaxWorkers, analyzeTaskBufferSize, analyzeWorkerFactory) // The pool of workers for the analyze task
+
+var pendingAnalysis sync.WaitGroup // The analyze tasks not finished yet
+
+func init() {
+	// Start the analyze task pool
+	analyzeTaskPool.Start()
+}
+
+// analyzeWorkerFactory creates a new worker
+func analyzeWorkerFactory() worker.Worker {
+	return worker.Worker{
+		JobName: "AnalyzeImageTask",
+		Do: func(job worker.Job) error {
+			args := job.Args.([1]string)
+			err := updateImageMeta(args[0])
+			if errors.Is(err, os.ErrNotExist) {
+				err = nil // The file is deleted, there is nothing to store
+			}
+			if err == nil || job.RetryCount >= worker.MaxJobRetries {
+				pendingAnalysis.Done() // The task will not be retried
+			}
+			return err
+		},
+	}
+}
+
+// AnalyzeFileAsync computes the image information of the file and stores it in the file meta asynchronously
+func AnalyzeFileAsync(fsPath string) {
+	pendingAnalysis.Add(1)
+	analyzeTaskPool.JobChain <- worker.Job{
+		RetryCount: 0,
+		Args:       [1]string{fsPath},
+	}
+}
+
+// WaitAnalysis waits for the analyze tasks added before to finish, for the commands exiting after the files are stored
+func WaitAnalysis() {
+	pendingAnalysis.Wait()
+}
+
+// updateImageMeta analyzes the file and stores its image information, cleared if the file is no longer an image
+func updateImageMeta(fsPath string) error {
+	meta, err := storage.LoadFileMeta(fsPath)
+	if err != nil {
+		return err
+	}
+
+	var imageMeta *model.ImageMeta
+	if IsDecodable(meta.MimeType) {
+		reader, err := storage.GetFileReader(fsPath)
+		if err != nil {
+			return err
+		}
+		imageMeta, err = AnalyzeImage(reader, util.GetImageConfig(meta.RelativePath))
+		_ = reader.Close()
+		if err != nil {
+			log.Warnf("Error analyzing image %s: %s", meta.RelativePath, err.Error())
+		}
+	}
+
+	if imageMeta == nil && meta.Image == nil {
+		return nil
+	}
+	return storage.SetImageMeta(fsPath, imageMeta)
+}
+
+// AnalyzeImage computes the size as displayed, the format, the number of frames, the dominant colors and the BlurHash
+// of the image, the colors and the BlurHash are skipped if the image is larger than the processing limit
+func AnalyzeImage(r io.ReadSeeker, conf *config.ImageConfig) (*model.ImageMeta, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	imageConf, format, err := image.DecodeConfig(r)
+	if errors.Is(err, image.ErrFormat) {
+		return nil, errors.Errorf("unsupported image format")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := exifOrientation(readExif(r))
+	meta := &model.ImageMeta{Width: imageConf.Width, Height: imageConf.Height, Format: format, Frames: 1}
+	if orientation >= 5 {
+		meta.Width, meta.Height = meta.Height, meta.Width
+	}
+
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	switch format {
+	case "gif":
+		meta.Frames = countGifFrames(r)
+	case "webp":
+		meta.Frames = countWebpFrames(r)
+	}
+
+	if meta.Width > conf.MaxWidth || meta.Height > conf.MaxHeight {
+		return meta, nil
+	}
+
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	decoded, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	sample := imaging.Fit(applyOrientation(decoded, orientation), analyzeSampleSize, analyzeSampleSize, imaging.Box)
+
+	meta.Palette = dominantColors(sample)
+	xComponents, yComponents := blurHashComponents, blurHashComponents
+	if meta.Width > meta.Height {
+		yComponents = max(1, blurHashComponents*meta.Height/meta.Width)
+	} else {
+		xComponents = max(1, blurHashComponents*meta.Width/meta.Height)
+	}
+	meta.BlurHash, err = blurhash.Encode(xComponents, yComponents, sample)
+	if err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// dominantColors get the most used colors of the image, the mostly transparent pixels are ignored
+func dominantColors(img *image.NRGBA) []string {
+	type bucket struct {
+		r, g, b, count int
+	}
+	buckets := map[int]*bucket{}
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		r, g, b, a := int(img.Pix[i]), int(img.Pix[i+1]), int(img.Pix[i+2]), img.Pix[i+3]
+		if a < 128 {
+			continue
+		}
+		key := r>>paletteBucketShift<<6 | g>>paletteBucketShift<<3 | b>>paletteBucketShift
+		if buckets[key] == nil {
+			buckets[key] = &bucket{}
+		}
+		buckets[key].r += r
+		buckets[key].g += g
+		buckets[key].b += b
+		buckets[key].count++
+	}
+
+	sorted := make([]*bucket, 0, len(buckets))
+	for _, b := range buckets {
+		sorted = append(sorted, b)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].count > sorted[j].count
+	})
+
+	palette := make([]string, 0, paletteSize)
+	for _, b := range sorted[:min(len(sorted), paletteSize)] {
+		palette = append(palette, fmt.Sprintf("#%02x%02x%02x", b.r/b.count, b.g/b.count, b.b/b.count))
+	}
+	return palette
+}
+
+// countGifFrames count the image descriptors of the GIF without decoding the frames, 1 if the GIF is invalid
+func countGifFrames(r io.Reader) int {
+	br := bufio.NewReader(r)
+	header := make([]byte, 13) // The header and the logical screen descriptor
+	if _, err := io.ReadFull(br, header); err != nil {
+		return 1
+	}
+	if header[10]&0x80 != 0 {
+		if _, err := br.Discard(3 << (header[10]&0x07 + 1)); err != nil {
+			return 1
+		}
+	}
+
+	frames := 0
+	for {
+		block, err := br.ReadByte()
+		if err != nil {
+			return max(frames, 1)
+		}
+		switch block {
+		case 0x21: // Extension, the label and the sub-blocks
+			if _, err = br.ReadByte(); err == nil {
+				err = skipGifSubBlocks(br)
+			}
+		case 0x2C: // Image descriptor, the local color table, the LZW minimum code size and the sub-blocks
+			descriptor := make([]byte, 9)
+			if _, err = io.ReadFull(br, descriptor); err != nil {
+				break
+			}
+			skip := 1
+			if descriptor[8]&0x80 != 0 {
+				skip += 3 << (descriptor[8]&0x07 + 1)
+			}
+			if _, err = br.Discard(skip); err == nil {
+				err = skipGifSubBlocks(br)
+			}
+			frames++
+		default: // The trailer, or the data is invalid
+			return max(frames, 1)
+		}
+		if err != nil {
+			return max(frames, 1)
+		}
+	}
+}
+
+// skipGifSubBlocks skip the data sub-blocks until the block terminator
+func skipGifSubBlocks(br *bufio.Reader) error {
+	for {
+		size, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			return nil
+		}
+		if _, err = br.Discard(int(size)); err != nil {
+			return err
+		}
+	}
+}
+
+// countWebpFrames count the animation frame chunks of the WebP, 1 if the WebP is not animated
+func countWebpFrames(r io.Reader) int {
+	frames, _ := readWebpAnimation(r)
+	return frames
+}
+
+// readWebpAnimation count the animation frame chunks of the WebP, 1 if the WebP is not animated, and read the loop
+// count of the animation chunk, 0 to loop forever
+func readWebpAnimation(r io.Reader) (int, int) {
+	br := bufio.NewReader(r)
+	header := make([]byte, 12) // RIFF, the size and WEBP
+	if _, err := io.ReadFull(br, header); err != nil {
+		return 1, 0
+	}
+
+	frames, loops := 0, 0
+	chunk := make([]byte, 8) // The FourCC and the size
+	for {
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return max(frames, 1), loops
+		}
+		size := int(binary.LittleEndian.Uint32(chunk[4:]))
+		skip := size + size&1 // The chunks are padded to even sizes
+		switch string(chunk[:4]) {
+		case "ANMF":
+			frames++
+		case "ANIM": // The background color and the loop count
+			anim := make([]byte, 6)
+			if size < len(anim) {
+				break
+			}
+			if _, err := io.ReadFull(br, anim); err != nil {
+				return max(frames, 1), loops
+			}
+			loops = int(binary.LittleEndian.Uint16(anim[4:]))
+			skip -= len(anim)
+		}
+		if _, err := br.Discard(skip); err != nil {
+			return max(frames, 1), loops
+		}
+	}
+}
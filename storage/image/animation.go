@@ -0,0 +1,246 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+
+	"github.com/disintegration/imaging"
+	"github.com/gen2brain/webp"
+	"github.com/pkg/errors"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+const (
+	webpFrameNoBlend    = 0x02      // The ANMF flag to replace the canvas with the frame instead of blending
+	webpVP8XAnimation   = 0x02      // The VP8X flag of the animated WebP
+	webpVP8XAlpha       = 0x10      // The VP8X flag of the WebP with transparency
+	webpMaxFrameDelay   = 1<<24 - 1 // The maximum duration of a WebP frame in milliseconds
+	gifDelayMillisecond = 10        // The GIF delays are in hundredths of a second
+)
+
+// animation contains the full canvas of every frame of an animated image
+type animation struct {
+	frames []image.Image
+	delays []int // The duration of each frame in milliseconds
+	plays  int   // The number of times the animation is played, 0 to loop forever
+}
+
+// decodeAnimation decode every frame of the animated GIF or WebP, nil if the image is not animated, or it has more
+// frames or pixels than the limits, so only its first frame is processed
+func decodeAnimation(r io.ReadSeeker, format string, width, height int, conf *config.ImageConfig) (*animation, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	frames, loops := 1, 0
+	switch format {
+	case "gif":
+		frames = countGifFrames(r)
+	case "webp":
+		frames, loops = readWebpAnimation(r)
+	}
+	if frames <= 1 {
+		return nil, nil
+	}
+	if frames > conf.MaxFrames || int64(width)*int64(height)*int64(frames) > conf.MaxTotalPixels {
+		log.Debugf("The animation of %d frames exceeds the limits, only the first frame is processed", frames)
+		return nil, nil
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if format == "gif" {
+		g, err := gif.DecodeAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return compositeGif(g), nil
+	}
+	w, err := webp.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return &animation{frames: w.Image, delays: w.Delay, plays: loops}, nil
+}
+
+// compositeGif draw the frames of the GIF on the full canvas, following their disposal methods
+func compositeGif(g *gif.GIF) *animation {
+	anim := &animation{}
+	switch {
+	case g.LoopCount < 0:
+		anim.plays = 1
+	case g.LoopCount > 0:
+		anim.plays = g.LoopCount + 1
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.NRGBA
+		if disposal == gif.DisposalPrevious {
+			previous = imaging.Clone(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		anim.frames = append(anim.frames, imaging.Clone(canvas))
+		anim.delays = append(anim.delays, g.Delay[i]*gifDelayMillisecond)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return anim
+}
+
+// processAnimation transform every frame the same way and encode the animation
+func processAnimation(anim *animation, p *ProcessParams, conf *config.ImageConfig, mark image.Image) (*bytes.Buffer, error) {
+	for i, frame := range anim.frames {
+		transformed, err := transformImage(frame, p, conf, mark)
+		if err != nil {
+			return nil, err
+		}
+		anim.frames[i] = transformed
+	}
+
+	if p.Format == PictureFormatWebp {
+		return encodeWebpAnimation(anim, p.Quality)
+	}
+	return encodeGifAnimation(anim)
+}
+
+// isOpaque check if every frame of the animation is fully opaque
+func (a *animation) isOpaque() bool {
+	for _, frame := range a.frames {
+		if opaque, ok := frame.(interface{ Opaque() bool }); !ok || !opaque.Opaque() {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeGifAnimation encode the animation as a GIF, the transparent color replaces black in the palette if needed
+func encodeGifAnimation(anim *animation) (*bytes.Buffer, error) {
+	colors := color.Palette(palette.Plan9)
+	if !anim.isOpaque() {
+		colors = append(color.Palette{color.Transparent}, palette.Plan9[1:]...)
+	}
+
+	out := &gif.GIF{}
+	switch {
+	case anim.plays == 1:
+		out.LoopCount = -1
+	case anim.plays > 1:
+		out.LoopCount = anim.plays - 1
+	}
+	for i, frame := range anim.frames {
+		bounds := frame.Bounds()
+		paletted := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), colors)
+		draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), frame, bounds.Min)
+		out.Image = append(out.Image, paletted)
+		out.Delay = append(out.Delay, anim.delays[i]/gifDelayMillisecond)
+		out.Disposal = append(out.Disposal, gif.DisposalBackground) // Every frame is a full canvas
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, out); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// encodeWebpAnimation encode every frame as a still WebP, and assemble their bitstreams in an animated WebP
+func encodeWebpAnimation(anim *animation, quality int) (*bytes.Buffer, error) {
+	bounds := anim.frames[0].Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	var body bytes.Buffer
+	flags := byte(webpVP8XAnimation)
+	if !anim.isOpaque() {
+		flags |= webpVP8XAlpha
+	}
+	vp8x := []byte{flags, 0, 0, 0}
+	vp8x = append(vp8x, uint24(width-1)...)
+	vp8x = append(vp8x, uint24(height-1)...)
+	writeWebpChunk(&body, "VP8X", vp8x)
+
+	// Transparent background, the loop count follows
+	writeWebpChunk(&body, "ANIM", binary.LittleEndian.AppendUint16([]byte{0, 0, 0, 0}, uint16(anim.plays)))
+
+	for i, frame := range anim.frames {
+		still, err := convertImageFormat(frame, PictureFormatWebp, quality)
+		if err != nil {
+			return nil, err
+		}
+		bitstream, err := webpBitstream(still.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		// The frames are placed at the origin and replace the canvas
+		frameBounds := frame.Bounds()
+		header := []byte{0, 0, 0, 0, 0, 0}
+		header = append(header, uint24(frameBounds.Dx()-1)...)
+		header = append(header, uint24(frameBounds.Dy()-1)...)
+		header = append(header, uint24(min(max(anim.delays[i], 0), webpMaxFrameDelay))...)
+		header = append(header, webpFrameNoBlend)
+		writeWebpChunk(&body, "ANMF", append(header, bitstream...))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(body.Len()+4)))
+	buf.WriteString("WEBP")
+	buf.Write(body.Bytes())
+	return &buf, nil
+}
+
+// webpBitstream get the ALPH, VP8 and VP8L chunks of the still WebP, which form an animation frame
+func webpBitstream(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.Errorf("invalid webp image")
+	}
+
+	var bitstream []byte
+	for offset := 12; offset+8 <= len(data); {
+		fourCC := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		end := offset + 8 + size + size&1 // The chunks are padded to even sizes
+		if end > len(data) {
+			return nil, errors.Errorf("invalid webp chunk")
+		}
+		if fourCC == "ALPH" || fourCC == "VP8 " || fourCC == "VP8L" {
+			bitstream = append(bitstream, data[offset:end]...)
+		}
+		offset = end
+	}
+	return bitstream, nil
+}
+
+// writeWebpChunk write the RIFF chunk with its size, padded to an even size
+func writeWebpChunk(w *bytes.Buffer, fourCC string, payload []byte) {
+	w.WriteString(fourCC)
+	w.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(payload))))
+	w.Write(payload)
+	if len(payload)%2 == 1 {
+		w.WriteByte(0)
+	}
+}
+
+// uint24 encode the value as a 24-bit little endian integer
+func uint24(value int) []byte {
+	return []byte{byte(value), byte(value >> 8), byte(value >> 16)}
+}
@@ -0,0 +1,217 @@
+package image
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// ImageCacheStats contains the usage of the processed image cache
+type ImageCacheStats struct {
+	Files       int64 `json:"files"`       // The number of the cached images, counted by the last cleanup and updated since
+	Bytes       int64 `json:"bytes"`       // The total size of the cached images, counted by the last cleanup and updated since
+	MaxFiles    int64 `json:"maxFiles"`    // The maximum number of the cached images, negative if not limited
+	MaxBytes    int64 `json:"maxBytes"`    // The maximum total size of the cached images, negative if not limited
+	Hits        int64 `json:"hits"`        // The number of the requests served from the cache since the start
+	Misses      int64 `json:"misses"`      // The number of the requests processing the image since the start
+	Evictions   int64 `json:"evictions"`   // The number of the images evicted over the budget since the start
+	LastCleanup int64 `json:"lastCleanup"` // The time of the last cleanup, 0 if not run yet
+}
+
+var (
+	cacheAccess    sync.Map     // The last access time of the cached images since the start, the modified time is used otherwise
+	cacheFiles     atomic.Int64 // The number of the cached images
+	cacheBytes     atomic.Int64 // The total size of the cached images
+	cacheHits      atomic.Int64 // The number of the cache hits
+	cacheMisses    atomic.Int64 // The number of the cache misses
+	cacheEvictions atomic.Int64 // The number of the evicted images
+	lastCleanup    atomic.Int64 // The time of the last cleanup
+	cleanupLock    sync.Mutex   // Only one cleanup runs at a time
+)
+
+// saveEvictionTarget is the share of the budget the eviction triggered by a save goes down to, so the next images
+// do not trigger it again right away
+const saveEvictionTarget = 0.9
+
+// cachedImage is a processed image found by the cleanup
+type cachedImage struct {
+	path     string
+	size     int64
+	accessed int64
+}
+
+// getImageCachePath get the folder to store the processed images in, empty if they are stored next to the original files
+func getImageCachePath() string {
+	if config.GofletCfg.ImageCacheConfig.Path == "" {
+		return ""
+	}
+	return util.GetPath(config.GofletCfg.ImageCacheConfig.Path)
+}
+
+// imageCacheFolder get the folder of the processed images of the file, named after the folder of the file
+func imageCacheFolder(fsPath string) string {
+	cachePath := getImageCachePath()
+	if cachePath == "" {
+		return fsPath
+	}
+	name := filepath.Base(fsPath)
+	if len(name) < 4 {
+		return filepath.Join(cachePath, name)
+	}
+	return filepath.Join(cachePath, name[:2], name[2:4], name)
+}
+
+// imageCachePath get the path of the processed image of the file with the parameters
+func imageCachePath(fsPath string, params *ProcessParams) string {
+	return filepath.Join(imageCacheFolder(fsPath), model.ImageAppend+params.Dump())
+}
+
+// originalExists check if the file of the folder in the image cache path still exists in one of the storage roots
+func originalExists(folder string) bool {
+	name := filepath.Base(folder)
+	if len(name) < 4 {
+		return false
+	}
+	for _, root := range util.GetStorageRoots() {
+		if _, err := os.Stat(filepath.Join(root, name[:2], name[2:4], name, model.FileAppend)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// removeCachedImage remove the processed image and update the usage
+func removeCachedImage(path string, size int64) bool {
+	if err := os.Remove(path); err != nil {
+		return false
+	}
+	cacheFiles.Add(-1)
+	cacheBytes.Add(-size)
+	cacheAccess.Delete(path)
+
+	// The folders in the image cache path only contain the processed images
+	if getImageCachePath() != "" {
+		_ = os.Remove(filepath.Dir(path))
+	}
+	return true
+}
+
+// GetImageCacheStats get the usage of the processed image cache
+func GetImageCacheStats() ImageCacheStats {
+	conf := config.GofletCfg.ImageCacheConfig
+	return ImageCacheStats{
+		Files:       cacheFiles.Load(),
+		Bytes:       cacheBytes.Load(),
+		MaxFiles:    conf.MaxFiles,
+		MaxBytes:    conf.MaxBytes,
+		Hits:        cacheHits.Load(),
+		Misses:      cacheMisses.Load(),
+		Evictions:   cacheEvictions.Load(),
+		LastCleanup: lastCleanup.Load(),
+	}
+}
+
+// overBudget check if the cache has more images or bytes than the share of the budget
+func overBudget(share float64) bool {
+	conf := config.GofletCfg.ImageCacheConfig
+	overFiles := conf.MaxFiles > 0 && float64(cacheFiles.Load()) > float64(conf.MaxFiles)*share
+	overBytes := conf.MaxBytes > 0 && float64(cacheBytes.Load()) > float64(conf.MaxBytes)*share
+	return overFiles || overBytes
+}
+
+// evictOverBudget evict the least recently used images if the saved images take the cache over the budget,
+// nothing is done if a cleanup is running already
+func evictOverBudget() {
+	if !overBudget(1) || !cleanupLock.TryLock() {
+		return
+	}
+	defer cleanupLock.Unlock()
+	cleanImageCache(saveEvictionTarget)
+}
+
+// CleanImageCache count the processed images, remove the ones of the deleted files from the image cache path, and
+// evict the least recently used images until the cache is within the budget
+func CleanImageCache() ImageCacheStats {
+	cleanupLock.Lock()
+	defer cleanupLock.Unlock()
+	return cleanImageCache(1)
+}
+
+// cleanImageCache clean the image cache, the images are evicted until the cache is within the share of the budget
+func cleanImageCache(share float64) ImageCacheStats {
+	cachePath := getImageCachePath()
+	roots := util.GetStorageRoots()
+	if cachePath != "" {
+		roots = []string{cachePath}
+	}
+
+	var images []cachedImage
+	seen := map[string]bool{}
+	for _, root := range roots {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasPrefix(d.Name(), model.ImageAppend) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if cachePath != "" && !originalExists(filepath.Dir(path)) {
+				log.Debugf("Remove the processed image of the deleted file: %s", path)
+				_ = os.Remove(path)
+				_ = os.Remove(filepath.Dir(path))
+				return nil
+			}
+
+			accessed := info.ModTime().Unix()
+			if value, ok := cacheAccess.Load(path); ok {
+				accessed = max(accessed, value.(int64))
+			}
+			images = append(images, cachedImage{path: path, size: info.Size(), accessed: accessed})
+			seen[path] = true
+			return nil
+		})
+	}
+
+	// Forget the images removed with their files
+	cacheAccess.Range(func(key, _ interface{}) bool {
+		if !seen[key.(string)] {
+			cacheAccess.Delete(key)
+		}
+		return true
+	})
+
+	var files, bytes int64
+	for _, img := range images {
+		files++
+		bytes += img.size
+	}
+	cacheFiles.Store(files)
+	cacheBytes.Store(bytes)
+
+	// Evict the least recently used images first
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].accessed < images[j].accessed
+	})
+	for _, img := range images {
+		if !overBudget(share) {
+			break
+		}
+		if removeCachedImage(img.path, img.size) {
+			cacheEvictions.Add(1)
+		}
+	}
+
+	lastCleanup.Store(time.Now().Unix())
+	return GetImageCacheStats()
+}
@@ -0,0 +1,35 @@
+package image
+
+import (
+	"image"
+	"strings"
+
+	"github.com/gen2brain/heic"
+	_ "github.com/gen2brain/webp" // Register the webp decoder
+	_ "golang.org/x/image/bmp"    // Register the bmp decoder
+	_ "golang.org/x/image/tiff"   // Register the tiff decoder
+)
+
+// decodableMimeTypes the mime types of the images that can be decoded
+var decodableMimeTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/avif": true,
+	"image/bmp":  true,
+	"image/tiff": true,
+	"image/heic": true,
+	"image/heif": true,
+}
+
+func init() {
+	// The heic package only registers the "heic" brand, "heix" is used by 10-bit images
+	image.RegisterFormat("heic", "????ftypheix", heic.Decode, heic.DecodeConfig)
+}
+
+// IsDecodable whether the image with the given mime type can be decoded
+func IsDecodable(mimeType string) bool {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	return decodableMimeTypes[strings.TrimSpace(mimeType)]
+}
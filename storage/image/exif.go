@@ -0,0 +1,218 @@
+package image
+
+import (
+	"bufio"
+	"image"
+	"image/jpeg"
+	"io"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+	"github.com/rwcarlsen/goexif/exif"
+
+	"github.com/vvbbnn00/goflet/config"
+)
+
+const (
+	// strippedJpegQuality the quality of the JPEG re-encoded to apply the orientation
+	strippedJpegQuality = 95
+	// dateTakenLayout the layout of the date taken, in the local time of the camera
+	dateTakenLayout = "2006-01-02T15:04:05"
+)
+
+// ExifInfo contains the EXIF information of an image, the GPS location is never exposed
+type ExifInfo struct {
+	Make         string  `json:"make,omitempty"`         // The maker of the camera
+	Model        string  `json:"model,omitempty"`        // The model of the camera
+	LensModel    string  `json:"lensModel,omitempty"`    // The model of the lens
+	DateTaken    string  `json:"dateTaken,omitempty"`    // The time the photo was taken, in the local time of the camera
+	ExposureTime string  `json:"exposureTime,omitempty"` // The exposure time in seconds, like 1/125
+	FNumber      float64 `json:"fNumber,omitempty"`      // The f-number of the aperture
+	ISO          int     `json:"iso,omitempty"`          // The ISO speed
+	FocalLength  float64 `json:"focalLength,omitempty"`  // The focal length in millimeters
+	Orientation  int     `json:"orientation,omitempty"`  // The EXIF orientation, from 1 to 8
+}
+
+// readExif read the EXIF of the image from the start, nil if the image has none
+func readExif(r io.ReadSeeker) *exif.Exif {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil
+	}
+	x, err := exif.Decode(r)
+	if err != nil {
+		return nil
+	}
+	return x
+}
+
+// exifOrientation get the orientation of the EXIF, 1 if not set
+func exifOrientation(x *exif.Exif) int {
+	if x == nil {
+		return 1
+	}
+	tag, err := x.Get(exif.Orientation)
+	if err != nil {
+		return 1
+	}
+	orientation, err := tag.Int(0)
+	if err != nil || orientation < 1 || orientation > 8 {
+		return 1
+	}
+	return orientation
+}
+
+// applyOrientation transform the image so it is displayed upright for the EXIF orientation
+func applyOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	}
+	return img
+}
+
+// ReadExifInfo read the EXIF information of the image, nil if the image has none
+func ReadExifInfo(r io.ReadSeeker) *ExifInfo {
+	x := readExif(r)
+	if x == nil {
+		return nil
+	}
+
+	info := &ExifInfo{Orientation: exifOrientation(x)}
+	str := func(name exif.FieldName) string {
+		if tag, err := x.Get(name); err == nil {
+			value, _ := tag.StringVal()
+			return strings.TrimRight(value, "\x00 ")
+		}
+		return ""
+	}
+	rat := func(name exif.FieldName) float64 {
+		if tag, err := x.Get(name); err == nil {
+			if value, err := tag.Rat(0); err == nil {
+				f, _ := value.Float64()
+				return f
+			}
+		}
+		return 0
+	}
+
+	info.Make = str(exif.Make)
+	info.Model = str(exif.Model)
+	info.LensModel = str(exif.LensModel)
+	if taken, err := x.DateTime(); err == nil {
+		info.DateTaken = taken.Format(dateTakenLayout)
+	}
+	if tag, err := x.Get(exif.ExposureTime); err == nil {
+		if value, err := tag.Rat(0); err == nil {
+			info.ExposureTime = value.RatString()
+		}
+	}
+	info.FNumber = rat(exif.FNumber)
+	info.FocalLength = rat(exif.FocalLength)
+	if tag, err := x.Get(exif.ISOSpeedRatings); err == nil {
+		info.ISO, _ = tag.Int(0)
+	}
+	return info
+}
+
+// StripExif write the JPEG image without the EXIF, XMP and IPTC metadata, the image is re-encoded
+// only if its EXIF orientation has to be applied to the pixels, which is limited by the processing size
+func StripExif(r io.ReadSeeker, w io.Writer, conf *config.ImageConfig) error {
+	if orientation := exifOrientation(readExif(r)); orientation != 1 {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		imageConf, err := jpeg.DecodeConfig(r)
+		if err != nil {
+			return err
+		}
+		if imageConf.Width > conf.MaxWidth || imageConf.Height > conf.MaxHeight {
+			return errors.Errorf("image size is too large")
+		}
+
+		if _, err = r.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		img, err := jpeg.Decode(r)
+		if err != nil {
+			return err
+		}
+		return jpeg.Encode(w, applyOrientation(img, orientation), &jpeg.Options{Quality: strippedJpegQuality})
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return stripJpegSegments(bufio.NewReader(r), w)
+}
+
+// stripJpegSegments copy the JPEG without the APP1 (EXIF, XMP) and APP13 (IPTC) segments
+func stripJpegSegments(r *bufio.Reader, w io.Writer) error {
+	soi := make([]byte, 2)
+	if _, err := io.ReadFull(r, soi); err != nil || soi[0] != 0xFF || soi[1] != 0xD8 {
+		return errors.Errorf("not a jpeg image")
+	}
+	if _, err := w.Write(soi); err != nil {
+		return err
+	}
+
+	for {
+		marker := make([]byte, 2)
+		if _, err := io.ReadFull(r, marker); err != nil {
+			return err
+		}
+		// Skip the fill bytes
+		for marker[0] == 0xFF && marker[1] == 0xFF {
+			b, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			marker[1] = b
+		}
+		if marker[0] != 0xFF {
+			return errors.Errorf("invalid jpeg marker")
+		}
+
+		// The entropy coded data follows the start of scan, copy the rest as it is
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			if _, err := w.Write(marker); err != nil {
+				return err
+			}
+			_, err := io.Copy(w, r)
+			return err
+		}
+
+		length := make([]byte, 2)
+		if _, err := io.ReadFull(r, length); err != nil {
+			return err
+		}
+		size := int(length[0])<<8 | int(length[1])
+		if size < 2 {
+			return errors.Errorf("invalid jpeg segment")
+		}
+		if marker[1] == 0xE1 || marker[1] == 0xED {
+			if _, err := r.Discard(size - 2); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := w.Write(append(marker, length...)); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, r, int64(size-2)); err != nil {
+			return err
+		}
+	}
+}
@@ -5,58 +5,99 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
 
 	"github.com/vvbbnn00/goflet/storage"
 	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/util"
 	"github.com/vvbbnn00/goflet/util/log"
 )
 
-// GetFileImageInfo get the file info for the image
+// GetFileImageInfo get the file info for the image, with the metadata of the original file
 func GetFileImageInfo(fsPath string, params *ProcessParams) (model.FileInfo, error) {
-	fsPath = filepath.Join(fsPath, model.ImageAppend+params.Dump())
-	return storage.GetInfo(fsPath)
+	cachePath := imageCachePath(fsPath, params)
+	fi, err := os.Stat(cachePath)
+	if err != nil {
+		return model.FileInfo{}, err
+	}
+
+	return model.FileInfo{
+		FilePath:     cachePath,
+		FileSize:     fi.Size(),
+		LastModified: fi.ModTime().Unix(),
+		FileMeta:     storage.GetFileMeta(fsPath),
+	}, nil
 }
 
-// GetFileImageReader get the file reader for the image
+// GetFileImageReader get the file reader for the image, the access is recorded for the eviction
 func GetFileImageReader(fsPath string, params *ProcessParams) (*os.File, error) {
-	fsPath = filepath.Join(fsPath, model.ImageAppend+params.Dump())
+	cachePath := imageCachePath(fsPath, params)
 
-	file, err := os.OpenFile(fsPath, os.O_RDONLY, model.FilePerm)
+	file, err := os.OpenFile(cachePath, os.O_RDONLY, model.FilePerm)
 	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			cacheMisses.Add(1)
+		}
 		return nil, err
 	}
 
+	cacheHits.Add(1)
+	cacheAccess.Store(cachePath, time.Now().Unix())
 	return file, nil
 }
 
-// SaveFileImageCache save the file to the image cache
+// SaveFileImageCache save the file to the image cache, the least recently used images are evicted if the cache
+// gets over the budget
 func SaveFileImageCache(fsPath string, params *ProcessParams, buffer bytes.Buffer) error {
-	fsPath = filepath.Join(fsPath, model.ImageAppend+params.Dump())
+	cachePath := imageCachePath(fsPath, params)
+	size := int64(buffer.Len())
 
-	// Copy the file to the cache
-	cacheFile, err := os.OpenFile(fsPath, os.O_CREATE|os.O_RDWR, model.FilePerm)
+	// The folder of the original file always exists, the one in the image cache path may not
+	if err := os.MkdirAll(filepath.Dir(cachePath), os.ModePerm); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so the image is never read partially written
+	tmpPath := filepath.Join(filepath.Dir(cachePath), "tmp-image-"+util.RandomString(10))
+	cacheFile, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, model.FilePerm)
 	if err != nil {
-		_ = cacheFile.Close()
 		return err
 	}
+	defer func() {
+		_ = os.Remove(tmpPath)
+	}()
 
 	// Write the buffer to the file
 	_, err = io.Copy(cacheFile, &buffer)
+	if closeErr := cacheFile.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
-		_ = cacheFile.Close()
 		return err
 	}
 
-	// Close the file
-	_ = cacheFile.Close()
+	// Only count the image if it is not cached by a concurrent request meanwhile
+	err = os.Link(tmpPath, cachePath)
+	if errors.Is(err, os.ErrExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cacheFiles.Add(1)
+	cacheBytes.Add(size)
 
+	evictOverBudget()
 	return nil
 }
 
 // RemoveImageCache remove the image cache
 func RemoveImageCache(fsPath string) {
 	// Remove the file from the cache
-	pathPattern := filepath.Join(fsPath, model.ImageCachePrefixWithWildcard)
+	folder := imageCacheFolder(fsPath)
+	pathPattern := filepath.Join(folder, model.ImageCachePrefixWithWildcard)
 	files, err := filepath.Glob(pathPattern)
 
 	if err != nil {
@@ -65,9 +106,10 @@ func RemoveImageCache(fsPath string) {
 
 	// Remove the files
 	for _, file := range files {
-		err = os.Remove(file)
+		info, err := os.Stat(file)
 		if err != nil {
 			continue
 		}
+		removeCachedImage(file, info.Size())
 	}
 }
@@ -7,6 +7,8 @@ import (
 	"image/jpeg"
 	"image/png"
 
+	"github.com/gen2brain/avif"
+	"github.com/gen2brain/webp"
 	"github.com/pkg/errors"
 )
 
@@ -34,10 +36,21 @@ func convertImageFormat(img image.Image, format PictureFormat, quality int) (*by
 		if err := gif.Encode(&buf, img, nil); err != nil {
 			return nil, err
 		}
-	// case PictureFormatWebp:
-	//	if err := webp.Encode(&buf, img, &webp.Options{Lossless: false, Quality: float32(quality)}); err != nil {
-	//		return nil, err
-	//	}
+	case PictureFormatWebp:
+		options := webp.Options{Quality: quality, Lossless: quality == 100, Method: webp.DefaultMethod}
+		if err := webp.Encode(&buf, img, options); err != nil {
+			return nil, err
+		}
+	case PictureFormatAvif:
+		options := avif.Options{
+			Quality:           quality,
+			QualityAlpha:      quality,
+			Speed:             avif.DefaultSpeed,
+			ChromaSubsampling: image.YCbCrSubsampleRatio420,
+		}
+		if err := avif.Encode(&buf, img, options); err != nil {
+			return nil, err
+		}
 	default:
 		return nil, errors.Errorf("unsupported format: %s", format)
 	}
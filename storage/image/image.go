@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"image"
 	"image/color"
+	"io"
 	"os"
 
 	"github.com/disintegration/imaging"
@@ -12,30 +13,68 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/storage/model"
 	"github.com/vvbbnn00/goflet/util/log"
 )
 
-// ProcessImage process the image with the given parameters
-func ProcessImage(fs *os.File, p *ProcessParams) (*bytes.Buffer, error) {
-	conf := config.GofletCfg.ImageConfig
-	decoded, _, err := image.Decode(fs)
+// ProcessImage process the image with the given parameters and the image configuration
+func ProcessImage(fs *os.File, p *ProcessParams, conf *config.ImageConfig) (*bytes.Buffer, error) {
+	imageConf, format, err := image.DecodeConfig(fs)
+	if errors.Is(err, image.ErrFormat) {
+		return nil, errors.Errorf("unsupported image format")
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	width, height := decoded.Bounds().Dx(), decoded.Bounds().Dy()
+	// The size is checked as the photo is displayed
+	orientation := exifOrientation(readExif(fs))
+	width, height := imageConf.Width, imageConf.Height
+	if orientation >= 5 {
+		width, height = height, width
+	}
 	if width > conf.MaxWidth || height > conf.MaxHeight {
 		return nil, errors.Errorf("image size is too large")
 	}
 
-	// Resize the image
-	resized := resizeImage(decoded, p.Scale, p.Width, p.Height)
+	// Load the watermark once, it is drawn on every frame of the animations
+	var mark image.Image
+	if p.Watermark != "" {
+		mark, err = loadWatermark(conf.Watermarks[p.Watermark])
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	// Rotate the image
-	rotated := rotateImage(resized, p.Angle)
+	// Keep the animation if the output format supports it
+	if p.Format == PictureFormatGif || p.Format == PictureFormatWebp {
+		anim, err := decodeAnimation(fs, format, width, height, conf)
+		if err != nil {
+			return nil, err
+		}
+		if anim != nil {
+			return processAnimation(anim, p, conf, mark)
+		}
+	}
+
+	if _, err = fs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	decoded, _, err := image.Decode(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make the photo upright before the other transforms, the metadata is never kept in the derivatives
+	decoded = applyOrientation(decoded, orientation)
+
+	transformed, err := transformImage(decoded, p, conf, mark)
+	if err != nil {
+		return nil, err
+	}
 
 	// Change the format
-	buf, err := convertImageFormat(rotated, p.Format, p.Quality)
+	buf, err := convertImageFormat(transformed, p.Format, p.Quality)
 	if err != nil {
 		return nil, err
 	}
@@ -43,6 +82,39 @@ func ProcessImage(fs *os.File, p *ProcessParams) (*bytes.Buffer, error) {
 	return buf, nil
 }
 
+// transformImage crop, resize, apply the effects, rotate and draw the watermark on the upright image or frame
+func transformImage(img image.Image, p *ProcessParams, conf *config.ImageConfig, mark image.Image) (image.Image, error) {
+	// Crop the requested area
+	focal := p.Focal
+	if !p.Crop.Empty() {
+		area := p.Crop.Add(img.Bounds().Min).Intersect(img.Bounds())
+		if area.Empty() {
+			return nil, errors.Errorf("invalid crop area")
+		}
+		focal = relocateFocalPoint(focal, img.Bounds(), area)
+		img = imaging.Crop(img, area)
+	}
+
+	// Resize the image
+	resized := resizeImage(img, p.Scale, p.Width, p.Height)
+	if p.fillCrop() {
+		resized = cropImage(resized, p.Width, p.Height, p.Gravity, focal)
+	}
+
+	// Apply the effects on the resized image, which is cheaper
+	resized = applyEffects(resized, p)
+
+	// Rotate the image
+	rotated := rotateImage(resized, p.Angle)
+
+	// Draw the watermark at last, so it is not rotated
+	if mark != nil {
+		rotated = placeWatermark(rotated, mark, conf.Watermarks[p.Watermark])
+	}
+
+	return rotated, nil
+}
+
 // resizeImage resize the image with the given parameters
 func resizeImage(img image.Image, scaleType ScaleType, width, height int) image.Image {
 	imgWidth, imgHeight := img.Bounds().Dx(), img.Bounds().Dy()
@@ -92,6 +164,75 @@ func resizeImage(img image.Image, scaleType ScaleType, width, height int) image.
 	return resize.Resize(uint(width), uint(height), img, resize.Lanczos3)
 }
 
+// gravityAnchors the anchors of the gravities, the others keep the center
+var gravityAnchors = map[Gravity]imaging.Anchor{
+	GravityNorth:     imaging.Top,
+	GravitySouth:     imaging.Bottom,
+	GravityEast:      imaging.Right,
+	GravityWest:      imaging.Left,
+	GravityNorthEast: imaging.TopRight,
+	GravityNorthWest: imaging.TopLeft,
+	GravitySouthEast: imaging.BottomRight,
+	GravitySouthWest: imaging.BottomLeft,
+}
+
+// cropImage crop the image to the given size, keeping the focal point if given, otherwise the anchor of the gravity
+func cropImage(img image.Image, width, height int, gravity Gravity, focal *model.FocalPoint) image.Image {
+	bounds := img.Bounds()
+	width, height = min(width, bounds.Dx()), min(height, bounds.Dy())
+
+	if focal == nil {
+		anchor, ok := gravityAnchors[gravity]
+		if !ok {
+			anchor = imaging.Center
+		}
+		return imaging.CropAnchor(img, width, height, anchor)
+	}
+
+	// Center the focal point as much as possible
+	x := int(focal.X*float64(bounds.Dx())) - width/2
+	y := int(focal.Y*float64(bounds.Dy())) - height/2
+	x = max(0, min(x, bounds.Dx()-width))
+	y = max(0, min(y, bounds.Dy()-height))
+	return imaging.Crop(img, image.Rect(x, y, x+width, y+height).Add(bounds.Min))
+}
+
+// relocateFocalPoint convert the focal point of the bounds to the focal point of the area inside the bounds
+func relocateFocalPoint(focal *model.FocalPoint, bounds, area image.Rectangle) *model.FocalPoint {
+	if focal == nil {
+		return nil
+	}
+	x := (focal.X*float64(bounds.Dx()) - float64(area.Min.X-bounds.Min.X)) / float64(area.Dx())
+	y := (focal.Y*float64(bounds.Dy()) - float64(area.Min.Y-bounds.Min.Y)) / float64(area.Dy())
+	return &model.FocalPoint{X: max(0, min(x, 1)), Y: max(0, min(y, 1))}
+}
+
+// applyEffects apply the effects of the parameters to the image
+func applyEffects(img image.Image, p *ProcessParams) image.Image {
+	if p.Blur > 0 {
+		img = imaging.Blur(img, p.Blur)
+	}
+	if p.Sharpen > 0 {
+		img = imaging.Sharpen(img, p.Sharpen)
+	}
+	if p.Grayscale {
+		img = imaging.Grayscale(img)
+	}
+	if p.Brightness != 0 {
+		img = imaging.AdjustBrightness(img, float64(p.Brightness))
+	}
+	if p.Contrast != 0 {
+		img = imaging.AdjustContrast(img, float64(p.Contrast))
+	}
+	if p.FlipH {
+		img = imaging.FlipH(img)
+	}
+	if p.FlipV {
+		img = imaging.FlipV(img)
+	}
+	return img
+}
+
 // rotateImage rotate the image with the given angle
 func rotateImage(img image.Image, angle int) image.Image {
 	if angle%360 == 0 {
@@ -0,0 +1,602 @@
+package image
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gen2brain/webp"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/util"
+)
+
+// testConfig returns an image configuration for the tests
+func testConfig(allowed ...string) *config.ImageConfig {
+	strict := false
+	return &config.ImageConfig{
+		DefaultFormat:  "jpeg",
+		AllowedFormats: allowed,
+		StrictMode:     &strict,
+		MaxWidth:       4096,
+		MaxHeight:      4096,
+		MaxFrames:      300,
+		MaxTotalPixels: 100000000,
+		MaxBlur:        10,
+		MaxSharpen:     -1,
+	}
+}
+
+// testImage returns a small gradient image
+func testImage(width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 255 / width), G: uint8(y * 255 / height), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func TestFormatParams(t *testing.T) {
+	conf := testConfig("jpeg", "png", "webp")
+
+	params := GetProcessParamsFromQuery(url.Values{"f": {"webp"}}, "", conf)
+	assert.Equal(t, PictureFormatWebp, params.Format)
+	assert.False(t, params.Auto)
+
+	// Not in the allowed formats
+	params = GetProcessParamsFromQuery(url.Values{"f": {"avif"}}, "", conf)
+	assert.Equal(t, PictureFormatJpeg, params.Format)
+
+	// Negotiated from the Accept header, avif is not allowed
+	accept := "image/avif,image/webp,image/apng,*/*;q=0.8"
+	params = GetProcessParamsFromQuery(url.Values{"f": {"auto"}}, accept, conf)
+	assert.Equal(t, PictureFormatWebp, params.Format)
+	assert.True(t, params.Auto)
+
+	params = GetProcessParamsFromQuery(url.Values{"f": {"auto"}}, accept, testConfig())
+	assert.Equal(t, PictureFormatAvif, params.Format)
+
+	params = GetProcessParamsFromQuery(url.Values{"f": {"auto"}}, "image/webp;q=0, */*", conf)
+	assert.Equal(t, PictureFormatJpeg, params.Format)
+}
+
+func TestConvertImageFormat(t *testing.T) {
+	img := testImage(64, 48)
+	for _, format := range []PictureFormat{PictureFormatWebp, PictureFormatAvif} {
+		for _, quality := range []int{80, 100} {
+			buf, err := convertImageFormat(img, format, quality)
+			assert.NoError(t, err)
+
+			decoded, name, err := image.Decode(bytes.NewReader(buf.Bytes()))
+			assert.NoError(t, err)
+			assert.Equal(t, string(format), name)
+			assert.Equal(t, img.Bounds(), decoded.Bounds())
+		}
+	}
+}
+
+func TestDecodeFormats(t *testing.T) {
+	img := testImage(32, 24)
+	encoders := map[string]func(w io.Writer, m image.Image) error{
+		"bmp":  bmp.Encode,
+		"tiff": func(w io.Writer, m image.Image) error { return tiff.Encode(w, m, nil) },
+		"webp": func(w io.Writer, m image.Image) error { return webp.Encode(w, m) },
+	}
+
+	for name, encode := range encoders {
+		path := filepath.Join(t.TempDir(), "image."+name)
+		var buf bytes.Buffer
+		assert.NoError(t, encode(&buf, img))
+		assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+
+		file, err := os.Open(path)
+		assert.NoError(t, err)
+		params := &ProcessParams{Format: PictureFormatPng, Quality: 100}
+		out, err := ProcessImage(file, params, testConfig())
+		_ = file.Close()
+		assert.NoError(t, err, name)
+
+		decoded, err := png.Decode(out)
+		assert.NoError(t, err)
+		assert.Equal(t, img.Bounds(), decoded.Bounds())
+	}
+
+	// Not an image the decoders know
+	path := filepath.Join(t.TempDir(), "image.svg")
+	assert.NoError(t, os.WriteFile(path, []byte("<svg></svg>"), 0644))
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer func() {
+		_ = file.Close()
+	}()
+	_, err = ProcessImage(file, &ProcessParams{Format: PictureFormatPng}, testConfig())
+	assert.EqualError(t, err, "unsupported image format")
+
+	assert.True(t, IsDecodable("image/heic"))
+	assert.True(t, IsDecodable("image/tiff"))
+	assert.False(t, IsDecodable("image/svg+xml"))
+}
+
+// processTestImage process the test image with the parameters
+func processTestImage(t *testing.T, img image.Image, params *ProcessParams) (image.Image, error) {
+	path := filepath.Join(t.TempDir(), "image.png")
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer func() {
+		_ = file.Close()
+	}()
+
+	params.Format = PictureFormatPng
+	params.Quality = 100
+	out, err := ProcessImage(file, params, testConfig())
+	if err != nil {
+		return nil, err
+	}
+	return png.Decode(out)
+}
+
+func TestCropParams(t *testing.T) {
+	conf := testConfig()
+	query := url.Values{"c": {"10,20,30,40"}, "g": {"north"}, "s": {"fill"}, "w": {"16"}, "h": {"16"}}
+	params := GetProcessParamsFromQuery(query, "", conf)
+	assert.Equal(t, image.Rect(10, 20, 40, 60), params.Crop)
+	assert.Equal(t, GravityNorth, params.Gravity)
+	assert.Contains(t, params.Dump(), "c10_20_30_40gnorth")
+
+	// The focal point is only used without an explicit compass gravity
+	params.SetFocalPoint(&model.FocalPoint{X: 0.5, Y: 0.25})
+	assert.Nil(t, params.Focal)
+
+	params = GetProcessParamsFromQuery(url.Values{"c": {"1,2,3"}, "g": {"up"}}, "", conf)
+	assert.True(t, params.Crop.Empty())
+	assert.Equal(t, Gravity(""), params.Gravity)
+	params.SetFocalPoint(&model.FocalPoint{X: 0.5, Y: 0.25})
+	assert.Nil(t, params.Focal)
+
+	params = GetProcessParamsFromQuery(url.Values{"s": {"fill"}, "w": {"16"}, "h": {"16"}}, "", conf)
+	params.SetFocalPoint(&model.FocalPoint{X: 0.5, Y: 0.25})
+	assert.NotNil(t, params.Focal)
+	assert.Contains(t, params.Dump(), "fp0.500_0.250")
+}
+
+func TestCropImage(t *testing.T) {
+	img := testImage(80, 40)
+
+	// Explicit crop area
+	out, err := processTestImage(t, img, &ProcessParams{Crop: image.Rect(10, 10, 30, 20)})
+	assert.NoError(t, err)
+	assert.Equal(t, 20, out.Bounds().Dx())
+	assert.Equal(t, 10, out.Bounds().Dy())
+
+	_, err = processTestImage(t, img, &ProcessParams{Crop: image.Rect(100, 100, 120, 120)})
+	assert.EqualError(t, err, "invalid crop area")
+
+	// The red channel grows from the west to the east
+	red := func(img image.Image) uint32 {
+		r, _, _, _ := img.At(img.Bounds().Min.X+img.Bounds().Dx()/2, img.Bounds().Min.Y).RGBA()
+		return r >> 8
+	}
+	west, err := processTestImage(t, img, &ProcessParams{Scale: ScaleTypeFill, Width: 20, Height: 20, Gravity: GravityWest})
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 20, 20), west.Bounds())
+	east, err := processTestImage(t, img, &ProcessParams{Scale: ScaleTypeFill, Width: 20, Height: 20, Gravity: GravityEast})
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 20, 20), east.Bounds())
+	assert.Less(t, red(west), uint32(80))
+	assert.Greater(t, red(east), uint32(170))
+
+	focal, err := processTestImage(t, img, &ProcessParams{Scale: ScaleTypeFill, Width: 20, Height: 20, Focal: &model.FocalPoint{X: 1, Y: 0.5}})
+	assert.NoError(t, err)
+	assert.Equal(t, red(east), red(focal))
+}
+
+func TestEffectParams(t *testing.T) {
+	query := url.Values{
+		"blur": {"42"}, "sharpen": {"2"}, "grayscale": {"1"}, "brightness": {"-33"},
+		"contrast": {"250"}, "flipH": {"true"}, "flipV": {"no"},
+	}
+	params := GetProcessParamsFromQuery(query, "", testConfig())
+	assert.Equal(t, 10.0, params.Blur) // Limited by the configuration
+	assert.Equal(t, 0.0, params.Sharpen)
+	assert.True(t, params.Grayscale)
+	assert.Equal(t, -30, params.Brightness)
+	assert.Equal(t, 100, params.Contrast)
+	assert.True(t, params.FlipH)
+	assert.False(t, params.FlipV)
+	assert.Contains(t, params.Dump(), "bl10gsbr-30ct100fh")
+
+	params = GetProcessParamsFromQuery(url.Values{"blur": {"1.3"}}, "", testConfig())
+	assert.Equal(t, 1.5, params.Blur)
+	assert.NotEqual(t, params.Dump(), GetProcessParamsFromQuery(url.Values{}, "", testConfig()).Dump())
+}
+
+func TestEffects(t *testing.T) {
+	img := testImage(40, 20)
+
+	gray, err := processTestImage(t, img, &ProcessParams{Grayscale: true, Blur: 2})
+	assert.NoError(t, err)
+	r, g, b, _ := gray.At(10, 10).RGBA()
+	assert.Equal(t, r, g)
+	assert.Equal(t, g, b)
+
+	// The red channel grows from the west to the east
+	flipped, err := processTestImage(t, img, &ProcessParams{FlipH: true})
+	assert.NoError(t, err)
+	r1, _, _, _ := img.At(0, 0).RGBA()
+	r2, _, _, _ := flipped.At(39, 0).RGBA()
+	assert.Equal(t, r1, r2)
+
+	darker, err := processTestImage(t, img, &ProcessParams{Brightness: -50})
+	assert.NoError(t, err)
+	_, g1, _, _ := img.At(20, 10).RGBA()
+	_, g2, _, _ := darker.At(20, 10).RGBA()
+	assert.Less(t, g2, g1)
+}
+
+func TestWatermark(t *testing.T) {
+	// Store a red watermark image
+	logoPath := "/watermark/" + util.RandomString(8) + ".png"
+	fsPath, _ := util.RelativeToFsPath(logoPath)
+	assert.NoError(t, os.MkdirAll(fsPath, os.ModePerm))
+	logo := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			logo.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, logo))
+	assert.NoError(t, os.WriteFile(filepath.Join(fsPath, model.FileAppend), buf.Bytes(), 0600))
+	defer func() {
+		_ = os.RemoveAll(fsPath)
+	}()
+
+	conf := testConfig()
+	conf.Watermarks = map[string]config.WatermarkConfig{
+		"logo": {Image: logoPath, Position: "northwest", Opacity: 1, Margin: 5},
+		"text": {Text: "PREVIEW", FontSize: 12, Color: "#000000", Tile: true},
+	}
+
+	params := GetProcessParamsFromQuery(url.Values{"wm": {"unknown"}}, "", conf)
+	assert.Equal(t, "", params.Watermark)
+	params = GetProcessParamsFromQuery(url.Values{"wm": {"logo"}}, "", conf)
+	assert.Equal(t, "logo", params.Watermark)
+	dump := params.Dump()
+	assert.Contains(t, dump, "wmlogo_")
+
+	// A changed preset gets other derivatives
+	conf.Watermarks["logo"] = config.WatermarkConfig{Image: logoPath, Position: "northwest", Opacity: 1, Margin: 6}
+	params.SetWatermark("logo", conf)
+	assert.NotEqual(t, dump, params.Dump())
+	conf.Watermarks["logo"] = config.WatermarkConfig{Image: logoPath, Position: "northwest", Opacity: 1, Margin: 5}
+
+	img := testImage(40, 20)
+	mark, err := loadWatermark(conf.Watermarks["logo"])
+	assert.NoError(t, err)
+	out := placeWatermark(img, mark, conf.Watermarks["logo"])
+	r, g, _, _ := out.At(7, 7).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+	assert.Equal(t, uint32(0), g)
+	assert.Equal(t, img.At(30, 15), out.At(30, 15)) // Untouched outside the watermark
+
+	mark, err = loadWatermark(conf.Watermarks["text"])
+	assert.NoError(t, err)
+	text := placeWatermark(img, mark, conf.Watermarks["text"])
+	assert.Equal(t, img.Bounds(), text.Bounds())
+	changed := false
+	for x := 0; x < 40 && !changed; x++ {
+		for y := 0; y < 20 && !changed; y++ {
+			changed = img.At(x, y) != text.At(x, y)
+		}
+	}
+	assert.True(t, changed)
+
+	_, err = loadWatermark(config.WatermarkConfig{Image: "/watermark/missing.png"})
+	assert.Error(t, err)
+}
+
+func TestPresets(t *testing.T) {
+	conf := testConfig()
+	strict := true
+	conf.StrictMode = &strict
+	conf.AllowedSizes = []int{64}
+	assert.NoError(t, json.Unmarshal([]byte(`{"thumb":{"w":100,"h":100,"s":"fill","f":"webp","q":80,"grayscale":true}}`), &conf.Presets))
+	assert.Equal(t, "100", conf.Presets["thumb"]["w"])
+	assert.Equal(t, "true", conf.Presets["thumb"]["grayscale"])
+
+	params, ok := GetProcessParamsFromPreset("thumb", "", conf)
+	assert.True(t, ok)
+	assert.Equal(t, 100, params.Width) // Not limited by the strict mode
+	assert.Equal(t, ScaleTypeFill, params.Scale)
+	assert.Equal(t, PictureFormatWebp, params.Format)
+	assert.Equal(t, 80, params.Quality)
+	assert.True(t, params.Grayscale)
+
+	// The same derivative as the query
+	query := url.Values{"w": {"100"}, "h": {"100"}, "s": {"fill"}, "f": {"webp"}, "q": {"80"}, "grayscale": {"1"}}
+	strict = false
+	assert.Equal(t, GetProcessParamsFromQuery(query, "", conf).Dump(), params.Dump())
+
+	_, ok = GetProcessParamsFromPreset("missing", "", conf)
+	assert.False(t, ok)
+}
+
+// jpegWithExif returns the JPEG of the image with an EXIF segment of the orientation and the maker
+func jpegWithExif(t *testing.T, img image.Image, orientation uint16) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}))
+
+	// Little endian TIFF with the Make and Orientation entries in IFD0, the maker follows the IFD
+	tiffData := []byte{'I', 'I', 42, 0, 8, 0, 0, 0, 2, 0}
+	tiffData = append(tiffData, 0x0F, 0x01, 2, 0, 7, 0, 0, 0, 38, 0, 0, 0)
+	tiffData = append(tiffData, 0x12, 0x01, 3, 0, 1, 0, 0, 0, byte(orientation), 0, 0, 0)
+	tiffData = append(tiffData, 0, 0, 0, 0)
+	tiffData = append(tiffData, []byte("Goflet\x00")...)
+	payload := append([]byte("Exif\x00\x00"), tiffData...)
+	segment := append([]byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}, payload...)
+
+	data := buf.Bytes()
+	return append(append([]byte{0xFF, 0xD8}, segment...), data[2:]...)
+}
+
+func TestExifOrientation(t *testing.T) {
+	img := testImage(40, 20)
+	data := jpegWithExif(t, img, 6)
+
+	info := ReadExifInfo(bytes.NewReader(data))
+	assert.NotNil(t, info)
+	assert.Equal(t, "Goflet", info.Make)
+	assert.Equal(t, 6, info.Orientation)
+	meta, err := AnalyzeImage(bytes.NewReader(data), testConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, []int{20, 40}, []int{meta.Width, meta.Height})
+
+	// The derivatives are upright and have no metadata
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	assert.NoError(t, os.WriteFile(path, data, 0644))
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer func() {
+		_ = file.Close()
+	}()
+	out, err := ProcessImage(file, &ProcessParams{Format: PictureFormatJpeg, Quality: 90}, testConfig())
+	assert.NoError(t, err)
+	assert.NotContains(t, out.String(), "Exif")
+	decoded, err := jpeg.Decode(out)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 20, 40), decoded.Bounds())
+
+	// Stripping applies the orientation
+	var stripped bytes.Buffer
+	assert.NoError(t, StripExif(bytes.NewReader(data), &stripped, testConfig()))
+	assert.NotContains(t, stripped.String(), "Exif")
+	decoded, err = jpeg.Decode(&stripped)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 20, 40), decoded.Bounds())
+
+	// The photo is not decoded beyond the processing size
+	small := testConfig()
+	small.MaxWidth = 10
+	err = StripExif(bytes.NewReader(data), &bytes.Buffer{}, small)
+	assert.EqualError(t, err, "image size is too large")
+
+	// Stripping an upright photo keeps the compressed data
+	var original bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&original, img, &jpeg.Options{Quality: 90}))
+	stripped.Reset()
+	assert.NoError(t, StripExif(bytes.NewReader(jpegWithExif(t, img, 1)), &stripped, small))
+	assert.Equal(t, original.Bytes(), stripped.Bytes())
+
+	assert.Nil(t, ReadExifInfo(bytes.NewReader(original.Bytes())))
+}
+
+func TestAnalyzeImage(t *testing.T) {
+	// Two colors, the red one covers three quarters
+	img := image.NewNRGBA(image.Rect(0, 0, 80, 40))
+	for x := 0; x < 80; x++ {
+		for y := 0; y < 40; y++ {
+			c := color.NRGBA{R: 255, A: 255}
+			if x >= 60 {
+				c = color.NRGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+
+	meta, err := AnalyzeImage(bytes.NewReader(buf.Bytes()), testConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, 80, meta.Width)
+	assert.Equal(t, 40, meta.Height)
+	assert.Equal(t, "png", meta.Format)
+	assert.Equal(t, 1, meta.Frames)
+	assert.Equal(t, []string{"#ff0000", "#0000ff"}, meta.Palette)
+	assert.NotEmpty(t, meta.BlurHash)
+
+	// The size is as displayed
+	meta, err = AnalyzeImage(bytes.NewReader(jpegWithExif(t, testImage(40, 20), 6)), testConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, []int{20, 40}, []int{meta.Width, meta.Height})
+
+	// The frames are counted
+	animation := &gif.GIF{}
+	for i := 0; i < 3; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, 16, 16), []color.Color{color.White, color.Black})
+		animation.Image = append(animation.Image, frame)
+		animation.Delay = append(animation.Delay, 10)
+	}
+	buf.Reset()
+	assert.NoError(t, gif.EncodeAll(&buf, animation))
+	meta, err = AnalyzeImage(bytes.NewReader(buf.Bytes()), testConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, "gif", meta.Format)
+	assert.Equal(t, 3, meta.Frames)
+
+	// The colors and the BlurHash are skipped beyond the processing limit
+	conf := testConfig()
+	conf.MaxWidth = 10
+	meta, err = AnalyzeImage(bytes.NewReader(buf.Bytes()), conf)
+	assert.NoError(t, err)
+	assert.Equal(t, 16, meta.Width)
+	assert.Empty(t, meta.Palette)
+	assert.Empty(t, meta.BlurHash)
+
+	_, err = AnalyzeImage(bytes.NewReader([]byte("not an image")), testConfig())
+	assert.EqualError(t, err, "unsupported image format")
+}
+
+// testAnimation returns a GIF of three frames in red, green and blue, played three times
+func testAnimation(t *testing.T) string {
+	animation := &gif.GIF{LoopCount: 2}
+	colors := []color.Color{color.RGBA{R: 255, A: 255}, color.RGBA{G: 255, A: 255}, color.RGBA{B: 255, A: 255}}
+	for i, c := range colors {
+		frame := image.NewPaletted(image.Rect(0, 0, 32, 32), colors)
+		draw.Draw(frame, frame.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+		animation.Image = append(animation.Image, frame)
+		animation.Delay = append(animation.Delay, (i+1)*10)
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, gif.EncodeAll(&buf, animation))
+	path := filepath.Join(t.TempDir(), "animation.gif")
+	assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+	return path
+}
+
+func TestAnimation(t *testing.T) {
+	path := testAnimation(t)
+	process := func(format PictureFormat, conf *config.ImageConfig) *bytes.Buffer {
+		file, err := os.Open(path)
+		assert.NoError(t, err)
+		defer func() {
+			_ = file.Close()
+		}()
+		out, err := ProcessImage(file, &ProcessParams{Width: 16, Scale: ScaleTypeFitWidth, Format: format, Quality: 90}, conf)
+		assert.NoError(t, err)
+		return out
+	}
+
+	out, err := gif.DecodeAll(process(PictureFormatGif, testConfig()))
+	assert.NoError(t, err)
+	assert.Len(t, out.Image, 3)
+	assert.Equal(t, []int{10, 20, 30}, out.Delay)
+	assert.Equal(t, 2, out.LoopCount)
+	assert.Equal(t, image.Rect(0, 0, 16, 16), out.Image[1].Bounds())
+	r, g, b, _ := out.Image[1].At(8, 8).RGBA()
+	assert.Equal(t, []uint32{0, 0xffff, 0}, []uint32{r, g, b})
+
+	data := process(PictureFormatWebp, testConfig()).Bytes()
+	frames, loops := readWebpAnimation(bytes.NewReader(data))
+	assert.Equal(t, 3, frames)
+	assert.Equal(t, 3, loops) // Played three times
+	decoded, err := decodeAnimation(bytes.NewReader(data), "webp", 16, 16, testConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, decoded.plays)
+	anim, err := webp.DecodeAll(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Len(t, anim.Image, 3)
+	assert.Equal(t, []int{100, 200, 300}, anim.Delay)
+	assert.Equal(t, image.Rect(0, 0, 16, 16), anim.Image[2].Bounds())
+	r, g, b, _ = anim.Image[2].At(8, 8).RGBA()
+	assert.Less(t, r, uint32(0x2000))
+	assert.Less(t, g, uint32(0x2000))
+	assert.Greater(t, b, uint32(0xe000))
+
+	// The formats without animation, and the animations beyond the limits keep the first frame
+	_, err = png.Decode(process(PictureFormatPng, testConfig()))
+	assert.NoError(t, err)
+	conf := testConfig()
+	conf.MaxFrames = 2
+	out, err = gif.DecodeAll(process(PictureFormatGif, conf))
+	assert.NoError(t, err)
+	assert.Len(t, out.Image, 1)
+}
+
+func TestImageCache(t *testing.T) {
+	cacheConfig := config.GofletCfg.ImageCacheConfig
+	config.GofletCfg.ImageCacheConfig.Path = t.TempDir()
+	config.GofletCfg.ImageCacheConfig.MaxFiles = -1
+	config.GofletCfg.ImageCacheConfig.MaxBytes = -1
+	defer func() {
+		config.GofletCfg.ImageCacheConfig = cacheConfig
+	}()
+
+	fsPath, _ := util.RelativeToFsPath("/cache/" + util.RandomString(8) + ".png")
+	assert.NoError(t, os.MkdirAll(fsPath, os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(fsPath, model.FileAppend), []byte("original"), 0600))
+	defer func() {
+		_ = os.RemoveAll(fsPath)
+	}()
+
+	// Three images cached an hour apart, the oldest is read again
+	var params []*ProcessParams
+	for i := 1; i <= 3; i++ {
+		p := &ProcessParams{Width: i, Format: PictureFormatPng}
+		assert.NoError(t, SaveFileImageCache(fsPath, p, *bytes.NewBufferString("image")))
+		cachePath := imageCachePath(fsPath, p)
+		assert.True(t, strings.HasPrefix(cachePath, config.GofletCfg.ImageCacheConfig.Path))
+		modified := time.Now().Add(time.Duration(i-4) * time.Hour)
+		assert.NoError(t, os.Chtimes(cachePath, modified, modified))
+		params = append(params, p)
+	}
+	reader, err := GetFileImageReader(fsPath, params[0])
+	assert.NoError(t, err)
+	_ = reader.Close()
+
+	// The image cached again by a concurrent request is counted once
+	files := GetImageCacheStats().Files
+	assert.NoError(t, SaveFileImageCache(fsPath, params[2], *bytes.NewBufferString("image")))
+	assert.Equal(t, files, GetImageCacheStats().Files)
+
+	// The images of the deleted files are removed
+	orphan := filepath.Join(config.GofletCfg.ImageCacheConfig.Path, "ff", "ff", "ffff", model.ImageAppend+"w1")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(orphan), os.ModePerm))
+	assert.NoError(t, os.WriteFile(orphan, []byte("image"), 0600))
+
+	config.GofletCfg.ImageCacheConfig.MaxFiles = 2
+	stats := CleanImageCache()
+	assert.Equal(t, int64(2), stats.Files)
+	assert.Equal(t, int64(10), stats.Bytes)
+	assert.GreaterOrEqual(t, stats.Evictions, int64(1))
+	assert.NoFileExists(t, orphan)
+	assert.FileExists(t, imageCachePath(fsPath, params[0]))
+	assert.NoFileExists(t, imageCachePath(fsPath, params[1]))
+	assert.FileExists(t, imageCachePath(fsPath, params[2]))
+
+	// The saved image taking the cache over the budget evicts the least recently used ones
+	for i, p := range []*ProcessParams{params[0], params[2]} {
+		modified := time.Now().Add(time.Duration(i-2) * time.Hour)
+		assert.NoError(t, os.Chtimes(imageCachePath(fsPath, p), modified, modified))
+		cacheAccess.Delete(imageCachePath(fsPath, p))
+	}
+	latest := &ProcessParams{Width: 4, Format: PictureFormatPng}
+	assert.NoError(t, SaveFileImageCache(fsPath, latest, *bytes.NewBufferString("image")))
+	assert.Equal(t, int64(1), GetImageCacheStats().Files)
+	assert.FileExists(t, imageCachePath(fsPath, latest))
+
+	RemoveImageCache(fsPath)
+	assert.Equal(t, int64(0), GetImageCacheStats().Files)
+	assert.NoDirExists(t, imageCacheFolder(fsPath))
+}
@@ -1,10 +1,15 @@
 package image
 
 import (
+	"fmt"
+	"image"
+	"math"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/storage/model"
 	"github.com/vvbbnn00/goflet/util/log"
 )
 
@@ -14,6 +19,9 @@ type ScaleType int
 // PictureFormat the format of the picture
 type PictureFormat string
 
+// Gravity the anchor of the fill crop
+type Gravity string
+
 const (
 	// ScaleTypeFit The fit scale type
 	ScaleTypeFit ScaleType = iota
@@ -34,9 +42,39 @@ const (
 	PictureFormatPng PictureFormat = "png"
 	// PictureFormatGif The gif picture format
 	PictureFormatGif PictureFormat = "gif"
-	// PictureFormatWebp PictureFormat = "webp"
+	// PictureFormatWebp The webp picture format, lossless when the quality is 100
+	PictureFormatWebp PictureFormat = "webp"
+	// PictureFormatAvif The avif picture format, lossless when the quality is 100
+	PictureFormatAvif PictureFormat = "avif"
 )
 
+const (
+	// GravityCenter Keep the center of the image
+	GravityCenter Gravity = "center"
+	// GravityNorth Keep the top edge of the image
+	GravityNorth Gravity = "north"
+	// GravitySouth Keep the bottom edge of the image
+	GravitySouth Gravity = "south"
+	// GravityEast Keep the right edge of the image
+	GravityEast Gravity = "east"
+	// GravityWest Keep the left edge of the image
+	GravityWest Gravity = "west"
+	// GravityNorthEast Keep the top right corner of the image
+	GravityNorthEast Gravity = "northeast"
+	// GravityNorthWest Keep the top left corner of the image
+	GravityNorthWest Gravity = "northwest"
+	// GravitySouthEast Keep the bottom right corner of the image
+	GravitySouthEast Gravity = "southeast"
+	// GravitySouthWest Keep the bottom left corner of the image
+	GravitySouthWest Gravity = "southwest"
+	// GravityFace Keep the subject of the image, which is marked by the focal point of the file,
+	// the center is kept if the file has no focal point
+	GravityFace Gravity = "face"
+)
+
+// negotiableFormats the formats that can be chosen by f=auto, ordered by preference
+var negotiableFormats = []PictureFormat{PictureFormatAvif, PictureFormatWebp}
+
 // ProcessParams the parameters for processing the image
 type ProcessParams struct {
 	Width   int           // The width of the image
@@ -45,6 +83,22 @@ type ProcessParams struct {
 	Quality int           // The quality of the image
 	Angle   int           // The angle of the image
 	Format  PictureFormat // The format of the image
+	Auto    bool          // Whether the format is negotiated from the Accept header
+
+	Crop    image.Rectangle   // The area of the source image to keep, empty if not cropped
+	Gravity Gravity           // The anchor of the fill crop, empty if not set
+	Focal   *model.FocalPoint // The focal point used by the fill crop, nil if not used
+
+	Blur       float64 // The sigma of the gaussian blur, 0 if not blurred
+	Sharpen    float64 // The sigma of the sharpening, 0 if not sharpened
+	Grayscale  bool    // Whether the image is converted to grayscale
+	Brightness int     // The brightness change in percent, from -100 to 100
+	Contrast   int     // The contrast change in percent, from -100 to 100
+	FlipH      bool    // Whether the image is flipped horizontally
+	FlipV      bool    // Whether the image is flipped vertically
+
+	Watermark        string // The watermark preset drawn on the image, empty if none
+	watermarkVersion string // The version of the watermark preset configuration
 }
 
 // Print the parameters
@@ -55,9 +109,60 @@ func (i *ProcessParams) Print() {
 
 // Dump the parameters
 func (i *ProcessParams) Dump() string {
-	return "w" + strconv.Itoa(i.Width) + "h" + strconv.Itoa(i.Height) + "s" +
+	dump := "w" + strconv.Itoa(i.Width) + "h" + strconv.Itoa(i.Height) + "s" +
 		strconv.Itoa(int(i.Scale)) + "q" + strconv.Itoa(i.Quality) + "a" +
 		strconv.Itoa(i.Angle) + "f" + string(i.Format)
+	if !i.Crop.Empty() {
+		dump += fmt.Sprintf("c%d_%d_%d_%d", i.Crop.Min.X, i.Crop.Min.Y, i.Crop.Dx(), i.Crop.Dy())
+	}
+	if i.Gravity != "" {
+		dump += "g" + string(i.Gravity)
+	}
+	if i.Focal != nil {
+		dump += fmt.Sprintf("fp%.3f_%.3f", i.Focal.X, i.Focal.Y)
+	}
+	if i.Blur > 0 {
+		dump += "bl" + strconv.FormatFloat(i.Blur, 'f', -1, 64)
+	}
+	if i.Sharpen > 0 {
+		dump += "sh" + strconv.FormatFloat(i.Sharpen, 'f', -1, 64)
+	}
+	if i.Grayscale {
+		dump += "gs"
+	}
+	if i.Brightness != 0 {
+		dump += "br" + strconv.Itoa(i.Brightness)
+	}
+	if i.Contrast != 0 {
+		dump += "ct" + strconv.Itoa(i.Contrast)
+	}
+	if i.FlipH {
+		dump += "fh"
+	}
+	if i.FlipV {
+		dump += "fv"
+	}
+	if i.Watermark != "" {
+		dump += "wm" + i.Watermark + "_" + i.watermarkVersion
+	}
+	return dump
+}
+
+// fillCrop whether the image is cropped to the exact size after the fill scale
+func (i *ProcessParams) fillCrop() bool {
+	return i.Scale == ScaleTypeFill && i.Width > 0 && i.Height > 0 && (i.Gravity != "" || i.Focal != nil)
+}
+
+// SetFocalPoint set the focal point of the file, it is only kept when the fill crop uses it
+func (i *ProcessParams) SetFocalPoint(focalPoint *model.FocalPoint) {
+	i.Focal = nil
+	if focalPoint == nil || (i.Gravity != "" && i.Gravity != GravityFace) {
+		return
+	}
+	i.Focal = focalPoint
+	if !i.fillCrop() {
+		i.Focal = nil
+	}
 }
 
 // whether the int in the array
@@ -70,15 +175,144 @@ func in(num int, arr []int) bool {
 	return false
 }
 
-// GetProcessParamsFromQuery get the image process parameters from the query
-func GetProcessParamsFromQuery(query url.Values) *ProcessParams {
-	conf := config.GofletCfg.ImageConfig
+// isFormatAllowed whether the format is allowed by the image configuration, all formats are allowed if the list is empty
+func isFormatAllowed(format PictureFormat, conf *config.ImageConfig) bool {
+	if len(conf.AllowedFormats) == 0 || format == PictureFormat(conf.DefaultFormat) {
+		return true
+	}
+	for _, v := range conf.AllowedFormats {
+		if PictureFormat(v) == format {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFormat parse the format from the query value, return an empty format if unknown
+func parseFormat(format string) PictureFormat {
+	switch format {
+	case "jpeg":
+		return PictureFormatJpeg
+	case "png":
+		return PictureFormatPng
+	case "gif":
+		return PictureFormatGif
+	case "webp":
+		return PictureFormatWebp
+	case "avif":
+		return PictureFormatAvif
+	}
+	return ""
+}
+
+// accepts whether the Accept header accepts the given mime type with a non-zero quality
+func accepts(accept string, mime string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		if strings.TrimSpace(fields[0]) != mime {
+			continue
+		}
+		for _, field := range fields[1:] {
+			field = strings.TrimSpace(field)
+			if strings.HasPrefix(field, "q=") {
+				q, err := strconv.ParseFloat(field[2:], 64)
+				if err != nil || q <= 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// NegotiateFormat choose the best allowed format from the Accept header, fall back to the default format
+func NegotiateFormat(accept string, conf *config.ImageConfig) PictureFormat {
+	for _, format := range negotiableFormats {
+		if isFormatAllowed(format, conf) && accepts(accept, "image/"+string(format)) {
+			return format
+		}
+	}
+	return PictureFormat(conf.DefaultFormat)
+}
 
+// parseCrop parse the crop area from the query value like x,y,w,h, return an empty area if invalid
+func parseCrop(crop string) image.Rectangle {
+	parts := strings.Split(crop, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}
+	}
+	values := make([]int, 4)
+	for i, part := range parts {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || value < 0 {
+			return image.Rectangle{}
+		}
+		values[i] = value
+	}
+	return image.Rect(values[0], values[1], values[0]+values[2], values[1]+values[3])
+}
+
+// parseGravity parse the gravity from the query value, return an empty gravity if unknown
+func parseGravity(gravity string) Gravity {
+	switch g := Gravity(gravity); g {
+	case GravityCenter, GravityNorth, GravitySouth, GravityEast, GravityWest,
+		GravityNorthEast, GravityNorthWest, GravitySouthEast, GravitySouthWest, GravityFace:
+		return g
+	}
+	return ""
+}
+
+// parseSigma parse the sigma of an effect, rounded to 0.5 and limited to the maximum, 0 if invalid or disabled
+func parseSigma(sigma string, maximum float64) float64 {
+	value, err := strconv.ParseFloat(sigma, 64)
+	if err != nil || value <= 0 || maximum <= 0 {
+		return 0
+	}
+	return min(math.Round(value*2)/2, maximum)
+}
+
+// parsePercent parse the percent of an adjustment, rounded to 5 and limited to -100 to 100, 0 if invalid
+func parsePercent(percent string) int {
+	value, err := strconv.Atoi(percent)
+	if err != nil {
+		return 0
+	}
+	return max(-100, min(value, 100)) / 5 * 5
+}
+
+// parseFlag parse the boolean flag, false if invalid
+func parseFlag(flag string) bool {
+	value, _ := strconv.ParseBool(flag)
+	return value
+}
+
+// GetProcessParamsFromQuery get the image process parameters from the query and the Accept header with the image configuration
+func GetProcessParamsFromQuery(query url.Values, accept string, conf *config.ImageConfig) *ProcessParams {
+	return parseProcessParams(query, accept, conf, *conf.StrictMode)
+}
+
+// GetProcessParamsFromPreset get the image process parameters of the named preset, false if the preset is not configured,
+// the sizes of the presets are not limited by the strict mode
+func GetProcessParamsFromPreset(name string, accept string, conf *config.ImageConfig) (*ProcessParams, bool) {
+	preset, ok := conf.Presets[name]
+	if !ok {
+		return nil, false
+	}
+	query := url.Values{}
+	for key, value := range preset {
+		query.Set(key, value)
+	}
+	return parseProcessParams(query, accept, conf, false), true
+}
+
+// parseProcessParams parse the image process parameters from the query, the sizes are limited to the allowed sizes if strict
+func parseProcessParams(query url.Values, accept string, conf *config.ImageConfig, strict bool) *ProcessParams {
 	params := &ProcessParams{}
 	if width := query.Get("w"); width != "" {
 		params.Width, _ = strconv.Atoi(width)
 	}
-	if *conf.StrictMode && !in(params.Width, conf.AllowedSizes) {
+	if strict && !in(params.Width, conf.AllowedSizes) {
 		params.Width = 0
 	} else {
 		params.Width = max(params.Width, 0)
@@ -87,7 +321,7 @@ func GetProcessParamsFromQuery(query url.Values) *ProcessParams {
 	if height := query.Get("h"); height != "" {
 		params.Height, _ = strconv.Atoi(height)
 	}
-	if *conf.StrictMode && !in(params.Height, conf.AllowedSizes) {
+	if strict && !in(params.Height, conf.AllowedSizes) {
 		params.Height = 0
 	} else {
 		params.Height = max(params.Height, 0)
@@ -120,23 +354,36 @@ func GetProcessParamsFromQuery(query url.Values) *ProcessParams {
 		params.Angle, _ = strconv.Atoi(angle)
 		params.Angle %= 360
 	}
-	if format := query.Get("f"); format != "" {
-		switch format {
-		case "jpeg":
-			params.Format = PictureFormatJpeg
-		case "png":
-			params.Format = PictureFormatPng
-		case "gif":
-			params.Format = PictureFormatGif
-		// case "webp":
-		//	params.Format = PictureFormatWebp
-		default:
-			params.Format = PictureFormat(conf.DefaultFormat)
-		}
+	if format := query.Get("f"); format == "auto" {
+		params.Format = NegotiateFormat(accept, conf)
+		params.Auto = true
 	} else {
+		params.Format = parseFormat(format)
+	}
+	if params.Format == "" || !isFormatAllowed(params.Format, conf) {
 		params.Format = PictureFormat(conf.DefaultFormat)
 	}
 
+	if crop := query.Get("c"); crop != "" {
+		params.Crop = parseCrop(crop)
+	}
+	if gravity := query.Get("g"); gravity != "" {
+		params.Gravity = parseGravity(gravity)
+	}
+
+	// Effects, limited to prevent the abuse of the CPU
+	params.Blur = parseSigma(query.Get("blur"), conf.MaxBlur)
+	params.Sharpen = parseSigma(query.Get("sharpen"), conf.MaxSharpen)
+	params.Grayscale = parseFlag(query.Get("grayscale"))
+	params.Brightness = parsePercent(query.Get("brightness"))
+	params.Contrast = parsePercent(query.Get("contrast"))
+	params.FlipH = parseFlag(query.Get("flipH"))
+	params.FlipV = parseFlag(query.Get("flipV"))
+
+	if preset := query.Get("wm"); preset != "" {
+		params.SetWatermark(preset, conf)
+	}
+
 	// If the format is PNG, the quality has only 2 values: 100 or 85
 	if params.Format == PictureFormatPng {
 		if params.Quality < 100 {
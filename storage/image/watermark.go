@@ -0,0 +1,199 @@
+package image
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/hash"
+)
+
+const (
+	defaultWatermarkOpacity  = 0.5
+	defaultWatermarkFontSize = 24
+	minWatermarkTileStep     = 16 // The minimum distance between the tiles, to limit the number of tiles
+)
+
+// SetWatermark set the watermark preset of the parameters, return false if the preset is not configured
+func (i *ProcessParams) SetWatermark(preset string, conf *config.ImageConfig) bool {
+	wm, ok := conf.Watermarks[preset]
+	if !ok {
+		return false
+	}
+	// The derivatives should be regenerated when the preset changes
+	data, _ := json.Marshal(wm)
+	i.Watermark = preset
+	i.watermarkVersion = hash.StringSha3New256(string(data))[:8]
+	return true
+}
+
+// placeWatermark draw the loaded watermark on the image, as configured in the preset
+func placeWatermark(img image.Image, mark image.Image, wm config.WatermarkConfig) image.Image {
+	bounds := img.Bounds()
+	if wm.Scale > 0 {
+		width := max(1, int(float64(bounds.Dx())*wm.Scale))
+		mark = imaging.Resize(mark, width, 0, imaging.Lanczos)
+	}
+
+	opacity := wm.Opacity
+	if opacity <= 0 {
+		opacity = defaultWatermarkOpacity
+	}
+	mask := image.NewUniform(color.Alpha{A: uint8(min(opacity, 1) * 255)})
+
+	dst := imaging.Clone(img)
+	markWidth, markHeight := mark.Bounds().Dx(), mark.Bounds().Dy()
+	margin := max(wm.Margin, 0)
+
+	if wm.Tile {
+		stepX := max(markWidth+margin, minWatermarkTileStep)
+		stepY := max(markHeight+margin, minWatermarkTileStep)
+		for y := margin; y < dst.Bounds().Dy(); y += stepY {
+			for x := margin; x < dst.Bounds().Dx(); x += stepX {
+				drawWatermark(dst, mark, image.Pt(x, y), mask)
+			}
+		}
+		return dst
+	}
+
+	drawWatermark(dst, mark, watermarkPosition(dst.Bounds(), markWidth, markHeight, margin, wm.Position), mask)
+	return dst
+}
+
+// drawWatermark draw the watermark at the point of the image with the opacity mask
+func drawWatermark(dst *image.NRGBA, mark image.Image, pt image.Point, mask image.Image) {
+	rect := image.Rectangle{Min: pt, Max: pt.Add(mark.Bounds().Size())}
+	draw.DrawMask(dst, rect, mark, mark.Bounds().Min, mask, image.Point{}, draw.Over)
+}
+
+// watermarkPosition get the top left point of the watermark for the position
+func watermarkPosition(bounds image.Rectangle, width, height, margin int, position string) image.Point {
+	gravity := parseGravity(position)
+	if gravity == "" {
+		gravity = GravitySouthEast
+	}
+
+	x, y := (bounds.Dx()-width)/2, (bounds.Dy()-height)/2
+	switch gravity {
+	case GravityWest, GravityNorthWest, GravitySouthWest:
+		x = margin
+	case GravityEast, GravityNorthEast, GravitySouthEast:
+		x = bounds.Dx() - width - margin
+	}
+	switch gravity {
+	case GravityNorth, GravityNorthWest, GravityNorthEast:
+		y = margin
+	case GravitySouth, GravitySouthWest, GravitySouthEast:
+		y = bounds.Dy() - height - margin
+	}
+	return image.Pt(x, y)
+}
+
+// loadWatermark load the watermark image of the preset, or render its text
+func loadWatermark(wm config.WatermarkConfig) (image.Image, error) {
+	if wm.Image != "" {
+		data, err := readStoredFile(wm.Image)
+		if err != nil {
+			return nil, errors.Wrap(err, "watermark image")
+		}
+		mark, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrap(err, "watermark image")
+		}
+		return mark, nil
+	}
+	if wm.Text == "" {
+		return nil, errors.Errorf("watermark has neither image nor text")
+	}
+	return renderText(wm)
+}
+
+// renderText render the text of the watermark on a transparent image
+func renderText(wm config.WatermarkConfig) (image.Image, error) {
+	fontData := goregular.TTF
+	if wm.Font != "" {
+		data, err := readStoredFile(wm.Font)
+		if err != nil {
+			return nil, errors.Wrap(err, "watermark font")
+		}
+		fontData = data
+	}
+	parsed, err := opentype.Parse(fontData)
+	if err != nil {
+		return nil, errors.Wrap(err, "watermark font")
+	}
+
+	size := wm.FontSize
+	if size <= 0 {
+		size = defaultWatermarkFontSize
+	}
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, errors.Wrap(err, "watermark font")
+	}
+	defer func() {
+		_ = face.Close()
+	}()
+
+	textColor, err := parseColor(wm.Color)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := face.Metrics()
+	drawer := &font.Drawer{Face: face, Src: image.NewUniform(textColor)}
+	width := max(1, drawer.MeasureString(wm.Text).Ceil())
+	height := max(1, (metrics.Ascent + metrics.Descent).Ceil())
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	drawer.Dst = dst
+	drawer.Dot = fixed.P(0, metrics.Ascent.Ceil())
+	drawer.DrawString(wm.Text)
+	return dst, nil
+}
+
+// parseColor parse the color like #ffffff or #ffffff80, white if empty
+func parseColor(value string) (color.NRGBA, error) {
+	if value == "" {
+		return color.NRGBA{R: 255, G: 255, B: 255, A: 255}, nil
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(value, "#"))
+	if err != nil || (len(data) != 3 && len(data) != 4) {
+		return color.NRGBA{}, errors.Errorf("invalid watermark color: %s", value)
+	}
+	c := color.NRGBA{R: data[0], G: data[1], B: data[2], A: 255}
+	if len(data) == 4 {
+		c.A = data[3]
+	}
+	return c, nil
+}
+
+// readStoredFile read the content of the file stored in goflet at the relative path
+func readStoredFile(relativePath string) ([]byte, error) {
+	fsPath, err := util.RelativeToFsPath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := storage.GetFileReader(fsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	return io.ReadAll(reader)
+}
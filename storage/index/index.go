@@ -0,0 +1,312 @@
+// Package index provides the index of the stored paths, the files are stored by the hash of their path,
+// so the index is needed to list the contents of a folder
+package index
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vvbbnn00/goflet/event"
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// foldersFile is the file in the base path storing the folders created explicitly, as they have no stored file
+const foldersFile = ".folders.json"
+
+// Entry is an entry in a folder
+type Entry struct {
+	Name  string `json:"name"`  // The name of the entry
+	Path  string `json:"path"`  // The relative path of the entry
+	IsDir bool   `json:"isDir"` // Whether the entry is a folder
+}
+
+var (
+	loadOnce sync.Once
+	loaded   bool
+	lock     sync.RWMutex
+	children = map[string]map[string]bool{"": {}} // The names of the entries in every folder, true for the folders
+	explicit = make(map[string]bool)              // The folders created explicitly, kept even if they are empty
+)
+
+func init() {
+	// Keep the index up to date with the file events
+	event.Subscribe(onEvent)
+}
+
+// normalize converts the path to the format of the relative path, without leading or trailing slash
+func normalize(p string) string {
+	p = strings.Trim(strings.ReplaceAll(p, "\\", "/"), "/")
+	if p == "" {
+		return ""
+	}
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// parent returns the parent folder and the name of the path
+func parent(p string) (string, string) {
+	dir, name := path.Split(p)
+	return strings.TrimSuffix(dir, "/"), name
+}
+
+// onEvent updates the index with the event
+func onEvent(e event.Event) {
+	lock.Lock()
+	defer lock.Unlock()
+	if !loaded {
+		return // The changes will be picked up by the scan
+	}
+
+	switch e.Type {
+	case event.TypeFileUploaded, event.TypeFileCreated, event.TypeFileCopied, event.TypeOnlyOfficeSaved:
+		addFile(normalize(e.Path))
+	case event.TypeFileMoved:
+		removeFile(normalize(e.SourcePath))
+		addFile(normalize(e.Path))
+	case event.TypeFileDeleted:
+		removeFile(normalize(e.Path))
+	case event.TypeFileHashed:
+	}
+}
+
+// ensureLoaded builds the index from the stored files on the first use
+func ensureLoaded() {
+	loadOnce.Do(func() {
+		lock.Lock()
+		defer lock.Unlock()
+
+		log.Infof("Building the path index...")
+		count := 0
+		storage.WalkFiles(func(_ string, _ int64, meta model.FileMeta) {
+			addFile(normalize(meta.RelativePath))
+			count++
+		})
+		for _, folder := range loadFolders() {
+			explicit[folder] = true
+			addEntry(folder, true)
+		}
+		loaded = true
+		log.Infof("Path index built, %d files and %d folders found.", count, len(explicit))
+	})
+}
+
+// getFoldersPath returns the path of the file storing the explicit folders
+func getFoldersPath() string {
+	return filepath.Join(util.GetBasePath(), foldersFile)
+}
+
+// loadFolders reads the explicit folders stored by saveFolders
+func loadFolders() []string {
+	data, err := os.ReadFile(getFoldersPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Error reading the folders: %s", err.Error())
+		}
+		return nil
+	}
+	var folders []string
+	if err = json.Unmarshal(data, &folders); err != nil {
+		log.Warnf("Error parsing the folders: %s", err.Error())
+		return nil
+	}
+	for i, folder := range folders {
+		folders[i] = normalize(folder)
+	}
+	return folders
+}
+
+// saveFolders stores the explicit folders, so the empty folders are kept after a restart
+func saveFolders() error {
+	folders := make([]string, 0, len(explicit))
+	for folder := range explicit {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+	data, err := json.Marshal(folders)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so the folders are not lost if the write is interrupted
+	foldersPath := getFoldersPath()
+	tmpPath := foldersPath + ".tmp"
+	if err = os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, foldersPath)
+}
+
+// addEntry adds the entry to its parent folder, and the parent folders to theirs
+func addEntry(p string, isDir bool) {
+	for p != "" {
+		dir, name := parent(p)
+		entries, ok := children[dir]
+		if !ok {
+			entries = make(map[string]bool)
+			children[dir] = entries
+		}
+		if _, exists := entries[name]; exists && (!isDir || children[p] != nil) {
+			return // The entry and its parents are already indexed
+		}
+		entries[name] = isDir
+		if isDir {
+			if _, ok := children[p]; !ok {
+				children[p] = make(map[string]bool)
+			}
+		}
+		p, isDir = dir, true
+	}
+}
+
+// addFile adds the file to the index
+func addFile(p string) {
+	if p == "" {
+		return
+	}
+	addEntry(p, false)
+}
+
+// removeFile removes the file from the index, and the parent folders becoming empty unless they are explicit
+func removeFile(p string) {
+	for p != "" {
+		dir, name := parent(p)
+		entries, ok := children[dir]
+		if !ok {
+			return
+		}
+		delete(entries, name)
+		if len(entries) > 0 || dir == "" || explicit[dir] {
+			return
+		}
+		delete(children, dir)
+		p = dir
+	}
+}
+
+// AddFolder adds the folder to the index, the folder is stored and kept even if it is empty
+func AddFolder(p string) error {
+	ensureLoaded()
+	p = normalize(p)
+
+	lock.Lock()
+	defer lock.Unlock()
+	if p == "" || explicit[p] {
+		return nil
+	}
+	explicit[p] = true
+	addEntry(p, true)
+	return saveFolders()
+}
+
+// RemoveFolder removes the empty folder from the index, returns false if the folder does not exist or is not empty
+func RemoveFolder(p string) bool {
+	ensureLoaded()
+	p = normalize(p)
+
+	lock.Lock()
+	defer lock.Unlock()
+	entries, ok := children[p]
+	if !ok || len(entries) > 0 || p == "" {
+		return false
+	}
+	delete(children, p)
+	removeFile(p)
+	if explicit[p] {
+		delete(explicit, p)
+		if err := saveFolders(); err != nil {
+			log.Warnf("Error saving the folders: %s", err.Error())
+		}
+	}
+	return true
+}
+
+// IsFolder returns whether the path is a folder in the index, the root is always a folder
+func IsFolder(p string) bool {
+	ensureLoaded()
+	p = normalize(p)
+
+	lock.RLock()
+	defer lock.RUnlock()
+	_, ok := children[p]
+	return ok
+}
+
+// List returns the entries in the folder sorted by name, false if the folder does not exist
+func List(p string) ([]Entry, bool) {
+	ensureLoaded()
+	p = normalize(p)
+
+	lock.RLock()
+	defer lock.RUnlock()
+	entries, ok := children[p]
+	if !ok {
+		return nil, false
+	}
+
+	result := make([]Entry, 0, len(entries))
+	for name, isDir := range entries {
+		result = append(result, Entry{Name: name, Path: path.Join(p, name), IsDir: isDir})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, true
+}
+
+// Files returns the relative paths of all the files under the folder sorted by path
+func Files(p string) []string {
+	ensureLoaded()
+	p = normalize(p)
+
+	lock.RLock()
+	defer lock.RUnlock()
+
+	var result []string
+	var walk func(dir string)
+	walk = func(dir string) {
+		for name, isDir := range children[dir] {
+			child := path.Join(dir, name)
+			if isDir {
+				walk(child)
+			} else {
+				result = append(result, child)
+			}
+		}
+	}
+	walk(p)
+	sort.Strings(result)
+	return result
+}
+
+// Folders returns the relative paths of the folder and all the folders under it, the deepest first
+func Folders(p string) []string {
+	ensureLoaded()
+	p = normalize(p)
+
+	lock.RLock()
+	defer lock.RUnlock()
+
+	var result []string
+	var walk func(dir string)
+	walk = func(dir string) {
+		for name, isDir := range children[dir] {
+			if isDir {
+				walk(path.Join(dir, name))
+			}
+		}
+		if dir != "" {
+			result = append(result, dir)
+		}
+	}
+	if _, ok := children[p]; ok {
+		walk(p)
+	}
+	return result
+}
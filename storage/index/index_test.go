@@ -0,0 +1,63 @@
+package index
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vvbbnn00/goflet/event"
+)
+
+func TestIndex(t *testing.T) {
+	ensureLoaded()
+
+	event.Publish(event.Event{Type: event.TypeFileUploaded, Path: "index-test/a/b.txt"})
+	event.Publish(event.Event{Type: event.TypeFileCreated, Path: "index-test/c.txt"})
+	AddFolder("/index-test/empty/")
+
+	entries, ok := List("index-test")
+	assert.True(t, ok)
+	assert.Equal(t, []Entry{
+		{Name: "a", Path: "index-test/a", IsDir: true},
+		{Name: "c.txt", Path: "index-test/c.txt"},
+		{Name: "empty", Path: "index-test/empty", IsDir: true},
+	}, entries)
+	assert.Equal(t, []string{"index-test/a/b.txt", "index-test/c.txt"}, Files("index-test"))
+
+	// The folders becoming empty are removed unless they are created explicitly
+	event.Publish(event.Event{Type: event.TypeFileMoved, SourcePath: "index-test/a/b.txt", Path: "index-test/empty/b.txt"})
+	assert.False(t, IsFolder("index-test/a"))
+	assert.False(t, RemoveFolder("index-test/empty"))
+
+	event.Publish(event.Event{Type: event.TypeFileDeleted, Path: "index-test/empty/b.txt"})
+	assert.True(t, IsFolder("index-test/empty"))
+	assert.False(t, RemoveFolder("index-test/c.txt"))
+	assert.True(t, RemoveFolder("index-test/empty"))
+
+	event.Publish(event.Event{Type: event.TypeFileDeleted, Path: "index-test/c.txt"})
+	assert.False(t, IsFolder("index-test"))
+}
+
+func TestFoldersStored(t *testing.T) {
+	ensureLoaded()
+	assert.NoError(t, AddFolder("/index-stored/empty"))
+
+	// reload builds the index again as after a restart
+	reload := func() {
+		lock.Lock()
+		children = map[string]map[string]bool{"": {}}
+		explicit = make(map[string]bool)
+		loaded = false
+		loadOnce = sync.Once{}
+		lock.Unlock()
+		ensureLoaded()
+	}
+
+	reload()
+	assert.True(t, IsFolder("index-stored/empty"))
+	assert.True(t, RemoveFolder("index-stored/empty"))
+
+	reload()
+	assert.False(t, IsFolder("index-stored"))
+}
@@ -25,13 +25,32 @@ type FileHash struct {
 	HashMd5    string `json:"md5"`
 }
 
+// FocalPoint is the point of interest of an image, relative to its width and height, from 0 to 1
+type FocalPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// ImageMeta contains the information of an image, computed once after the upload
+type ImageMeta struct {
+	Width    int      `json:"width"`              // The width of the image as displayed
+	Height   int      `json:"height"`             // The height of the image as displayed
+	Format   string   `json:"format"`             // The format of the image, like jpeg, png, gif
+	Frames   int      `json:"frames"`             // The number of frames, more than 1 if the image is animated
+	Palette  []string `json:"palette,omitempty"`  // The dominant colors like #rrggbb, the most used first
+	BlurHash string   `json:"blurHash,omitempty"` // The BlurHash of the image, used as the placeholder
+}
+
 // FileMeta contains the metadata of the file
 type FileMeta struct {
-	RelativePath string   `json:"relativePath"` // The relative path to the base file storage path
-	FileName     string   `json:"fileName"`     // The name of the file
-	MimeType     string   `json:"mimeType"`     // The mime type of the file
-	UploadedAt   int64    `json:"uploadedAt"`   // The time the file was uploaded
-	Hash         FileHash `json:"hash"`         // The hash of the file
+	RelativePath string      `json:"relativePath"`         // The relative path to the base file storage path
+	FileName     string      `json:"fileName"`             // The name of the file
+	MimeType     string      `json:"mimeType"`             // The mime type of the file
+	UploadedAt   int64       `json:"uploadedAt"`           // The time the file was uploaded
+	Owner        string      `json:"owner"`                // The subject of the token that uploaded the file
+	Hash         FileHash    `json:"hash"`                 // The hash of the file
+	FocalPoint   *FocalPoint `json:"focalPoint,omitempty"` // The focal point of the image, used by the fill crop
+	Image        *ImageMeta  `json:"image,omitempty"`      // The information of the image, nil if not an image or not analyzed yet
 }
 
 // FileInfo contains the information of the file
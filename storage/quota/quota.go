@@ -0,0 +1,325 @@
+// Package quota provides the storage quotas per path prefix and per token subject
+package quota
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// AnySubject is the subject key of the quota applied to every subject not listed
+const AnySubject = "*"
+
+// Usage contains the usage of a quota
+type Usage struct {
+	Bytes int64 `json:"bytes"` // The total size of the files
+	Files int64 `json:"files"` // The number of files
+}
+
+// Report contains the limit and the usage of a quota
+type Report struct {
+	Name  string            `json:"name"`  // The prefix or the subject of the quota
+	Limit config.QuotaLimit `json:"limit"` // The limit of the quota
+	Usage Usage             `json:"usage"` // The current usage of the quota
+}
+
+// Delta is a change of the usage caused by a file
+type Delta struct {
+	RelativePath string // The relative path of the file
+	Owner        string // The owner of the file, empty if the file has no owner
+	Bytes        int64  // The change of the size
+	Files        int64  // The change of the number of files
+}
+
+// Negate returns the delta that reverts the change
+func (d Delta) Negate() Delta {
+	d.Bytes = -d.Bytes
+	d.Files = -d.Files
+	return d
+}
+
+var (
+	loadOnce     sync.Once
+	lock         sync.RWMutex
+	prefixUsage  = make(map[string]*Usage) // The usage of the configured prefixes
+	subjectUsage = make(map[string]*Usage) // The usage of every subject
+)
+
+// Enabled returns whether the quotas are enabled
+func Enabled() bool {
+	return *config.GofletCfg.QuotaConfig.Enabled
+}
+
+// normalizePrefix converts the prefix to the format of the relative path, without leading or trailing slash
+func normalizePrefix(prefix string) string {
+	return strings.Trim(filepath.ToSlash(prefix), "/")
+}
+
+// prefixMatch checks whether the relative path is under the prefix
+func prefixMatch(relativePath, prefix string) bool {
+	relativePath = normalizePrefix(relativePath)
+	return prefix == "" || relativePath == prefix || strings.HasPrefix(relativePath, prefix+"/")
+}
+
+// matchingPrefixes returns the configured prefixes containing the relative path
+func matchingPrefixes(relativePath string) []string {
+	var result []string
+	for prefix := range config.GofletCfg.QuotaConfig.Prefixes {
+		normalized := normalizePrefix(prefix)
+		if prefixMatch(relativePath, normalized) {
+			result = append(result, normalized)
+		}
+	}
+	return result
+}
+
+// PrefixLimit returns the limit of the prefix
+func PrefixLimit(prefix string) (config.QuotaLimit, bool) {
+	normalized := normalizePrefix(prefix)
+	for p, limit := range config.GofletCfg.QuotaConfig.Prefixes {
+		if normalizePrefix(p) == normalized {
+			return limit, true
+		}
+	}
+	return config.QuotaLimit{}, false
+}
+
+// SubjectLimit returns the limit of the subject
+func SubjectLimit(subject string) (config.QuotaLimit, bool) {
+	subjects := config.GofletCfg.QuotaConfig.Subjects
+	if limit, ok := subjects[subject]; ok {
+		return limit, true
+	}
+	limit, ok := subjects[AnySubject]
+	return limit, ok
+}
+
+// add adds the delta to the usage in the map
+func add(usages map[string]*Usage, key string, d Delta) {
+	usage, ok := usages[key]
+	if !ok {
+		usage = &Usage{}
+		usages[key] = usage
+	}
+	usage.Bytes += d.Bytes
+	usage.Files += d.Files
+}
+
+// group sums the deltas by prefix and by subject
+func group(deltas []Delta) (map[string]*Usage, map[string]*Usage) {
+	prefixes := make(map[string]*Usage)
+	subjects := make(map[string]*Usage)
+	for _, d := range deltas {
+		for _, prefix := range matchingPrefixes(d.RelativePath) {
+			add(prefixes, prefix, d)
+		}
+		if d.Owner != "" {
+			add(subjects, d.Owner, d)
+		}
+	}
+	return prefixes, subjects
+}
+
+// exceeds checks whether the delta makes the usage exceed the limit, a decreasing delta never exceeds
+func exceeds(limit config.QuotaLimit, usage *Usage, delta *Usage) bool {
+	current := Usage{}
+	if usage != nil {
+		current = *usage
+	}
+	if delta.Bytes > 0 && limit.MaxBytes > 0 && current.Bytes+delta.Bytes > limit.MaxBytes {
+		return true
+	}
+	if delta.Files > 0 && limit.MaxFiles > 0 && current.Files+delta.Files > limit.MaxFiles {
+		return true
+	}
+	return false
+}
+
+// Check checks whether the changes are allowed by the quotas, the changes are not counted, so the operations
+// should reserve them with Reserve before they are made
+func Check(deltas ...Delta) error {
+	if !Enabled() {
+		return nil
+	}
+	ensureLoaded()
+
+	prefixes, subjects := group(deltas)
+
+	lock.RLock()
+	defer lock.RUnlock()
+	return checkQuotas(prefixes, subjects)
+}
+
+// checkQuotas checks the grouped changes against the quotas, the lock should be held
+func checkQuotas(prefixes, subjects map[string]*Usage) error {
+	for prefix, delta := range prefixes {
+		limit, _ := PrefixLimit(prefix)
+		if exceeds(limit, prefixUsage[prefix], delta) {
+			log.Debugf("Quota of prefix %s exceeded", prefix)
+			return errors.New("quota_exceeded")
+		}
+	}
+	for subject, delta := range subjects {
+		limit, ok := SubjectLimit(subject)
+		if ok && exceeds(limit, subjectUsage[subject], delta) {
+			log.Debugf("Quota of subject %s exceeded", subject)
+			return errors.New("quota_exceeded")
+		}
+	}
+	return nil
+}
+
+// Apply applies the changes to the usage
+func Apply(deltas ...Delta) {
+	if !Enabled() {
+		return
+	}
+	ensureLoaded()
+
+	prefixes, subjects := group(deltas)
+
+	lock.Lock()
+	defer lock.Unlock()
+	apply(prefixes, subjects)
+}
+
+// apply adds the grouped changes to the usage, the lock should be held
+func apply(prefixes, subjects map[string]*Usage) {
+	for prefix, delta := range prefixes {
+		add(prefixUsage, prefix, Delta{Bytes: delta.Bytes, Files: delta.Files})
+	}
+	for subject, delta := range subjects {
+		add(subjectUsage, subject, Delta{Bytes: delta.Bytes, Files: delta.Files})
+	}
+}
+
+// Reserve checks the changes against the quotas and counts them in the usage at once, so the concurrent operations
+// cannot exceed the quotas together. The changes are kept when the operation succeeds, otherwise the returned
+// function should be called to release them
+func Reserve(deltas ...Delta) (func(), error) {
+	return reserve(deltas, true)
+}
+
+// Count counts the changes in the usage without checking the quotas, like Reserve, the returned function releases
+// them if the operation fails
+func Count(deltas ...Delta) func() {
+	release, _ := reserve(deltas, false)
+	return release
+}
+
+// reserve counts the changes in the usage, they are checked against the quotas first if check is set
+func reserve(deltas []Delta, check bool) (func(), error) {
+	if !Enabled() {
+		return func() {}, nil
+	}
+	ensureLoaded()
+
+	prefixes, subjects := group(deltas)
+
+	lock.Lock()
+	defer lock.Unlock()
+	if check {
+		if err := checkQuotas(prefixes, subjects); err != nil {
+			return nil, err
+		}
+	}
+	apply(prefixes, subjects)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			reverted := make([]Delta, len(deltas))
+			for i, d := range deltas {
+				reverted[i] = d.Negate()
+			}
+			Apply(reverted...)
+		})
+	}, nil
+}
+
+// Existing returns the delta of the stored file at the provided path, false if the file does not exist
+func Existing(fsPath string) (Delta, bool) {
+	fileInfo, err := storage.GetFileInfo(fsPath)
+	if err != nil {
+		return Delta{}, false
+	}
+	return Delta{
+		RelativePath: fileInfo.FileMeta.RelativePath,
+		Owner:        fileInfo.FileMeta.Owner,
+		Bytes:        fileInfo.FileSize,
+		Files:        1,
+	}, true
+}
+
+// GetPrefixReport returns the report of the configured prefix, false if the prefix has no quota
+func GetPrefixReport(prefix string) (Report, bool) {
+	limit, ok := PrefixLimit(prefix)
+	if !Enabled() || !ok {
+		return Report{}, false
+	}
+	ensureLoaded()
+
+	lock.RLock()
+	defer lock.RUnlock()
+	report := Report{Name: prefix, Limit: limit}
+	if usage, ok := prefixUsage[normalizePrefix(prefix)]; ok {
+		report.Usage = *usage
+	}
+	return report, true
+}
+
+// GetSubjectReport returns the report of the subject, false if the subject has no quota
+func GetSubjectReport(subject string) (Report, bool) {
+	limit, ok := SubjectLimit(subject)
+	if !Enabled() || !ok || subject == "" {
+		return Report{}, false
+	}
+	ensureLoaded()
+
+	lock.RLock()
+	defer lock.RUnlock()
+	report := Report{Name: subject, Limit: limit}
+	if usage, ok := subjectUsage[subject]; ok {
+		report.Usage = *usage
+	}
+	return report, true
+}
+
+// ensureLoaded computes the usage from the stored files on the first use
+func ensureLoaded() {
+	loadOnce.Do(func() {
+		log.Infof("Computing the storage usage for quotas...")
+		usages := scan()
+
+		lock.Lock()
+		defer lock.Unlock()
+		prefixes, subjects := group(usages)
+		for prefix, usage := range prefixes {
+			prefixUsage[prefix] = usage
+		}
+		for subject, usage := range subjects {
+			subjectUsage[subject] = usage
+		}
+		log.Infof("Storage usage computed, %d files found.", len(usages))
+	})
+}
+
+// scan walks the storage roots and returns the delta of every stored file
+func scan() []Delta {
+	var result []Delta
+	storage.WalkFiles(func(_ string, size int64, meta model.FileMeta) {
+		result = append(result, Delta{
+			RelativePath: meta.RelativePath,
+			Owner:        meta.Owner,
+			Bytes:        size,
+			Files:        1,
+		})
+	})
+	return result
+}
@@ -0,0 +1,108 @@
+package quota
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vvbbnn00/goflet/config"
+)
+
+// enableQuotas enables the quotas with the provided limits
+func enableQuotas(prefixes, subjects map[string]config.QuotaLimit) {
+	enabled := true
+	config.GofletCfg.QuotaConfig.Enabled = &enabled
+	config.GofletCfg.QuotaConfig.Prefixes = prefixes
+	config.GofletCfg.QuotaConfig.Subjects = subjects
+}
+
+func TestPrefixQuota(t *testing.T) {
+	enableQuotas(map[string]config.QuotaLimit{
+		"/quota-test-prefix": {MaxBytes: 100, MaxFiles: 2},
+	}, nil)
+
+	file := Delta{RelativePath: "quota-test-prefix/a.txt", Bytes: 60, Files: 1}
+	assert.NoError(t, Check(file))
+	Apply(file)
+
+	// Exceeds the size limit
+	assert.Error(t, Check(Delta{RelativePath: "quota-test-prefix/b.txt", Bytes: 60, Files: 1}))
+	// Other paths are not limited
+	assert.NoError(t, Check(Delta{RelativePath: "quota-test-prefix-other/b.txt", Bytes: 60, Files: 1}))
+	// Replacing the file frees its space
+	assert.NoError(t, Check(file.Negate(), Delta{RelativePath: "quota-test-prefix/a.txt", Bytes: 90, Files: 1}))
+
+	report, ok := GetPrefixReport("quota-test-prefix")
+	assert.True(t, ok)
+	assert.Equal(t, Usage{Bytes: 60, Files: 1}, report.Usage)
+
+	Apply(file.Negate())
+	report, _ = GetPrefixReport("quota-test-prefix")
+	assert.Equal(t, Usage{}, report.Usage)
+}
+
+func TestSubjectQuota(t *testing.T) {
+	enableQuotas(nil, map[string]config.QuotaLimit{
+		AnySubject: {MaxFiles: 1},
+	})
+
+	file := Delta{RelativePath: "quota-test-subject/a.txt", Owner: "quota-test-user", Files: 1}
+	assert.NoError(t, Check(file))
+	Apply(file)
+
+	assert.Error(t, Check(Delta{RelativePath: "quota-test-subject/b.txt", Owner: "quota-test-user", Files: 1}))
+	assert.NoError(t, Check(Delta{RelativePath: "quota-test-subject/b.txt", Owner: "quota-test-other", Files: 1}))
+	// Files without owner are not limited by the subject quotas
+	assert.NoError(t, Check(Delta{RelativePath: "quota-test-subject/b.txt", Files: 1}))
+
+	_, ok := GetSubjectReport("")
+	assert.False(t, ok)
+
+	Apply(file.Negate())
+}
+
+func TestReserve(t *testing.T) {
+	enableQuotas(map[string]config.QuotaLimit{
+		"/quota-test-reserve": {MaxBytes: 100},
+	}, nil)
+
+	// The concurrent reservations cannot exceed the quota together
+	var wg sync.WaitGroup
+	var reserved atomic.Int32
+	releases := make(chan func(), 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := Reserve(Delta{RelativePath: "quota-test-reserve/a.txt", Bytes: 10, Files: 1})
+			if err == nil {
+				reserved.Add(1)
+				releases <- release
+			}
+		}()
+	}
+	wg.Wait()
+	close(releases)
+	assert.Equal(t, int32(10), reserved.Load())
+
+	// The released reservation frees its space once
+	release := <-releases
+	release()
+	release()
+	report, _ := GetPrefixReport("quota-test-reserve")
+	assert.Equal(t, Usage{Bytes: 90, Files: 9}, report.Usage)
+
+	// The counted changes are not checked
+	releaseCount := Count(Delta{RelativePath: "quota-test-reserve/b.txt", Bytes: 20, Files: 1})
+	report, _ = GetPrefixReport("quota-test-reserve")
+	assert.Equal(t, Usage{Bytes: 110, Files: 10}, report.Usage)
+	releaseCount()
+
+	for release := range releases {
+		release()
+	}
+	report, _ = GetPrefixReport("quota-test-reserve")
+	assert.Equal(t, Usage{}, report.Usage)
+}
@@ -0,0 +1,338 @@
+// Package scrub provides the integrity check of the storage, the stored files are re-hashed against their metadata,
+// and the leftovers of the uploads and the image caches of the removed files are found
+package scrub
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vvbbnn00/goflet/cache"
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event"
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/hasher"
+	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/storage/quota"
+	"github.com/vvbbnn00/goflet/storage/upload"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/hash"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+const (
+	// KindMetaInvalid is the problem of a file whose metadata is missing or undecodable
+	KindMetaInvalid = "meta_invalid"
+	// KindHashMismatch is the problem of a file whose content does not match the stored hash
+	KindHashMismatch = "hash_mismatch"
+	// KindHashMissing is the problem of a file whose hash is not stored
+	KindHashMissing = "hash_missing"
+	// KindMisplaced is the problem of a file whose relative path does not hash back to its folder
+	KindMisplaced = "misplaced"
+	// KindOrphanedTemp is the problem of an outdated temporary file of an upload or a metadata update
+	KindOrphanedTemp = "orphaned_temp"
+	// KindOrphanedMeta is the problem of a metadata file without the file
+	KindOrphanedMeta = "orphaned_meta"
+	// KindOrphanedImageCache is the problem of an image cache without the file
+	KindOrphanedImageCache = "orphaned_image_cache"
+)
+
+const (
+	// ActionRepaired is the action of a repaired problem
+	ActionRepaired = "repaired"
+	// ActionRemoved is the action of a removed leftover
+	ActionRemoved = "removed"
+	// ActionQuarantined is the action of a file moved to the quarantine folder
+	ActionQuarantined = "quarantined"
+	// ActionFailed is the action failed to fix the problem, the error is in the detail
+	ActionFailed = "failed"
+)
+
+// tempPrefix is the prefix of the temporary files in the folders of the stored files
+const tempPrefix = "tmp-"
+
+// reasonFile is the file describing the problem in a quarantined folder
+const reasonFile = "quarantine.json"
+
+// Options are the options of a scrub
+type Options struct {
+	Repair     bool // Repair the missing hashes and the misplaced files, and remove the leftovers
+	Quarantine bool // Move the files which cannot be repaired to the quarantine folder
+}
+
+// Problem is a problem found in the storage
+type Problem struct {
+	Kind         string `json:"kind"`                   // The kind of the problem
+	Path         string `json:"path"`                   // The fs path of the folder or the file with the problem
+	RelativePath string `json:"relativePath,omitempty"` // The relative path of the stored file, empty if it is unknown
+	Detail       string `json:"detail,omitempty"`       // The detail of the problem
+	Action       string `json:"action,omitempty"`       // The action taken, empty if nothing is done
+}
+
+// Report is the report of a scrub
+type Report struct {
+	StartedAt  int64     `json:"startedAt"`  // The time the scrub started
+	FinishedAt int64     `json:"finishedAt"` // The time the scrub finished
+	Checked    int       `json:"checked"`    // The number of the checked files
+	Problems   []Problem `json:"problems"`   // The problems found
+}
+
+// scrubber keeps the state of a scrub
+type scrubber struct {
+	opts          Options
+	report        Report
+	uploadTimeout time.Duration
+	skip          map[string]bool // The folders not scanned as the storage, like the upload folder
+}
+
+// Run checks the storage, the problems are fixed as the options allow
+func Run(opts Options) Report {
+	s := &scrubber{
+		opts:          opts,
+		report:        Report{StartedAt: time.Now().Unix(), Problems: []Problem{}},
+		uploadTimeout: time.Duration(config.GofletCfg.FileConfig.UploadTimeout) * time.Second,
+		skip: map[string]bool{
+			filepath.Clean(util.GetUploadPath()): true,
+			filepath.Clean(GetQuarantinePath()):  true,
+		},
+	}
+
+	for _, root := range util.GetStorageRoots() {
+		s.scanStorage(root)
+	}
+	s.scanUploads()
+
+	s.report.FinishedAt = time.Now().Unix()
+	return s.report
+}
+
+// GetQuarantinePath returns the folder of the quarantined files
+func GetQuarantinePath() string {
+	return util.GetPath(config.GofletCfg.ScrubConfig.QuarantinePath)
+}
+
+// add adds the problem to the report
+func (s *scrubber) add(p Problem) {
+	s.report.Problems = append(s.report.Problems, p)
+}
+
+// outdated checks whether the file is not modified within the upload timeout, so it is not in use
+func (s *scrubber) outdated(info fs.FileInfo) bool {
+	return time.Since(info.ModTime()) > s.uploadTimeout
+}
+
+// scanUploads finds the outdated temporary files of the uploads, the multipart uploads are skipped as they expire
+// as a whole from their manifests
+func (s *scrubber) scanUploads() {
+	multipartPath := filepath.Clean(upload.GetMultipartPath())
+	_ = filepath.WalkDir(util.GetUploadPath(), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && filepath.Clean(path) == multipartPath {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && s.outdated(info) {
+			s.removeLeftover(Problem{Kind: KindOrphanedTemp, Path: path})
+		}
+		return nil
+	})
+}
+
+// scanStorage checks the folders of the stored files under the root
+func (s *scrubber) scanStorage(root string) {
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if s.skip[filepath.Clean(path)] {
+			return filepath.SkipDir
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil
+		}
+		var names []string
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+		if len(names) > 0 {
+			s.checkFolder(path, names)
+		}
+		return nil
+	})
+}
+
+// checkFolder checks the folder of a stored file with the names of the files in it
+func (s *scrubber) checkFolder(fsPath string, names []string) {
+	if uploading(fsPath) {
+		return // The file is being written
+	}
+
+	hasFile, hasMeta := false, false
+	var imageCaches []string
+	for _, name := range names {
+		switch {
+		case name == model.FileAppend:
+			hasFile = true
+		case name == model.MetaAppend:
+			hasMeta = true
+		case strings.HasPrefix(name, model.ImageAppend):
+			imageCaches = append(imageCaches, name)
+		case strings.HasPrefix(name, tempPrefix):
+			if info, err := os.Stat(filepath.Join(fsPath, name)); err == nil && s.outdated(info) {
+				s.removeLeftover(Problem{Kind: KindOrphanedTemp, Path: filepath.Join(fsPath, name)})
+			}
+		}
+	}
+
+	if !hasFile {
+		for _, name := range imageCaches {
+			s.removeLeftover(Problem{Kind: KindOrphanedImageCache, Path: filepath.Join(fsPath, name)})
+		}
+		if hasMeta {
+			s.removeLeftover(Problem{Kind: KindOrphanedMeta, Path: filepath.Join(fsPath, model.MetaAppend)})
+		}
+		return
+	}
+
+	s.report.Checked++
+	s.checkFile(fsPath)
+}
+
+// checkFile checks the metadata, the location and the hash of the stored file in the folder
+func (s *scrubber) checkFile(fsPath string) {
+	meta, err := storage.LoadFileMeta(fsPath)
+	if err != nil || meta.RelativePath == "" {
+		detail := "missing relative path"
+		if err != nil {
+			detail = err.Error()
+		}
+		s.quarantine(Problem{Kind: KindMetaInvalid, Path: fsPath, Detail: detail}, false)
+		return
+	}
+
+	expected, err := util.RelativeToFsPath(meta.RelativePath)
+	if err == nil && filepath.Clean(expected) != filepath.Clean(fsPath) {
+		s.relocate(Problem{Kind: KindMisplaced, Path: fsPath, RelativePath: meta.RelativePath,
+			Detail: "expected at " + expected}, expected)
+		return
+	}
+
+	if meta.Hash.HashSha256 == "" {
+		p := Problem{Kind: KindHashMissing, Path: fsPath, RelativePath: meta.RelativePath}
+		if s.opts.Repair {
+			hasher.HashFileAsync(fsPath)
+			p.Action = ActionRepaired
+		}
+		s.add(p)
+		return
+	}
+
+	sum, err := hash.FileSha256(filepath.Join(fsPath, model.FileAppend))
+	if err != nil || sum != meta.Hash.HashSha256 {
+		if replaced(fsPath, meta) {
+			return // The file is replaced during the check, its new content is hashed in the background
+		}
+		detail := "sha256 " + sum + " does not match the stored " + meta.Hash.HashSha256
+		if err != nil {
+			detail = err.Error()
+		}
+		s.quarantine(Problem{Kind: KindHashMismatch, Path: fsPath, RelativePath: meta.RelativePath, Detail: detail}, true)
+	}
+}
+
+// uploading checks whether the file in the folder is being written
+func uploading(fsPath string) bool {
+	uploading, _ := cache.GetCache().GetBool(storage.CachePrefix + fsPath + string(filepath.Separator))
+	return uploading
+}
+
+// replaced checks whether the file is being replaced, or is replaced since its metadata is loaded
+func replaced(fsPath string, meta model.FileMeta) bool {
+	if uploading(fsPath) {
+		return true
+	}
+	current, err := storage.LoadFileMeta(fsPath)
+	return err != nil || current.UploadedAt != meta.UploadedAt || current.Hash != meta.Hash
+}
+
+// removeLeftover removes the leftover file of the problem if repairing is allowed
+func (s *scrubber) removeLeftover(p Problem) {
+	if s.opts.Repair {
+		if err := os.Remove(p.Path); err != nil {
+			p.Action, p.Detail = ActionFailed, err.Error()
+		} else {
+			p.Action = ActionRemoved
+		}
+	}
+	s.add(p)
+}
+
+// relocate moves the misplaced file to the folder of its relative path, the file is quarantined
+// if another file is stored there
+func (s *scrubber) relocate(p Problem, expected string) {
+	if !s.opts.Repair {
+		s.add(p)
+		return
+	}
+	if storage.FileExists(expected) {
+		p.Detail += ", which is taken by another file"
+		s.quarantine(p, false)
+		return
+	}
+
+	err := os.MkdirAll(filepath.Dir(filepath.Clean(expected)), os.ModePerm)
+	if err == nil {
+		_ = os.RemoveAll(expected) // The leftovers of the removed file
+		err = storage.RenameFile(filepath.Clean(p.Path), filepath.Clean(expected))
+	}
+	if err != nil {
+		p.Action, p.Detail = ActionFailed, err.Error()
+	} else {
+		p.Action = ActionRepaired
+	}
+	s.add(p)
+}
+
+// quarantine moves the folder of the problem to the quarantine folder if it is allowed, the file is removed from
+// the quota and the index if it is known by its relative path
+func (s *scrubber) quarantine(p Problem, known bool) {
+	if !s.opts.Quarantine {
+		s.add(p)
+		return
+	}
+
+	target := filepath.Join(GetQuarantinePath(), strconv.FormatInt(time.Now().UnixNano(), 10)+"-"+filepath.Base(p.Path))
+	existing, exists := quota.Existing(p.Path)
+	e := event.NewFileEvent(event.TypeFileDeleted, event.Actor{}, p.RelativePath, p.Path)
+
+	err := os.MkdirAll(GetQuarantinePath(), os.ModePerm)
+	if err == nil {
+		err = storage.RenameFile(filepath.Clean(p.Path), target)
+	}
+	if err != nil {
+		p.Action, p.Detail = ActionFailed, err.Error()
+		log.Warnf("Error quarantining %s: %s", p.Path, err.Error())
+		s.add(p)
+		return
+	}
+	if known {
+		if exists {
+			quota.Apply(existing.Negate())
+		}
+		event.Publish(e)
+	}
+
+	p.Action = ActionQuarantined
+	data, _ := json.MarshalIndent(p, "", "  ")
+	_ = os.WriteFile(filepath.Join(target, reasonFile), data, model.FilePerm)
+	s.add(p)
+}
@@ -0,0 +1,159 @@
+package scrub
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/storage"
+	"github.com/vvbbnn00/goflet/storage/hasher"
+	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/storage/upload"
+	"github.com/vvbbnn00/goflet/util"
+	"github.com/vvbbnn00/goflet/util/hash"
+)
+
+func init() {
+	config.InitConfig()
+	config.GofletCfg.ScrubConfig.QuarantinePath = filepath.Join(os.TempDir(), "goflet-quarantine-"+util.RandomString(8))
+}
+
+// storeFile stores the file with the content at the relative path, returns the fs path
+func storeFile(t *testing.T, relativePath string, content string) string {
+	localPath := filepath.Join(t.TempDir(), "local")
+	assert.NoError(t, os.WriteFile(localPath, []byte(content), 0644))
+	assert.NoError(t, upload.ImportFile(localPath, relativePath, "", false, false))
+	hasher.Wait()
+	fsPath, _ := util.RelativeToFsPath(relativePath)
+	return filepath.Clean(fsPath)
+}
+
+// findProblem returns the problem of the path in the report
+func findProblem(report Report, path string) (Problem, bool) {
+	for _, p := range report.Problems {
+		if filepath.Clean(p.Path) == filepath.Clean(path) {
+			return p, true
+		}
+	}
+	return Problem{}, false
+}
+
+// TestHashMismatch tests finding and quarantining the corrupted file
+func TestHashMismatch(t *testing.T) {
+	defer func() {
+		_ = os.RemoveAll(GetQuarantinePath())
+	}()
+	fsPath := storeFile(t, "scrub/"+util.RandomString(8)+".txt", "content")
+	report := Run(Options{})
+	_, found := findProblem(report, fsPath)
+	assert.False(t, found)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(fsPath, model.FileAppend), []byte("corrupted"), model.FilePerm))
+	p, found := findProblem(Run(Options{}), fsPath)
+	if assert.True(t, found) {
+		assert.Equal(t, KindHashMismatch, p.Kind)
+		assert.Empty(t, p.Action)
+	}
+
+	p, _ = findProblem(Run(Options{Quarantine: true}), fsPath)
+	assert.Equal(t, ActionQuarantined, p.Action)
+	assert.False(t, storage.FileExists(fsPath))
+	reasons, _ := filepath.Glob(filepath.Join(GetQuarantinePath(), "*-"+filepath.Base(fsPath), reasonFile))
+	assert.Len(t, reasons, 1)
+}
+
+// TestOverwritten tests the overwritten file is not quarantined while its new content is hashed
+func TestOverwritten(t *testing.T) {
+	defer func() {
+		_ = os.RemoveAll(GetQuarantinePath())
+	}()
+	relativePath := "scrub/" + util.RandomString(8) + ".txt"
+	fsPath := storeFile(t, relativePath, "content")
+
+	localPath := filepath.Join(t.TempDir(), "local")
+	assert.NoError(t, os.WriteFile(localPath, []byte("new content"), 0644))
+	assert.NoError(t, upload.ImportFile(localPath, relativePath, "", false, false))
+
+	// The hash of the previous content is not kept, the new one may be computed already
+	meta, err := storage.LoadFileMeta(fsPath)
+	assert.NoError(t, err)
+	assert.Contains(t, []string{"", hash.StringSha256("new content")}, meta.Hash.HashSha256)
+
+	if p, found := findProblem(Run(Options{Quarantine: true}), fsPath); found {
+		assert.Equal(t, KindHashMissing, p.Kind)
+	}
+	assert.True(t, storage.FileExists(fsPath))
+	hasher.Wait()
+}
+
+// TestMisplaced tests moving the misplaced file back to the folder of its relative path
+func TestMisplaced(t *testing.T) {
+	fsPath := storeFile(t, "scrub/"+util.RandomString(8)+".txt", "misplaced")
+	wrongPath, _ := util.RelativeToFsPath("scrub/" + util.RandomString(8))
+	wrongPath = filepath.Clean(wrongPath)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(wrongPath), os.ModePerm))
+	assert.NoError(t, os.Rename(fsPath, wrongPath))
+
+	p, found := findProblem(Run(Options{Repair: true}), wrongPath)
+	if assert.True(t, found) {
+		assert.Equal(t, KindMisplaced, p.Kind)
+		assert.Equal(t, ActionRepaired, p.Action)
+	}
+	assert.True(t, storage.FileExists(fsPath))
+	assert.False(t, storage.FileExists(wrongPath))
+}
+
+// TestLeftovers tests finding the invalid metadata and removing the orphaned image caches
+func TestLeftovers(t *testing.T) {
+	root := util.GetStorageRoots()[0]
+	orphan := filepath.Join(root, "zz", "zz", util.RandomString(16))
+	assert.NoError(t, os.MkdirAll(orphan, os.ModePerm))
+	imageCache := filepath.Join(orphan, model.ImageAppend+"w64")
+	assert.NoError(t, os.WriteFile(imageCache, []byte("image"), model.FilePerm))
+
+	invalid := filepath.Join(root, "zz", "zz", util.RandomString(16))
+	assert.NoError(t, os.MkdirAll(invalid, os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(invalid, model.FileAppend), []byte("data"), model.FilePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(invalid, model.MetaAppend), []byte("garbage"), model.FilePerm))
+	defer func() {
+		_ = os.RemoveAll(invalid)
+	}()
+
+	report := Run(Options{Repair: true})
+	p, found := findProblem(report, imageCache)
+	if assert.True(t, found) {
+		assert.Equal(t, KindOrphanedImageCache, p.Kind)
+		assert.Equal(t, ActionRemoved, p.Action)
+	}
+	_, err := os.Stat(imageCache)
+	assert.True(t, os.IsNotExist(err))
+
+	p, found = findProblem(report, invalid)
+	if assert.True(t, found) {
+		assert.Equal(t, KindMetaInvalid, p.Kind)
+		assert.Empty(t, p.Action) // Only quarantined if allowed
+	}
+}
+
+// TestMultipartUpload tests keeping the parts of the multipart uploads, they expire with their manifests
+func TestMultipartUpload(t *testing.T) {
+	dir := filepath.Join(upload.GetMultipartPath(), util.RandomString(32))
+	assert.NoError(t, os.MkdirAll(dir, os.ModePerm))
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, upload.MultipartManifest), []byte("{}"), model.FilePerm))
+	part := filepath.Join(dir, "1.part")
+	assert.NoError(t, os.WriteFile(part, []byte("part"), model.FilePerm))
+	old := time.Now().Add(-2 * time.Duration(config.GofletCfg.FileConfig.UploadTimeout) * time.Second)
+	assert.NoError(t, os.Chtimes(part, old, old))
+
+	report := Run(Options{Repair: true})
+	_, found := findProblem(report, part)
+	assert.False(t, found)
+	assert.FileExists(t, part)
+}
@@ -0,0 +1,42 @@
+package upload
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vvbbnn00/goflet/util"
+)
+
+// MultipartManifest is the file of a multipart upload of the S3 API storing the bucket and the key, it is touched
+// when a part is uploaded
+const MultipartManifest = "upload.json"
+
+// GetMultipartPath returns the folder of the multipart uploads of the S3 API in the upload path, each upload is
+// a folder of its manifest and its parts
+func GetMultipartPath() string {
+	return filepath.Join(util.GetUploadPath(), "s3")
+}
+
+// OutdatedMultipartUploads returns the folders of the multipart uploads not updated within the timeout, an upload
+// expires as a whole from the modification time of its manifest, so its older parts are kept while it goes on
+func OutdatedMultipartUploads(timeout time.Duration) []string {
+	multipartPath := GetMultipartPath()
+	entries, err := os.ReadDir(multipartPath)
+	if err != nil {
+		return nil
+	}
+
+	var outdated []string
+	for _, entry := range entries {
+		dir := filepath.Join(multipartPath, entry.Name())
+		info, err := os.Stat(filepath.Join(dir, MultipartManifest))
+		if err != nil {
+			info, err = entry.Info() // The manifest is not written
+		}
+		if err == nil && time.Since(info.ModTime()) > timeout {
+			outdated = append(outdated, dir)
+		}
+	}
+	return outdated
+}
@@ -3,6 +3,7 @@ package upload
 
 import (
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,10 +14,12 @@ import (
 
 	"github.com/vvbbnn00/goflet/cache"
 	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/event"
 	"github.com/vvbbnn00/goflet/storage"
 	"github.com/vvbbnn00/goflet/storage/hasher"
 	"github.com/vvbbnn00/goflet/storage/image"
 	"github.com/vvbbnn00/goflet/storage/model"
+	"github.com/vvbbnn00/goflet/storage/quota"
 	"github.com/vvbbnn00/goflet/util"
 	"github.com/vvbbnn00/goflet/util/hash"
 	"github.com/vvbbnn00/goflet/util/log"
@@ -79,59 +82,252 @@ func GetTempFileWriteStream(relativePath string) (*os.File, error) {
 	return file, nil
 }
 
-// CompleteFileUpload Complete the file upload by renaming the temporary file to the final file
-func CompleteFileUpload(relativePath string) error {
-	fileName := hash.StringSha3New256(relativePath) // Get the hash of the path
-	tmpPath := filepath.Join(uploadPath, fileName)
+// CreateObjectFile Create a private temporary file in the folder of the path for the upload of a whole file, so the
+// file is not shared with the chunked upload of the path, like the imported files. The upload is completed with
+// CompleteObjectUpload, the caller removes the file if the upload fails before
+func CreateObjectFile(relativePath string) (*os.File, error) {
+	// If it has subdirectory, check whether the directory can be created
+	dir := filepath.Dir(relativePath)
+	if dir != "." && !canCreateFolder {
+		return nil, errors.New("directory_creation")
+	}
+
+	fsPath, err := util.RelativeToFsPath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(fsPath, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := filepath.Join(fsPath, "tmp-upload-"+util.RandomString(10))
+	return os.OpenFile(tmpPath, os.O_CREATE|os.O_EXCL|os.O_RDWR, model.FilePerm)
+}
+
+// uploadDeltas Get the quota changes of replacing the file at the path with a new file of the size
+func uploadDeltas(relativePath string, fsPath string, owner string, size int64) []quota.Delta {
+	newFile := quota.Delta{
+		RelativePath: relativePath,
+		Owner:        owner,
+		Bytes:        size,
+		Files:        1,
+	}
+	oldFile, exists := quota.Existing(fsPath)
+	if !exists {
+		return []quota.Delta{newFile}
+	}
+	// The owner is kept if the file is overwritten anonymously
+	if newFile.Owner == "" {
+		newFile.Owner = oldFile.Owner
+	}
+	return []quota.Delta{newFile, oldFile.Negate()}
+}
+
+// CheckQuota Check whether a file of the size can be uploaded to the path by the owner
+func CheckQuota(relativePath string, owner string, size int64) error {
+	if !quota.Enabled() {
+		return nil
+	}
+	fsPath, err := util.RelativeToFsPath(relativePath)
+	if err != nil {
+		return err
+	}
+	return quota.Check(uploadDeltas(relativePath, fsPath, owner, size)...)
+}
+
+// CompleteFileUpload Complete the file upload by renaming the temporary file to the final file, the subject of
+// the actor becomes the owner of the file, the event of the type is published once the file is in place
+func CompleteFileUpload(relativePath string, actor event.Actor, eventType event.Type) error {
+	complete, err := prepareUpload(relativePath, GetTempFilePath(relativePath), actor, eventType)
+	if err != nil {
+		return err
+	}
+	go func() {
+		_ = complete()
+	}()
+	return nil
+}
+
+// CompleteFileUploadSync Complete the file upload like CompleteFileUpload, but the file is in place when it returns,
+// for the APIs whose clients expect to read the file right after the upload
+func CompleteFileUploadSync(relativePath string, actor event.Actor, eventType event.Type) error {
+	complete, err := prepareUpload(relativePath, GetTempFilePath(relativePath), actor, eventType)
+	if err != nil {
+		return err
+	}
+	return complete()
+}
+
+// CompleteObjectUpload Complete the upload of the file created by CreateObjectFile like CompleteFileUploadSync,
+// the file is removed if the upload fails
+func CompleteObjectUpload(relativePath string, tmpPath string, actor event.Actor, eventType event.Type) error {
+	complete, err := prepareUpload(relativePath, tmpPath, actor, eventType)
+	if err == nil {
+		err = complete()
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+	}
+	return err
+}
+
+// prepareUpload checks the temporary file of the upload, returns the function to complete the upload
+func prepareUpload(relativePath string, tmpPath string, actor event.Actor, eventType event.Type) (func() error, error) {
+	owner := actor.Subject
 	c := cache.GetCache()
 	// Ensure the directory exists
 	fsPath, err := util.RelativeToFsPath(relativePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	exists, _ := c.GetBool(storage.CachePrefix + fsPath)
 	if exists {
-		return errors.New("file_uploading")
+		return nil, errors.New("file_uploading")
 	}
 
 	// Check if the temporary file exists
-	_, err = os.Stat(tmpPath)
+	tmpInfo, err := os.Stat(tmpPath)
 	if err != nil {
-		return errors.New("file_not_found")
+		return nil, errors.New("file_not_found")
 	}
 
 	// Make sure the directory exists
 	dir := filepath.Dir(fsPath)
 	err = os.MkdirAll(dir, os.ModePerm)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Open the temporary file to get file header info
-	mimeType, err := mimetype.DetectFile(tmpPath)
+	mimeTypeStr, err := detectMimeType(tmpPath)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	meta := model.FileMeta{
+		RelativePath: relativePath,
+		FileName:     filepath.Base(relativePath),
+		MimeType:     mimeTypeStr,
+		UploadedAt:   time.Now().Unix(),
+		Owner:        owner,
+	}
+
+	// Reserve the quota for the file, so the concurrent uploads cannot exceed it together
+	release := func() {}
+	if quota.Enabled() {
+		release, err = quota.Reserve(uploadDeltas(relativePath, fsPath, owner, tmpInfo.Size())...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return func() error {
+		return completeUpload(fsPath, tmpPath, meta, release, event.Event{Type: eventType, Actor: actor})
+	}, nil
+}
+
+// detectMimeType detects the mime type of the file from its header
+func detectMimeType(path string) (string, error) {
+	mimeType, err := mimetype.DetectFile(path)
+	if err != nil {
+		return "", err
 	}
 	mimeTypeStr := mimeType.String()
 	// If the file type is like html, xml, etc, set it to text/plain
 	if strings.HasPrefix(mimeTypeStr, "text/") {
 		mimeTypeStr = "text/plain"
 	}
+	return mimeTypeStr, nil
+}
+
+// ImportFile Store the local file at the relative path, owned by the owner. The file is copied, or hard linked if link
+// is true, into the folder of the path, so the file is never moved across devices. The quota is checked like the
+// uploads if checkQuota is true, otherwise the usage is only counted
+func ImportFile(localPath string, relativePath string, owner string, link bool, checkQuota bool) error {
+	fsPath, err := util.RelativeToFsPath(relativePath)
+	if err != nil {
+		return err
+	}
+	exists, _ := cache.GetCache().GetBool(storage.CachePrefix + fsPath)
+	if exists {
+		return errors.New("file_uploading")
+	}
+
+	err = os.MkdirAll(fsPath, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	tmpPath := filepath.Join(fsPath, "tmp-import-"+util.RandomString(10))
+	if link {
+		err = os.Link(localPath, tmpPath)
+	} else {
+		err = copyLocalFile(localPath, tmpPath)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
 
-	// Complete the upload
-	go completeUpload(fsPath, tmpPath, model.FileMeta{
+	mimeTypeStr, err := detectMimeType(tmpPath)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	release := func() {}
+	if quota.Enabled() {
+		info, err := os.Stat(tmpPath)
+		if err != nil {
+			_ = os.Remove(tmpPath)
+			return err
+		}
+		deltas := uploadDeltas(relativePath, fsPath, owner, info.Size())
+		if !checkQuota {
+			release = quota.Count(deltas...)
+		} else if release, err = quota.Reserve(deltas...); err != nil {
+			_ = os.Remove(tmpPath)
+			return err
+		}
+	}
+
+	meta := model.FileMeta{
 		RelativePath: relativePath,
 		FileName:     filepath.Base(relativePath),
 		MimeType:     mimeTypeStr,
 		UploadedAt:   time.Now().Unix(),
-	})
+		Owner:        owner,
+	}
+	e := event.Event{Type: event.TypeFileUploaded, Actor: event.Actor{Subject: owner}}
+	err = completeUpload(fsPath, tmpPath, meta, release, e)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+	}
+	return err
+}
 
-	return nil
+// copyLocalFile copies the local file to the path
+func copyLocalFile(src string, dst string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = srcFile.Close()
+	}()
+
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, model.FilePerm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dstFile, srcFile)
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
 
-// completeUpload completes the file upload by renaming the temporary file to the final file
-func completeUpload(fsPath string, tmpPath string, meta model.FileMeta) {
+// completeUpload completes the file upload by renaming the temporary file to the final file, the quota reserved
+// for the file is released if the file cannot be put in place
+func completeUpload(fsPath string, tmpPath string, meta model.FileMeta, release func(), e event.Event) error {
 	c := cache.GetCache()
 	_ = c.SetEx(storage.CachePrefix+fsPath, true, 60)
 
@@ -145,31 +341,47 @@ func completeUpload(fsPath string, tmpPath string, meta model.FileMeta) {
 	err := os.MkdirAll(fsPath, os.ModePerm)
 	if err != nil {
 		log.Debugf("Error creating directory: %s", err.Error())
-		return // Give up if the directory cannot be created
+		release()
+		return err // Give up if the directory cannot be created
+	}
+
+	// Clear the hashes of the previous content, they are computed again once the file is in place
+	err = storage.SetFileHash(fsPath, model.FileHash{})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("Error clearing file hash: %s", err.Error())
+		release()
+		return err // Give up if the previous hashes cannot be cleared
 	}
 
 	// Rename the temporary file to the final file
 	err = storage.RenameFile(tmpPath, filePath)
 	if err != nil {
 		log.Debugf("Error moving file: %s", err.Error())
-		return // Give up if the file cannot be moved
+		release()
+		return err // Give up if the file cannot be moved
 	}
 
 	// Update the file meta
 	err = storage.UpdateFileMeta(fsPath, meta)
 	if err != nil {
 		log.Warnf("Error updating file meta: %s", err.Error())
-		return // Give up if the file meta cannot be updated
+		return err // Give up if the file meta cannot be updated
 	}
+	event.Publish(event.NewFileEvent(e.Type, e.Actor, meta.RelativePath, fsPath))
 
 	wg := sync.WaitGroup{}
-	wg.Add(2)
+	wg.Add(3)
 
 	// Update the file hash
 	go func() {
 		hasher.HashFileAsync(fsPath)
 		wg.Done()
 	}()
+	// Update the image information, like the size and the BlurHash
+	go func() {
+		image.AnalyzeFileAsync(fsPath)
+		wg.Done()
+	}()
 	// Remove image cache ending with .image_*
 	go func() {
 		image.RemoveImageCache(fsPath)
@@ -177,4 +389,5 @@ func completeUpload(fsPath string, tmpPath string, meta model.FileMeta) {
 	}()
 
 	wg.Wait()
+	return nil
 }
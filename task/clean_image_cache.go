@@ -0,0 +1,12 @@
+package task
+
+import (
+	"github.com/vvbbnn00/goflet/storage/image"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// CleanImageCache Evict the least recently used processed images over the budget of the image cache
+func CleanImageCache() {
+	stats := image.CleanImageCache()
+	log.Infof("Image cache holds %d images of %d bytes, %d evicted since the start", stats.Files, stats.Bytes, stats.Evictions)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/storage/upload"
 	"github.com/vvbbnn00/goflet/util"
 	"github.com/vvbbnn00/goflet/util/log"
 )
@@ -14,12 +15,16 @@ import (
 // CleanOutdatedFile Clean outdated files
 func CleanOutdatedFile() {
 	uploadPath := util.GetUploadPath()
+	multipartPath := filepath.Clean(upload.GetMultipartPath())
 	UploadTimeout := time.Duration(config.GofletCfg.FileConfig.UploadTimeout) * time.Second
 
 	_ = filepath.Walk(uploadPath, func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if info.IsDir() && filepath.Clean(path) == multipartPath {
+			return filepath.SkipDir // The multipart uploads expire as a whole
+		}
 		if !info.IsDir() {
 			if time.Since(info.ModTime()) > UploadTimeout {
 				log.Infof("Remove outdated file: %s", path)
@@ -28,4 +33,9 @@ func CleanOutdatedFile() {
 		}
 		return nil
 	})
+
+	for _, dir := range upload.OutdatedMultipartUploads(UploadTimeout) {
+		log.Infof("Remove outdated multipart upload: %s", dir)
+		_ = os.RemoveAll(dir)
+	}
 }
@@ -5,14 +5,23 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/vvbbnn00/goflet/config"
 	"github.com/vvbbnn00/goflet/util"
 	"github.com/vvbbnn00/goflet/util/log"
 )
 
-// DeleteEmptyFolder Delete empty folders
+// DeleteEmptyFolder Delete empty folders in the storage roots and the image cache path
 func DeleteEmptyFolder() {
-	dataPath := util.GetBasePath()
+	for _, root := range util.GetStorageRoots() {
+		deleteEmptyFolder(root)
+	}
+	if path := config.GofletCfg.ImageCacheConfig.Path; path != "" {
+		deleteEmptyFolder(util.GetPath(path))
+	}
+}
 
+// deleteEmptyFolder Delete empty folders in the data path
+func deleteEmptyFolder(dataPath string) {
 	var pathToCheckList []string
 
 	// Recursively delete empty folders
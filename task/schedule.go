@@ -12,6 +12,8 @@ import (
 var scheduleToCheck = map[string]func(){
 	"DeleteEmptyFolder": DeleteEmptyFolder,
 	"CleanOutdatedFile": CleanOutdatedFile,
+	"ScrubFiles":        ScrubFiles,
+	"CleanImageCache":   CleanImageCache,
 }
 
 // runTask runs the task
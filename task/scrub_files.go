@@ -0,0 +1,22 @@
+package task
+
+import (
+	"encoding/json"
+
+	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/storage/scrub"
+	"github.com/vvbbnn00/goflet/util/log"
+)
+
+// ScrubFiles Verify the stored files, the problems are logged as JSON
+func ScrubFiles() {
+	report := scrub.Run(scrub.Options{
+		Repair:     *config.GofletCfg.ScrubConfig.Repair,
+		Quarantine: *config.GofletCfg.ScrubConfig.Quarantine,
+	})
+	for _, problem := range report.Problems {
+		data, _ := json.Marshal(problem)
+		log.Warnf("Scrub problem: %s", data)
+	}
+	log.Infof("Scrub checked %d files, found %d problems", report.Checked, len(report.Problems))
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/vvbbnn00/goflet/config"
+)
+
+// ErrBucketNotFound The error for the path outside any bucket
+var ErrBucketNotFound = errors.New("bucket not found")
+
+// BucketsEnabled returns whether the buckets are enabled
+func BucketsEnabled() bool {
+	return *config.GofletCfg.BucketConfig.Enabled
+}
+
+// GetBucketName returns the bucket name of the relative path, which is the first segment of the path
+func GetBucketName(relativePath string) string {
+	relativePath = strings.TrimLeft(strings.ReplaceAll(relativePath, "\\", "/"), "/")
+	name, _, _ := strings.Cut(relativePath, "/")
+	return name
+}
+
+// GetBucket returns the bucket name and the configuration of the relative path, false if the buckets are
+// disabled or the path is not in a bucket
+func GetBucket(relativePath string) (string, *config.Bucket, bool) {
+	name := GetBucketName(relativePath)
+	bucket, ok := config.GofletCfg.GetBucket(name)
+	return name, bucket, ok
+}
+
+// GetStorageRoots returns the base path and the storage roots of the buckets, without duplicates
+func GetStorageRoots() []string {
+	roots := []string{BasePath}
+	if !BucketsEnabled() {
+		return roots
+	}
+	for _, bucket := range config.GofletCfg.BucketConfig.Buckets {
+		if bucket.BaseFileStoragePath == "" {
+			continue
+		}
+		root := GetPath(bucket.BaseFileStoragePath)
+		if !slices.Contains(roots, root) {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// GetUploadLimit returns the maximum size of the file to be uploaded to the relative path
+func GetUploadLimit(relativePath string) int64 {
+	if _, bucket, ok := GetBucket(relativePath); ok {
+		return bucket.UploadLimit
+	}
+	return config.GofletCfg.FileConfig.UploadLimit
+}
+
+// GetMaxPostSize returns the maximum size of the post request to the relative path
+func GetMaxPostSize(relativePath string) int64 {
+	if _, bucket, ok := GetBucket(relativePath); ok {
+		return bucket.MaxPostSize
+	}
+	return config.GofletCfg.FileConfig.MaxPostSize
+}
+
+// GetImageConfig returns the image configuration of the relative path
+func GetImageConfig(relativePath string) *config.ImageConfig {
+	if _, bucket, ok := GetBucket(relativePath); ok {
+		return &bucket.ImageConfig
+	}
+	return &config.GofletCfg.ImageConfig
+}
+
+// getStorageRoot returns the storage root of the relative path
+func getStorageRoot(relativePath string) string {
+	if _, bucket, ok := GetBucket(relativePath); ok && bucket.BaseFileStoragePath != "" {
+		return GetPath(bucket.BaseFileStoragePath)
+	}
+	return BasePath
+}
@@ -0,0 +1,81 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vvbbnn00/goflet/config"
+)
+
+// enableBuckets enables the buckets with the provided configuration
+func enableBuckets(buckets map[string]config.Bucket) {
+	enabled := true
+	config.GofletCfg.BucketConfig.Enabled = &enabled
+	config.GofletCfg.BucketConfig.Buckets = buckets
+	config.InitBuckets()
+}
+
+// disableBuckets disables the buckets
+func disableBuckets() {
+	enabled := false
+	config.GofletCfg.BucketConfig.Enabled = &enabled
+	config.GofletCfg.BucketConfig.Buckets = nil
+}
+
+func signToken(t *testing.T, key string, bucket string) string {
+	claims := &JwtClaims{StandardClaims: &jwt.StandardClaims{Subject: "test"}, Bucket: bucket}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestBucketPath(t *testing.T) {
+	enableBuckets(map[string]config.Bucket{
+		"product-a": {UploadLimit: 1024},
+		"product-b": {},
+	})
+	defer disableBuckets()
+
+	assert.Equal(t, "product-a", GetBucketName("product-a/path/to/file.txt"))
+
+	pathA, err := ParsePath("/product-a/file.txt")
+	assert.NoError(t, err)
+	pathB, err := ParsePath("/product-b/file.txt")
+	assert.NoError(t, err)
+	assert.NotEqual(t, pathA.FsPath, pathB.FsPath)
+
+	_, err = ParsePath("/product-c/file.txt")
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+
+	assert.Equal(t, int64(1024), GetUploadLimit("product-a/file.txt"))
+	assert.Equal(t, config.GofletCfg.FileConfig.UploadLimit, GetUploadLimit("product-b/file.txt"))
+}
+
+func TestBucketJwt(t *testing.T) {
+	config.GofletCfg.JWTConfig.Algorithm = "HS256"
+	config.GofletCfg.JWTConfig.Security.SigningKey = "global-key"
+	JwtInit()
+
+	enableBuckets(map[string]config.Bucket{
+		"product-a": {JWTConfig: config.BucketJWTConfig{SigningKey: "bucket-key"}},
+	})
+	defer disableBuckets()
+
+	claims, err := ParseJwtToken(signToken(t, "bucket-key", "product-a"))
+	assert.NoError(t, err)
+	assert.Equal(t, "product-a", claims.Bucket)
+
+	// The bucket token should be signed with the key of the bucket
+	_, err = ParseJwtToken(signToken(t, "global-key", "product-a"))
+	assert.Error(t, err)
+
+	_, err = ParseJwtToken(signToken(t, "bucket-key", "product-b"))
+	assert.ErrorIs(t, err, ErrBucketNotFound)
+
+	_, err = ParseJwtToken(signToken(t, "global-key", ""))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,33 @@
+// Package claims provides the claims of the JWT tokens, it has no dependency on the configuration,
+// so the clients can build the tokens without loading the configuration of the server
+package claims
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Permission The permission of the token
+type Permission struct {
+	Path    string            `json:"path"`    // The path that the token is allowed to access, supports wildcards
+	Methods []string          `json:"methods"` // The methods that the token is allowed to access
+	Query   map[string]string `json:"query"`   // The query parameters, if set in the map, the query should match the map
+}
+
+// JwtClaims The body of the JWT token
+type JwtClaims struct {
+	*jwt.StandardClaims
+	Permissions  []Permission `json:"permissions"`            // The permissions of the token
+	RateLimitBps int64        `json:"rateLimitBps,omitempty"` // The bandwidth of each connection in bytes per second, overrides the configured value
+	Bucket       string       `json:"bucket,omitempty"`       // The bucket that the token is bound to, verified with the key of the bucket
+	Watermark    string       `json:"watermark,omitempty"`    // The watermark preset forced on the processed images
+}
+
+// Valid The function to validate the JWT token
+func (c *JwtClaims) Valid() error {
+	if c.StandardClaims == nil { // Check StandardClaims in case of nil pointer dereference
+		return errors.New("missing required fields")
+	}
+	return c.StandardClaims.Valid()
+}
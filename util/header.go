@@ -6,14 +6,10 @@ import (
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/vvbbnn00/goflet/config"
 )
 
-// HeaderParseRangeUpload Parse the range header and return the start and end
-func HeaderParseRangeUpload(contentRange string, contentLength string) (start int64, end int64, total int64, err error) {
-	uploadLimit := config.GofletCfg.FileConfig.UploadLimit
-
+// HeaderParseRangeUpload Parse the range header and return the start and end, the total size should not exceed the upload limit
+func HeaderParseRangeUpload(contentRange string, contentLength string, uploadLimit int64) (start int64, end int64, total int64, err error) {
 	contentLengthInt, err := strconv.ParseInt(contentLength, 10, 64)
 	if err != nil {
 		return 0, 0, 0, errors.New("invalid content length")
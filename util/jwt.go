@@ -6,13 +6,19 @@ import (
 	"github.com/golang-jwt/jwt"
 
 	"github.com/vvbbnn00/goflet/config"
+	"github.com/vvbbnn00/goflet/util/claims"
 )
 
-var (
+// keyConfig The configuration to verify the JWT token
+type keyConfig struct {
 	secretKey      string
 	publicKey      string
 	alg            string
 	trustedIssuers []string
+}
+
+var (
+	globalKeyConfig keyConfig
 )
 
 func init() {
@@ -22,10 +28,12 @@ func init() {
 // JwtInit Initialize the JWT
 func JwtInit() {
 	conf := config.GofletCfg.JWTConfig
-	alg = conf.Algorithm // The only supported algorithm defined in the configuration
-	secretKey = conf.Security.SigningKey
-	publicKey = conf.Security.PublicKey
-	trustedIssuers = conf.TrustedIssuers
+	globalKeyConfig = keyConfig{
+		alg:            conf.Algorithm, // The only supported algorithm defined in the configuration
+		secretKey:      conf.Security.SigningKey,
+		publicKey:      conf.Security.PublicKey,
+		trustedIssuers: conf.TrustedIssuers,
+	}
 }
 
 // ErrInvalidAlgorithm The error for invalid algorithm
@@ -34,30 +42,20 @@ var ErrInvalidAlgorithm = errors.New("invalid algorithm")
 // ErrUnsafeNoneAlgorithm The error for unsafe none algorithm
 var ErrUnsafeNoneAlgorithm = errors.New("none algorithm is not supported for security reasons")
 
-// Permission The permission of the token
-type Permission struct {
-	Path    string            `json:"path"`    // The path that the token is allowed to access, supports wildcards
-	Methods []string          `json:"methods"` // The methods that the token is allowed to access
-	Query   map[string]string `json:"query"`   // The query parameters, if set in the map, the query should match the map
-}
+// Permission The permission of the token, defined in the claims package for the clients
+type Permission = claims.Permission
 
-// JwtClaims The body of the JWT token
-type JwtClaims struct {
-	*jwt.StandardClaims
-	Permissions []Permission `json:"permissions"` // The permissions of the token
-}
-
-// Valid The function to validate the JWT token
-func (c *JwtClaims) Valid() error {
-	if c.StandardClaims == nil { // Check StandardClaims in case of nil pointer dereference
-		return errors.New("missing required fields")
-	}
-	return c.StandardClaims.Valid()
-}
+// JwtClaims The body of the JWT token, defined in the claims package for the clients
+type JwtClaims = claims.JwtClaims
 
 // ParseJwtToken Parse the JWT token
 func ParseJwtToken(tokenString string) (*JwtClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JwtClaims{}, selectSecretKey)
+	keyConf, err := selectKeyConfig(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &JwtClaims{}, keyConf.selectSecretKey)
 	if err != nil {
 		return nil, err
 	}
@@ -68,6 +66,7 @@ func ParseJwtToken(tokenString string) (*JwtClaims, error) {
 	}
 
 	// If there is no trusted issuer, trust any issuer
+	trustedIssuers := keyConf.trustedIssuers
 	if len(trustedIssuers) == 0 {
 		return claims, nil
 	}
@@ -88,9 +87,33 @@ func ParseJwtToken(tokenString string) (*JwtClaims, error) {
 	return claims, nil
 }
 
+// selectKeyConfig Select the key configuration by the bucket claim of the JWT token, the claim is verified afterwards
+func selectKeyConfig(tokenString string) (keyConfig, error) {
+	unverified := &JwtClaims{}
+	_, _, err := new(jwt.Parser).ParseUnverified(tokenString, unverified)
+	if err != nil {
+		return keyConfig{}, err
+	}
+	if unverified.Bucket == "" {
+		return globalKeyConfig, nil
+	}
+
+	bucket, ok := config.GofletCfg.GetBucket(unverified.Bucket)
+	if !ok {
+		return keyConfig{}, ErrBucketNotFound
+	}
+	return keyConfig{
+		alg:            bucket.JWTConfig.Algorithm,
+		secretKey:      bucket.JWTConfig.SigningKey,
+		publicKey:      bucket.JWTConfig.PublicKey,
+		trustedIssuers: bucket.JWTConfig.TrustedIssuers,
+	}, nil
+}
+
 // selectSecretKey The function to get the key for the JWT token
-func selectSecretKey(token *jwt.Token) (interface{}, error) {
-	if token.Method.Alg() != alg {
+func (k keyConfig) selectSecretKey(token *jwt.Token) (interface{}, error) {
+	secretKey, publicKey := k.secretKey, k.publicKey
+	if token.Method.Alg() != k.alg {
 		return nil, ErrInvalidAlgorithm
 	}
 
@@ -21,10 +21,12 @@ var (
 // init initializes the storage package
 func init() {
 	BasePath = GetBasePath()
-	// Ensure the base path exists
-	err := os.MkdirAll(BasePath, os.ModePerm)
-	if err != nil {
-		log.Fatalf("Error creating base path: %s", err.Error())
+	// Ensure the base path and the storage roots of the buckets exist
+	for _, root := range GetStorageRoots() {
+		err := os.MkdirAll(root, os.ModePerm)
+		if err != nil {
+			log.Fatalf("Error creating base path: %s", err.Error())
+		}
 	}
 }
 
@@ -60,8 +62,8 @@ func RelativeToFsPath(path string) (string, error) {
 	firstIndex := pathHash[:2]
 	secondIndex := pathHash[2:4]
 
-	// Join the parts to get the real file system path
-	fsPath := filepath.Join(BasePath, firstIndex, secondIndex, pathHash)
+	// Join the parts to get the real file system path, the files of a bucket may be stored in its own root
+	fsPath := filepath.Join(getStorageRoot(path), firstIndex, secondIndex, pathHash)
 
 	// Add filepath separator to the end of the path to ensure it is a folder
 	if !strings.HasSuffix(fsPath, string(filepath.Separator)) {
@@ -97,6 +99,12 @@ func ParsePath(path string) (*Path, error) {
 		return nil, err
 	}
 
+	// The path should be in a bucket if the buckets are enabled
+	if _, _, ok := GetBucket(relativePath); BucketsEnabled() && !ok {
+		log.Debugf("Bucket not found: %s", relativePath)
+		return nil, ErrBucketNotFound
+	}
+
 	// Convert the relative path to fs path
 	fsPath, err := RelativeToFsPath(relativePath)
 	if err != nil {
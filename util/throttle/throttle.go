@@ -0,0 +1,161 @@
+// Package throttle provides bandwidth limited readers and writers
+package throttle
+
+import (
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/vvbbnn00/goflet/config"
+)
+
+const maxChunkSize = 32 * 1024 // The maximum number of bytes transferred at once, same as the buffer size of io.Copy
+
+var (
+	// GlobalDownload is the limiter shared by all downloads, nil if not limited
+	GlobalDownload *Limiter
+	// GlobalUpload is the limiter shared by all uploads, nil if not limited
+	GlobalUpload *Limiter
+)
+
+func init() {
+	conf := config.GofletCfg.BandwidthConfig
+	GlobalDownload = NewLimiter(conf.DownloadGlobalBps)
+	GlobalUpload = NewLimiter(conf.UploadGlobalBps)
+}
+
+// Limiter is a token bucket limiting the number of bytes per second, a nil limiter does not limit anything
+type Limiter struct {
+	bps    float64   // The number of bytes allowed per second
+	burst  float64   // The maximum number of bytes allowed at once
+	tokens float64   // The number of bytes available, may be negative when the bandwidth is overdrawn
+	last   time.Time // The last time the tokens were refilled
+	lock   sync.Mutex
+}
+
+// NewLimiter creates a new limiter with the given bytes per second, returns nil if bps le 0
+func NewLimiter(bps int64) *Limiter {
+	if bps <= 0 {
+		return nil
+	}
+	return &Limiter{
+		bps:    float64(bps),
+		burst:  float64(bps),
+		tokens: float64(bps),
+		last:   time.Now(),
+	}
+}
+
+// chunkSize returns the maximum number of bytes to transfer at once
+func (l *Limiter) chunkSize() int {
+	if l == nil {
+		return maxChunkSize
+	}
+	return int(math.Max(1, math.Min(maxChunkSize, l.burst)))
+}
+
+// WaitN blocks until n bytes can be transferred
+func (l *Limiter) WaitN(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+
+	l.lock.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.bps)
+	l.last = now
+	// Reserve the bytes, the caller waits until the debt is paid
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.bps * float64(time.Second))
+	}
+	l.lock.Unlock()
+
+	time.Sleep(wait)
+}
+
+// limiters is a group of limiters applied together
+type limiters []*Limiter
+
+// compact removes the nil limiters
+func compact(list []*Limiter) limiters {
+	result := make(limiters, 0, len(list))
+	for _, l := range list {
+		if l != nil {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+// chunkSize returns the smallest chunk size of the limiters
+func (ls limiters) chunkSize() int {
+	size := maxChunkSize
+	for _, l := range ls {
+		size = min(size, l.chunkSize())
+	}
+	return size
+}
+
+// waitN waits for all the limiters
+func (ls limiters) waitN(n int) {
+	for _, l := range ls {
+		l.WaitN(n)
+	}
+}
+
+type reader struct {
+	r  io.Reader
+	ls limiters
+}
+
+// Read reads from the underlying reader and waits for the limiters
+func (t *reader) Read(p []byte) (int, error) {
+	if size := t.ls.chunkSize(); len(p) > size {
+		p = p[:size]
+	}
+	n, err := t.r.Read(p)
+	t.ls.waitN(n)
+	return n, err
+}
+
+type writer struct {
+	w  io.Writer
+	ls limiters
+}
+
+// Write waits for the limiters and writes to the underlying writer chunk by chunk
+func (t *writer) Write(p []byte) (int, error) {
+	size := t.ls.chunkSize()
+	written := 0
+	for written < len(p) {
+		end := min(written+size, len(p))
+		t.ls.waitN(end - written)
+		n, err := t.w.Write(p[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// NewReader returns a reader limited by the given limiters, the nil limiters are ignored
+func NewReader(r io.Reader, limiters ...*Limiter) io.Reader {
+	ls := compact(limiters)
+	if len(ls) == 0 {
+		return r
+	}
+	return &reader{r: r, ls: ls}
+}
+
+// NewWriter returns a writer limited by the given limiters, the nil limiters are ignored
+func NewWriter(w io.Writer, limiters ...*Limiter) io.Writer {
+	ls := compact(limiters)
+	if len(ls) == 0 {
+		return w
+	}
+	return &writer{w: w, ls: ls}
+}
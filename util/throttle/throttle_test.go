@@ -0,0 +1,57 @@
+package throttle
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+const testBps = 64 * 1024
+
+func TestNilLimiter(t *testing.T) {
+	r := bytes.NewReader(nil)
+	if NewReader(r, nil, NewLimiter(0)) != r {
+		t.Errorf("The reader should not be wrapped without limiters.")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	data := make([]byte, 2*testBps)
+	var buf bytes.Buffer
+
+	start := time.Now()
+	n, err := NewWriter(&buf, NewLimiter(testBps)).Write(data)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Errorf("The error should be nil, but got %v.", err)
+	}
+	if n != len(data) || buf.Len() != len(data) {
+		t.Errorf("The written size should be [%d], but got [%d].", len(data), n)
+	}
+	// The first second is covered by the burst
+	if elapsed < 900*time.Millisecond {
+		t.Errorf("The write should take about 1 second, but took %v.", elapsed)
+	}
+}
+
+func TestReader(t *testing.T) {
+	data := make([]byte, 2*testBps)
+	global := NewLimiter(testBps)
+
+	start := time.Now()
+	n, err := io.Copy(io.Discard, NewReader(bytes.NewReader(data), global, NewLimiter(4*testBps)))
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Errorf("The error should be nil, but got %v.", err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("The read size should be [%d], but got [%d].", len(data), n)
+	}
+	// The slowest limiter wins
+	if elapsed < 900*time.Millisecond {
+		t.Errorf("The read should take about 1 second, but took %v.", elapsed)
+	}
+}
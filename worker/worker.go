@@ -9,7 +9,7 @@ import (
 )
 
 const (
-	maxJobRetries    = 3 // Maximum number of retries for a job
+	MaxJobRetries    = 3 // Maximum number of retries for a job
 	noPoolBufferSize = 0 // No buffer for the job chain
 )
 
@@ -49,8 +49,8 @@ func (w *Worker) work(jobChain chan Job, cancelChain <-chan struct{}, wg *sync.W
 			return
 		case job := <-jobChain:
 			// Check if the job has exceeded the maximum number of retries
-			if job.RetryCount > maxJobRetries {
-				log.Warnf("[Worker] Job %s(%v) failed after %d retries", w.JobName, job.Args, maxJobRetries)
+			if job.RetryCount > MaxJobRetries {
+				log.Warnf("[Worker] Job %s(%v) failed after %d retries", w.JobName, job.Args, MaxJobRetries)
 				continue
 			}
 